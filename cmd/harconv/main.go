@@ -0,0 +1,163 @@
+// Command harconv converts between HAR files and simulator recordings.
+//
+//	harconv import -in session.har -out records
+//	harconv export -http 'GET https://api.binance.com/api/v3/ping=records/http/ping' -ws 'wss://api.whitebit.com/ws=records/ws' -out session.har
+//	harconv export -journal http://localhost:8080/admin -out journal.har
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"alphanonce.com/exchangesimulator/internal/log"
+	"alphanonce.com/exchangesimulator/internal/simulator"
+)
+
+var logger *log.Logger
+
+func init() {
+	logger = log.NewDefault().With(log.String("package", "main"))
+}
+
+type mappings []string
+
+func (m *mappings) String() string {
+	return strings.Join(*m, ",")
+}
+
+func (m *mappings) Set(value string) error {
+	*m = append(*m, value)
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: harconv import|export [flags]")
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "import":
+		err = runImport(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command: %s", os.Args[1])
+	}
+	if err != nil {
+		logger.Error("Conversion failed", log.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	in := flags.String("in", "", "HAR file to read")
+	out := flags.String("out", "records", "directory to write recordings to")
+	flags.Parse(args)
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+
+	h, err := simulator.ReadHar(*in)
+	if err != nil {
+		return err
+	}
+
+	err = simulator.WriteHarRecords(h, *out)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("HAR imported", log.String("in", *in), log.String("out", *out), log.Int("entries", len(h.Log.Entries)))
+	return nil
+}
+
+func runExport(args []string) error {
+	var httpMappings, wsMappings mappings
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.Var(&httpMappings, "http", "'METHOD URL=DIR' of recorded HTTP responses, split at the last = (repeatable)")
+	flags.Var(&wsMappings, "ws", "'URL=DIR' of recorded WebSocket messages, split at the last = (repeatable)")
+	journal := flags.String("journal", "", "admin API URL of a running simulator, e.g. http://localhost:8080/admin, whose journal is exported")
+	out := flags.String("out", "", "HAR file to write")
+	flags.Parse(args)
+
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+
+	h := simulator.NewHar()
+	if *journal != "" {
+		entries, err := readJournal(*journal)
+		if err != nil {
+			return err
+		}
+		h.Log.Entries = append(h.Log.Entries, entries...)
+	}
+	for _, m := range httpMappings {
+		request, dir, ok := cutLast(m, "=")
+		method, url, ok2 := strings.Cut(request, " ")
+		if !ok || !ok2 {
+			return fmt.Errorf("invalid -http mapping: %s", m)
+		}
+
+		entries, err := simulator.NewHarEntriesFromHttpRecords(method, url, dir)
+		if err != nil {
+			return err
+		}
+		h.Log.Entries = append(h.Log.Entries, entries...)
+	}
+	for _, m := range wsMappings {
+		url, dir, ok := cutLast(m, "=")
+		if !ok {
+			return fmt.Errorf("invalid -ws mapping: %s", m)
+		}
+
+		entry, err := simulator.NewHarEntryFromWsRecords(url, dir)
+		if err != nil {
+			return err
+		}
+		h.Log.Entries = append(h.Log.Entries, entry)
+	}
+
+	err := simulator.WriteHar(*out, h)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("HAR exported", log.String("out", *out), log.Int("entries", len(h.Log.Entries)))
+	return nil
+}
+
+// readJournal returns the journal of the simulator whose admin API is at adminUrl, as HAR entries
+func readJournal(adminUrl string) ([]simulator.HarEntry, error) {
+	response, err := http.Get(strings.TrimSuffix(adminUrl, "/") + "/journal/har")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get the journal: %s", response.Status)
+	}
+	h, err := simulator.DecodeHar(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	return h.Log.Entries, nil
+}
+
+// cutLast slices s around the last sep, as URLs may contain sep in their query
+func cutLast(s string, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i == -1 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
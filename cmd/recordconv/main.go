@@ -0,0 +1,38 @@
+// Command recordconv converts a directory or tar archive of WebSocket messages recorded one YAML file per message
+// into a single JSON Lines record file, which is compressed if it ends with .gz or .zst.
+//
+//	recordconv -dir data/ws/depth_update -out data/ws/depth_update.jsonl.zst
+package main
+
+import (
+	"flag"
+	"os"
+
+	"alphanonce.com/exchangesimulator/internal/log"
+	"alphanonce.com/exchangesimulator/internal/simulator"
+)
+
+var logger *log.Logger
+
+func init() {
+	logger = log.NewDefault().With(log.String("package", "main"))
+}
+
+func main() {
+	dir := flag.String("dir", "", "directory or tar archive of YAML message files named by their RFC 3339 time")
+	out := flag.String("out", "", "record file to append the messages to")
+	flag.Parse()
+
+	if *dir == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := simulator.ConvertWsRecordDir(*dir, *out)
+	if err != nil {
+		logger.Error("Conversion failed", log.Any("error", err))
+		os.Exit(1)
+	}
+
+	logger.Info("Records converted", log.String("dir", *dir), log.String("out", *out))
+}
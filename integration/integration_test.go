@@ -148,6 +148,8 @@ func TestIntegration(t *testing.T) {
 		},
 		WsRedirectUrl: mockServerURL,
 		WsRecordDir:   filepath.Join(tempDir, "redirect", "ws"),
+		// Records the traffic of every connection of the WebSocket tests
+		WsSessionRecordPath: filepath.Join(tempDir, "redirect", "session.jsonl"),
 	}
 
 	// Create a simulator
@@ -174,6 +176,7 @@ func TestIntegration(t *testing.T) {
 		testWsMessageHandlers(t, config)
 		testWsSubscription(t, config)
 		testWsRedirection(t, config)
+		testWsSessionRecording(t, config)
 	})
 }
 
@@ -582,3 +585,25 @@ func testWsRedirection(t *testing.T, config simulator.Config) {
 		})
 	}
 }
+
+func testWsSessionRecording(t *testing.T, config simulator.Config) {
+	t.Run("WebSocket session recording", func(t *testing.T) {
+		events, err := simulator.ReadWsSession(config.WsSessionRecordPath)
+		require.NoError(t, err)
+
+		var sent, received, opened int
+		for _, e := range events {
+			switch {
+			case e.Kind == simulator.WsSessionEventOpen:
+				opened++
+			case e.Kind == simulator.WsSessionEventMessage && e.Direction == simulator.WsDirectionClientToServer && string(e.Message.Data) == "redirect":
+				sent++
+			case e.Kind == simulator.WsSessionEventMessage && e.Direction == simulator.WsDirectionServerToClient && string(e.Message.Data) == "echoed: redirect":
+				received++
+			}
+		}
+		assert.Greater(t, opened, 1)
+		assert.Equal(t, 3, sent)
+		assert.Equal(t, 3, received)
+	})
+}
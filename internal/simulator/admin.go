@@ -0,0 +1,395 @@
+package simulator
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/log"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+)
+
+// HttpRuleDescription describes a HTTP rule that responds to the requests it matches with a fixed response.
+// It is what the admin API makes HTTP rules from.
+type HttpRuleDescription struct {
+	// Method and Path match the requests; empty matches any
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// StatusCode 0 stands for 200 OK
+	StatusCode int    `json:"status_code,omitempty"`
+	Body       string `json:"body"`
+	// ResponseTime is how long responding takes, such as 100ms
+	ResponseTime string `json:"response_time,omitempty"`
+}
+
+func (d HttpRuleDescription) rule() (HttpRule, error) {
+	responseTime, err := parseResponseTime(d.ResponseTime)
+	if err != nil {
+		return nil, err
+	}
+	statusCode := d.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	return NewHttpRule(NewHttpRequestPredicate(d.Method, d.Path), NewHttpResponseFromString(statusCode, d.Body, responseTime)), nil
+}
+
+// WsRuleDescription describes a WebSocket rule that answers the messages it matches with a fixed message.
+// It is what the admin API makes WebSocket rules from.
+type WsRuleDescription struct {
+	// Match matches the messages with exactly this data, and MatchJson the text messages with this JSON value
+	// however it is formatted. If neither is given, every message is matched.
+	Match     *string         `json:"match,omitempty"`
+	MatchJson json.RawMessage `json:"match_json,omitempty"`
+	// Type is the type of the messages matched and answered, text or binary, whose data is hex-encoded.
+	// Empty stands for text.
+	Type     string `json:"type,omitempty"`
+	Response string `json:"response"`
+	// ResponseTime is how long answering takes, such as 100ms
+	ResponseTime string `json:"response_time,omitempty"`
+}
+
+func (d WsRuleDescription) rule() (WsRule, error) {
+	responseTime, err := parseResponseTime(d.ResponseTime)
+	if err != nil {
+		return nil, err
+	}
+	messageType, decode, err := wsMessageDecoder(d.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	var matcher ws.MessageMatcher
+	switch {
+	case d.Match != nil && d.MatchJson != nil:
+		return nil, errors.New("match and match_json cannot both be given")
+	case d.Match != nil:
+		data, err := decode(*d.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match: %w", err)
+		}
+		matcher = NewWsMessagePredicate(messageType, data)
+	case d.MatchJson != nil:
+		if !json.Valid(d.MatchJson) {
+			return nil, errors.New("invalid match_json")
+		}
+		matcher = NewWsJsonMatcher(string(d.MatchJson))
+	default:
+		matcher = NewWsMessagePredicate(WsMessageAny, nil)
+	}
+
+	response, err := decode(d.Response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return NewWsRule(matcher, NewWsMessageFromString(messageType, string(response), responseTime)), nil
+}
+
+// wsMessageDecoder returns the message type named typeStr, text or binary, and how the data of its messages is decoded.
+// Empty stands for text.
+func wsMessageDecoder(typeStr string) (WsMessageType, func(string) ([]byte, error), error) {
+	switch typeStr {
+	case "", "text":
+		return WsMessageText, func(s string) ([]byte, error) { return []byte(s), nil }, nil
+	case "binary":
+		return WsMessageBinary, hex.DecodeString, nil
+	default:
+		return WsMessageAny, nil, fmt.Errorf("invalid message type %q", typeStr)
+	}
+}
+
+func parseResponseTime(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid response_time: %w", err)
+	}
+	return d, nil
+}
+
+// OutageDescription describes an outage the admin API starts
+type OutageDescription struct {
+	// Duration is how long the outage lasts, such as 5m; empty stands for until it is ended
+	Duration string `json:"duration,omitempty"`
+	// Scope is what the outage takes down, http or ws; empty stands for both
+	Scope OutageScope `json:"scope,omitempty"`
+	// StatusCode and Body answer the HTTP requests during the outage. StatusCode 0 stands for 503 Service Unavailable,
+	// with {"error":"Service Unavailable"} if Body is empty too.
+	StatusCode int    `json:"status_code,omitempty"`
+	Body       string `json:"body,omitempty"`
+	// CloseCode and CloseReason close the open WebSocket connections as the outage starts. CloseCode 0 stands for 1001, going away.
+	CloseCode   int    `json:"close_code,omitempty"`
+	CloseReason string `json:"close_reason,omitempty"`
+}
+
+func (d OutageDescription) outage() (Outage, time.Duration, error) {
+	var duration time.Duration
+	if d.Duration != "" {
+		var err error
+		duration, err = time.ParseDuration(d.Duration)
+		if err != nil || duration < 0 {
+			return Outage{}, 0, fmt.Errorf("invalid duration %q", d.Duration)
+		}
+	}
+	switch d.Scope {
+	case OutageScopeAll, OutageScopeHttp, OutageScopeWs:
+	default:
+		return Outage{}, 0, fmt.Errorf("invalid scope %q", d.Scope)
+	}
+
+	outage := Outage{Scope: d.Scope, WsClosure: WsClosure{Code: d.CloseCode, Reason: d.CloseReason}}
+	if d.StatusCode != 0 || d.Body != "" {
+		statusCode := d.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusServiceUnavailable
+		}
+		outage.HttpResponse = HttpResponse{StatusCode: statusCode, Body: []byte(d.Body)}
+	}
+	return outage, duration, nil
+}
+
+// ruleDescription is a description the admin API makes rules of type R from
+type ruleDescription[R any] interface {
+	rule() (R, error)
+}
+
+// adminRule is a rule as the admin API lists it
+type adminRule struct {
+	Id      int    `json:"id"`
+	Enabled bool   `json:"enabled"`
+	Type    string `json:"type"`
+	// Description is what the rule was made from, or null if it was made in code
+	Description any `json:"description"`
+}
+
+// newAdminHandler serves the admin API under basePath, to change the rules of s as it runs,
+// act on its connections as handleWsConnections tells and query its journal as handleJournal tells:
+//
+//	POST   {basePath}/outage                       starts the described outage
+//	DELETE {basePath}/outage                       ends the outage started by hand
+//	GET    {basePath}/rules/{http,ws}              lists the rules in the order they are matched in
+//	POST   {basePath}/rules/{http,ws}?index=N      adds the described rule at index N, or last
+//	PUT    {basePath}/rules/{http,ws}/{id}         replaces a rule with the described one
+//	DELETE {basePath}/rules/{http,ws}/{id}         removes a rule
+//	POST   {basePath}/rules/{http,ws}/{id}/enable  enables a rule
+//	POST   {basePath}/rules/{http,ws}/{id}/disable disables a rule, so that it matches nothing
+//	POST   {basePath}/reset                        puts the rules of the config back as they were made, stopping their
+//	                                               subscriptions and rate limits, ends the outage started by hand
+//	                                               and empties the journal
+func (s Simulator) newAdminHandler(basePath string) http.Handler {
+	mux := http.NewServeMux()
+	handleRules[HttpRule, HttpRuleDescription](mux, basePath+"/rules/http", s.httpRules)
+	handleRules[WsRule, WsRuleDescription](mux, basePath+"/rules/ws", s.wsRules)
+	s.handleWsConnections(mux, basePath)
+	s.handleJournal(mux, basePath)
+	mux.HandleFunc("POST "+basePath+"/outage", func(w http.ResponseWriter, r *http.Request) {
+		var description OutageDescription
+		err := decodeAdminJson(r, &description)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, fmt.Errorf("invalid outage description: %w", err))
+			return
+		}
+		outage, duration, err := description.outage()
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, fmt.Errorf("invalid outage description: %w", err))
+			return
+		}
+		s.StartOutage(outage, duration)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("DELETE "+basePath+"/outage", func(w http.ResponseWriter, r *http.Request) {
+		s.EndOutage()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST "+basePath+"/reset", func(w http.ResponseWriter, r *http.Request) {
+		s.httpRules.reset()
+		s.wsRules.reset()
+		s.EndOutage()
+		s.ResetJournal()
+		logger.Info("Reset the rules by the admin API")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// handleJournal serves the part of the admin API that verifies what the clients did:
+//
+//	GET    {basePath}/journal      returns the count and the entries of the journal that match the query, such as
+//	                               ?kind=http&method=POST&path=/api/v3/order&param=symbol=BTCUSDT, or
+//	                               ?kind=ws_in&connection_id=1&contains=UNSUBSCRIBE
+//	GET    {basePath}/journal/har  returns the entries of the journal that match the query as a HAR; see JournalHar
+//	DELETE {basePath}/journal      empties the journal
+func (s Simulator) handleJournal(mux *http.ServeMux, basePath string) {
+	mux.HandleFunc("GET "+basePath+"/journal", func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseJournalQuery(r.URL.Query())
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		entries := s.Journal(query)
+		writeAdminJson(w, http.StatusOK, map[string]any{"count": len(entries), "entries": entries})
+	})
+
+	mux.HandleFunc("GET "+basePath+"/journal/har", func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseJournalQuery(r.URL.Query())
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		h, err := s.JournalHar(query, scheme+"://"+r.Host)
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeAdminJson(w, http.StatusOK, h)
+	})
+
+	mux.HandleFunc("DELETE "+basePath+"/journal", func(w http.ResponseWriter, r *http.Request) {
+		s.ResetJournal()
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func parseJournalQuery(values url.Values) (JournalQuery, error) {
+	query := JournalQuery{
+		Kind:     JournalKind(values.Get("kind")),
+		Method:   values.Get("method"),
+		Path:     values.Get("path"),
+		Contains: values.Get("contains"),
+	}
+	if s := values.Get("connection_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return JournalQuery{}, fmt.Errorf("invalid connection_id: %w", err)
+		}
+		query.ConnectionId = id
+	}
+	for _, param := range values["param"] {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return JournalQuery{}, fmt.Errorf("invalid param %q, which must be key=value", param)
+		}
+		if query.Params == nil {
+			query.Params = map[string]string{}
+		}
+		query.Params[key] = value
+	}
+	return query, nil
+}
+
+func handleRules[R any, D ruleDescription[R]](mux *http.ServeMux, path string, rules *ruleSet[R]) {
+	mux.HandleFunc("GET "+path, func(w http.ResponseWriter, r *http.Request) {
+		entries := rules.list()
+		list := make([]adminRule, 0, len(entries))
+		for _, e := range entries {
+			list = append(list, adminRule{Id: e.id, Enabled: e.enabled, Type: fmt.Sprintf("%T", e.rule), Description: e.description})
+		}
+		writeAdminJson(w, http.StatusOK, list)
+	})
+
+	mux.HandleFunc("POST "+path, func(w http.ResponseWriter, r *http.Request) {
+		index := -1
+		if s := r.URL.Query().Get("index"); s != "" {
+			var err error
+			index, err = strconv.Atoi(s)
+			if err != nil {
+				writeAdminError(w, http.StatusBadRequest, fmt.Errorf("invalid index: %w", err))
+				return
+			}
+		}
+		description, rule, err := readRuleDescription[R, D](r)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		id := rules.add(rule, description, index)
+		logger.Info("Added a rule by the admin API", log.String("path", path), log.Int("id", id))
+		writeAdminJson(w, http.StatusCreated, map[string]int{"id": id})
+	})
+
+	mux.HandleFunc("PUT "+path+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := ruleId(w, r)
+		if !ok {
+			return
+		}
+		description, rule, err := readRuleDescription[R, D](r)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		respondRuleChange(w, path, id, "Replaced", rules.replace(id, rule, description))
+	})
+
+	mux.HandleFunc("DELETE "+path+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := ruleId(w, r); ok {
+			respondRuleChange(w, path, id, "Removed", rules.remove(id))
+		}
+	})
+
+	mux.HandleFunc("POST "+path+"/{id}/enable", func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := ruleId(w, r); ok {
+			respondRuleChange(w, path, id, "Enabled", rules.enable(id, true))
+		}
+	})
+
+	mux.HandleFunc("POST "+path+"/{id}/disable", func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := ruleId(w, r); ok {
+			respondRuleChange(w, path, id, "Disabled", rules.enable(id, false))
+		}
+	})
+}
+
+func readRuleDescription[R any, D ruleDescription[R]](r *http.Request) (D, R, error) {
+	var description D
+	var rule R
+	err := decodeAdminJson(r, &description)
+	if err != nil {
+		return description, rule, fmt.Errorf("invalid rule description: %w", err)
+	}
+	rule, err = description.rule()
+	if err != nil {
+		return description, rule, fmt.Errorf("invalid rule description: %w", err)
+	}
+	return description, rule, nil
+}
+
+// ruleId returns the id in the path of r, or responds with 400 Bad Request if it is not one
+func ruleId(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("invalid rule id: %w", err))
+		return 0, false
+	}
+	return id, true
+}
+
+func respondRuleChange(w http.ResponseWriter, path string, id int, change string, ok bool) {
+	if !ok {
+		writeAdminError(w, http.StatusNotFound, fmt.Errorf("no rule with id %d", id))
+		return
+	}
+	logger.Info(change+" a rule by the admin API", log.String("path", path), log.Int("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeAdminJson(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, statusCode int, err error) {
+	writeAdminJson(w, statusCode, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,237 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpRuleDescription_rule(t *testing.T) {
+	tests := []struct {
+		name               string
+		description        HttpRuleDescription
+		request            HttpRequest
+		expectedMatch      bool
+		expectedStatusCode int
+		expectedError      bool
+	}{
+		{"Match", HttpRuleDescription{Method: "POST", Path: "/order", StatusCode: 400, Body: "rejected"}, HttpRequest{Method: "POST", Path: "/order"}, true, 400, false},
+		{"No match", HttpRuleDescription{Method: "POST", Path: "/order"}, HttpRequest{Method: "GET", Path: "/order"}, false, 200, false},
+		{"Any", HttpRuleDescription{}, HttpRequest{Method: "GET", Path: "/time"}, true, 200, false},
+		{"Invalid response time", HttpRuleDescription{ResponseTime: "soon"}, HttpRequest{}, false, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := tt.description.rule()
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedMatch, rule.MatchRequest(tt.request))
+			response, err := rule.Response(tt.request)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatusCode, response.StatusCode)
+			assert.Equal(t, tt.description.Body, string(response.Body))
+		})
+	}
+}
+
+func TestWsRuleDescription_rule(t *testing.T) {
+	match := func(s string) *string { return &s }
+
+	tests := []struct {
+		name          string
+		description   WsRuleDescription
+		message       WsMessage
+		expectedMatch bool
+		expectedError bool
+	}{
+		{"Match", WsRuleDescription{Match: match("ping")}, WsMessage{Type: WsMessageText, Data: []byte("ping")}, true, false},
+		{"No match", WsRuleDescription{Match: match("ping")}, WsMessage{Type: WsMessageText, Data: []byte("pong")}, false, false},
+		{"Binary", WsRuleDescription{Match: match("0102"), Type: "binary"}, WsMessage{Type: WsMessageBinary, Data: []byte{1, 2}}, true, false},
+		{"Json", WsRuleDescription{MatchJson: json.RawMessage(`{"method":"order.place"}`)}, WsMessage{Type: WsMessageText, Data: []byte(`{ "method": "order.place" }`)}, true, false},
+		{"Any", WsRuleDescription{}, WsMessage{Type: WsMessageBinary, Data: []byte{1}}, true, false},
+		{"Both", WsRuleDescription{Match: match("ping"), MatchJson: json.RawMessage(`{}`)}, WsMessage{}, false, true},
+		{"Invalid type", WsRuleDescription{Type: "json"}, WsMessage{}, false, true},
+		{"Invalid hex", WsRuleDescription{Type: "binary", Response: "xyz"}, WsMessage{}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := tt.description.rule()
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedMatch, rule.MatchMessage(tt.message))
+		})
+	}
+}
+
+func TestSimulator_admin(t *testing.T) {
+	sim := New(Config{
+		HttpBasePath:  "/api",
+		AdminBasePath: "/admin",
+		HttpRules: []HttpRule{
+			NewHttpRule(NewHttpRequestPredicate("POST", "/order"), NewHttpResponseFromString(200, `{"orderId":1}`, 0)),
+		},
+	})
+	serve := func(method string, path string, body string) (int, string) {
+		w := httptest.NewRecorder()
+		sim.requestHandler(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w.Code, w.Body.String()
+	}
+
+	code, body := serve("POST", "/admin/rules/http?index=0", `{"method":"POST","path":"/order","status_code":400,"body":"{\"code\":-2010}"}`)
+	require.Equal(t, http.StatusCreated, code)
+	assert.JSONEq(t, `{"id":1}`, body)
+	code, body = serve("POST", "/api/order", "")
+	assert.Equal(t, 400, code)
+	assert.Equal(t, `{"code":-2010}`, body)
+
+	code, body = serve("GET", "/admin/rules/http", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `[
+		{"id":1,"enabled":true,"type":"http.RuleImpl","description":{"method":"POST","path":"/order","status_code":400,"body":"{\"code\":-2010}"}},
+		{"id":0,"enabled":true,"type":"http.RuleImpl","description":null}
+	]`, body)
+
+	code, _ = serve("POST", "/admin/rules/http/1/disable", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = serve("POST", "/api/order", "")
+	assert.Equal(t, 200, code)
+
+	code, _ = serve("PUT", "/admin/rules/http/0", `{"path":"/order","status_code":503}`)
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = serve("POST", "/api/order", "")
+	assert.Equal(t, 503, code)
+
+	code, _ = serve("DELETE", "/admin/rules/http/0", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = serve("POST", "/api/order", "")
+	assert.Equal(t, 404, code)
+
+	code, _ = serve("POST", "/admin/reset", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = serve("POST", "/api/order", "")
+	assert.Equal(t, 200, code)
+
+	// The ids of the rules removed by the reset are not given again
+	code, body = serve("POST", "/admin/rules/http", `{"path":"/time"}`)
+	require.Equal(t, http.StatusCreated, code)
+	assert.JSONEq(t, `{"id":2}`, body)
+}
+
+func TestSimulator_admin_Outage(t *testing.T) {
+	c := NewFakeClock(time.Date(2000, 1, 23, 10, 0, 0, 0, time.UTC))
+	sim := New(Config{
+		HttpBasePath:  "/api",
+		AdminBasePath: "/admin",
+		HttpRules: []HttpRule{
+			NewHttpRule(NewHttpRequestPredicate("POST", "/order"), NewHttpResponseFromString(200, `{"orderId":1}`, 0)),
+		},
+		Clock: c,
+	})
+	serve := func(method string, path string, body string) (int, string) {
+		w := httptest.NewRecorder()
+		sim.requestHandler(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w.Code, w.Body.String()
+	}
+
+	code, _ := serve("POST", "/admin/outage", `{"duration":"1m","scope":"http","status_code":502,"body":"{\"code\":-1001}"}`)
+	require.Equal(t, http.StatusNoContent, code)
+	code, body := serve("POST", "/api/order", "")
+	assert.Equal(t, 502, code)
+	assert.Equal(t, `{"code":-1001}`, body)
+
+	c.Advance(time.Minute)
+	code, _ = serve("POST", "/api/order", "")
+	assert.Equal(t, 200, code)
+
+	code, _ = serve("POST", "/admin/outage", `{"scope":"ws","close_code":1012}`)
+	require.Equal(t, http.StatusNoContent, code)
+	code, _ = serve("POST", "/api/order", "")
+	assert.Equal(t, 200, code)
+
+	code, _ = serve("POST", "/admin/outage", `{}`)
+	require.Equal(t, http.StatusNoContent, code)
+	code, body = serve("POST", "/api/order", "")
+	assert.Equal(t, 503, code)
+	assert.Equal(t, `{"error":"Service Unavailable"}`, body)
+
+	code, _ = serve("DELETE", "/admin/outage", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = serve("POST", "/api/order", "")
+	assert.Equal(t, 200, code)
+}
+
+func TestSimulator_admin_Errors(t *testing.T) {
+	sim := New(Config{AdminBasePath: "/admin"})
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		expectedCode int
+	}{
+		{"Unknown field", "POST", "/admin/rules/http", `{"status":400}`, http.StatusBadRequest},
+		{"Invalid description", "POST", "/admin/rules/ws", `{"type":"json"}`, http.StatusBadRequest},
+		{"Invalid index", "POST", "/admin/rules/ws?index=first", `{}`, http.StatusBadRequest},
+		{"Invalid id", "DELETE", "/admin/rules/ws/first", "", http.StatusBadRequest},
+		{"Unknown id", "POST", "/admin/rules/http/7/enable", "", http.StatusNotFound},
+		{"Unknown path", "GET", "/admin/rules", "", http.StatusNotFound},
+		{"Invalid outage duration", "POST", "/admin/outage", `{"duration":"soon"}`, http.StatusBadRequest},
+		{"Invalid outage scope", "POST", "/admin/outage", `{"scope":"fix"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			sim.requestHandler(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestSimulator_admin_Ws(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	sim := New(Config{
+		WsEndpoint:    "/ws",
+		AdminBasePath: "/admin",
+		WsRules: []WsRule{
+			NewWsRule(NewWsMessagePredicate(WsMessageText, []byte("ping")), NewWsMessageFromString(WsMessageText, "pong", 0)),
+		},
+	})
+	server := httptest.NewServer(http.HandlerFunc(sim.requestHandler))
+	defer server.Close()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.CloseNow()
+
+	response, err := exchange(t, ctx, conn, "ping")
+	require.NoError(t, err)
+	assert.Equal(t, "pong", response)
+
+	adminResponse, err := http.Post(server.URL+"/admin/rules/ws?index=0", "application/json", strings.NewReader(`{"match":"ping","response":"maintenance"}`))
+	require.NoError(t, err)
+	adminResponse.Body.Close()
+	require.Equal(t, http.StatusCreated, adminResponse.StatusCode)
+
+	response, err = exchange(t, ctx, conn, "ping")
+	require.NoError(t, err)
+	assert.Equal(t, "maintenance", response)
+}
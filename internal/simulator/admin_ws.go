@@ -0,0 +1,208 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"alphanonce.com/exchangesimulator/internal/log"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+)
+
+// adminWsMessage is a message the admin API pushes to WebSocket connections
+type adminWsMessage struct {
+	// Type is text or binary, whose data is hex-encoded; empty stands for text
+	Type string `json:"type,omitempty"`
+	Data string `json:"data"`
+}
+
+// adminWsClosure is how the admin API closes WebSocket connections; the zero value closes them with 1001, going away
+type adminWsClosure struct {
+	Code   int    `json:"code,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Reset  bool   `json:"reset,omitempty"`
+}
+
+// adminWsConnection is a WebSocket connection as the admin API lists it
+type adminWsConnection struct {
+	Id        uint64 `json:"id"`
+	Queued    int    `json:"queued"`
+	MaxQueued int    `json:"max_queued"`
+	Written   uint64 `json:"written"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+// handleWsConnections serves the part of the admin API that acts on the open WebSocket connections:
+//
+//	GET  {basePath}/ws/connections               lists the connections
+//	POST {basePath}/ws/connections/{id}/messages pushes a message to a connection
+//	POST {basePath}/ws/connections/{id}/close    closes a connection
+//	POST {basePath}/ws/messages                  pushes a message to every connection
+//	POST {basePath}/ws/topics/messages?topic=    pushes a message to every connection subscribed to a topic
+//	POST {basePath}/ws/close                     closes every connection
+//
+// The topic is a query parameter rather than a part of the path, as topics such as BTC/USD have slashes in them.
+// The messages are written after those queued before, and so is the closure.
+func (s Simulator) handleWsConnections(mux *http.ServeMux, basePath string) {
+	mux.HandleFunc("GET "+basePath+"/ws/connections", func(w http.ResponseWriter, r *http.Request) {
+		backlogs := s.WsBacklogs()
+		list := make([]adminWsConnection, 0, len(backlogs))
+		for _, b := range backlogs {
+			list = append(list, adminWsConnection{Id: b.ConnectionId, Queued: b.Queued, MaxQueued: b.MaxQueued, Written: b.Written, Dropped: b.Dropped})
+		}
+		writeAdminJson(w, http.StatusOK, list)
+	})
+
+	mux.HandleFunc("POST "+basePath+"/ws/connections/{id}/messages", func(w http.ResponseWriter, r *http.Request) {
+		q, ok := s.wsConnection(w, r)
+		if !ok {
+			return
+		}
+		message, err := readAdminWsMessage(r)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = q.Write(r.Context(), message)
+		if err != nil {
+			writeAdminError(w, http.StatusConflict, fmt.Errorf("failed to write to connection %s: %w", r.PathValue("id"), err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("POST "+basePath+"/ws/connections/{id}/close", func(w http.ResponseWriter, r *http.Request) {
+		q, ok := s.wsConnection(w, r)
+		if !ok {
+			return
+		}
+		closure, err := readAdminWsClosure(r)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = q.Close(closure)
+		if err != nil {
+			writeAdminError(w, http.StatusConflict, fmt.Errorf("failed to close connection %s: %w", r.PathValue("id"), err))
+			return
+		}
+		logger.Info("Closing a WebSocket connection by the admin API", log.String("connection_id", r.PathValue("id")))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("POST "+basePath+"/ws/messages", func(w http.ResponseWriter, r *http.Request) {
+		message, err := readAdminWsMessage(r)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		var conns []WsConnection
+		for _, q := range s.connections.all() {
+			conns = append(conns, q)
+		}
+		writeAdminJson(w, http.StatusOK, map[string]int{"connections": pushWsMessage(r.Context(), conns, message)})
+	})
+
+	mux.HandleFunc("POST "+basePath+"/ws/topics/messages", func(w http.ResponseWriter, r *http.Request) {
+		topic := r.URL.Query().Get("topic")
+		if topic == "" {
+			writeAdminError(w, http.StatusBadRequest, errors.New("no topic"))
+			return
+		}
+		message, err := readAdminWsMessage(r)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		var conns []WsConnection
+		for _, rule := range s.wsRules.rules() {
+			if subscribers, ok := ws.TopicSubscribersOf(rule); ok {
+				conns = append(conns, subscribers.Subscribers(topic)...)
+			}
+		}
+		writeAdminJson(w, http.StatusOK, map[string]int{"connections": pushWsMessage(r.Context(), conns, message)})
+	})
+
+	mux.HandleFunc("POST "+basePath+"/ws/close", func(w http.ResponseWriter, r *http.Request) {
+		closure, err := readAdminWsClosure(r)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		closed := 0
+		for _, q := range s.connections.all() {
+			if q.Close(closure) == nil {
+				closed++
+			}
+		}
+		logger.Info("Closing every WebSocket connection by the admin API", log.Int("connections", closed))
+		writeAdminJson(w, http.StatusOK, map[string]int{"connections": closed})
+	})
+}
+
+// wsConnection returns the connection with the id in the path of r, or responds with an error if there is none
+func (s Simulator) wsConnection(w http.ResponseWriter, r *http.Request) (*wsWriteQueue, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("invalid connection id: %w", err))
+		return nil, false
+	}
+	q, ok := s.connections.get(id)
+	if !ok {
+		writeAdminError(w, http.StatusNotFound, fmt.Errorf("no connection with id %d", id))
+		return nil, false
+	}
+	return q, true
+}
+
+// pushWsMessage writes message to every connection of conns, and returns to how many it was written
+func pushWsMessage(ctx context.Context, conns []WsConnection, message WsMessage) int {
+	written := 0
+	for _, conn := range conns {
+		err := conn.Write(ctx, message)
+		if err != nil {
+			logger.Info("Failed to push a message to a WebSocket connection", log.Any("error", err))
+			continue
+		}
+		written++
+	}
+	logger.Info("Pushed a message to WebSocket connections by the admin API", log.Int("connections", written))
+	return written
+}
+
+func readAdminWsMessage(r *http.Request) (WsMessage, error) {
+	var m adminWsMessage
+	err := decodeAdminJson(r, &m)
+	if err != nil {
+		return WsMessage{}, fmt.Errorf("invalid message: %w", err)
+	}
+	messageType, decode, err := wsMessageDecoder(m.Type)
+	if err != nil {
+		return WsMessage{}, fmt.Errorf("invalid message: %w", err)
+	}
+	data, err := decode(m.Data)
+	if err != nil {
+		return WsMessage{}, fmt.Errorf("invalid message: %w", err)
+	}
+	return WsMessage{Type: messageType, Data: data}, nil
+}
+
+// readAdminWsClosure reads the closure in the body of r, which may be empty
+func readAdminWsClosure(r *http.Request) (WsClosure, error) {
+	var c adminWsClosure
+	err := decodeAdminJson(r, &c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return WsClosure{}, fmt.Errorf("invalid closure: %w", err)
+	}
+	return WsClosure{Code: c.Code, Reason: c.Reason, Reset: c.Reset}, nil
+}
+
+func decodeAdminJson(r *http.Request, v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,128 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulator_admin_WsConnections(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	response := NewWsMessageFromString(WsMessageText, `{"result":null}`, 0)
+	sim := New(Config{
+		WsEndpoint:    "/ws",
+		AdminBasePath: "/admin",
+		WsRules: []WsRule{
+			NewWsRule(NewWsMessagePredicate(WsMessageText, []byte("ping")), NewWsMessageFromString(WsMessageText, "pong", 0)),
+			NewWsTopicSubscriptionRule(
+				wsMethodMatcher("SUBSCRIBE"), response,
+				wsMethodMatcher("UNSUBSCRIBE"), response,
+				NewWsJsonTopicExtractor("params"), nil,
+			),
+		},
+	})
+	server := httptest.NewServer(http.HandlerFunc(sim.requestHandler))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	admin := func(path string, body string) (int, string) {
+		w := httptest.NewRecorder()
+		sim.requestHandler(w, httptest.NewRequest("POST", "/admin"+path, strings.NewReader(body)))
+		return w.Code, w.Body.String()
+	}
+	read := func(conn *websocket.Conn) (websocket.MessageType, string) {
+		messageType, data, err := conn.Read(ctx)
+		require.NoError(t, err)
+		return messageType, string(data)
+	}
+
+	// The connections are opened one after another, so that they get the ids 1 and 2
+	conn1, _, err := websocket.Dial(ctx, url, nil)
+	require.NoError(t, err)
+	defer conn1.CloseNow()
+	_, err = exchange(t, ctx, conn1, `{"method":"SUBSCRIBE","params":["book/XBT/USD"]}`)
+	require.NoError(t, err)
+	conn2, _, err := websocket.Dial(ctx, url, nil)
+	require.NoError(t, err)
+	defer conn2.CloseNow()
+	_, err = exchange(t, ctx, conn2, "ping")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	sim.requestHandler(w, httptest.NewRequest("GET", "/admin/ws/connections", nil))
+	var connections []adminWsConnection
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &connections))
+	require.Len(t, connections, 2)
+	assert.Equal(t, []uint64{1, 2}, []uint64{connections[0].Id, connections[1].Id})
+
+	code, _ := admin("/ws/connections/1/messages", `{"data":"{\"e\":\"ORDER_TRADE_UPDATE\",\"o\":{\"o\":\"LIQUIDATION\"}}"}`)
+	assert.Equal(t, http.StatusNoContent, code)
+	messageType, data := read(conn1)
+	assert.Equal(t, websocket.MessageText, messageType)
+	assert.Equal(t, `{"e":"ORDER_TRADE_UPDATE","o":{"o":"LIQUIDATION"}}`, data)
+
+	code, body := admin("/ws/topics/messages?topic=book%2FXBT%2FUSD", `{"type":"binary","data":"0102"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"connections":1}`, body)
+	messageType, data = read(conn1)
+	assert.Equal(t, websocket.MessageBinary, messageType)
+	assert.Equal(t, "\x01\x02", data)
+
+	code, body = admin("/ws/messages", `{"data":"{malformed"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"connections":2}`, body)
+	_, data = read(conn1)
+	assert.Equal(t, "{malformed", data)
+	_, data = read(conn2)
+	assert.Equal(t, "{malformed", data)
+
+	code, _ = admin("/ws/connections/2/close", `{"code":4000,"reason":"forced"}`)
+	assert.Equal(t, http.StatusNoContent, code)
+	_, _, err = conn2.Read(ctx)
+	var closeErr websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.StatusCode(4000), closeErr.Code)
+	assert.Equal(t, "forced", closeErr.Reason)
+
+	code, _ = admin("/ws/close", "")
+	assert.Equal(t, http.StatusOK, code)
+	_, _, err = conn1.Read(ctx)
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.StatusGoingAway, closeErr.Code)
+}
+
+func TestSimulator_admin_WsConnections_Errors(t *testing.T) {
+	sim := New(Config{AdminBasePath: "/admin"})
+
+	tests := []struct {
+		name         string
+		path         string
+		body         string
+		expectedCode int
+	}{
+		{"Unknown connection", "/admin/ws/connections/7/messages", `{"data":"hello"}`, http.StatusNotFound},
+		{"Invalid connection id", "/admin/ws/connections/first/close", "", http.StatusBadRequest},
+		{"Invalid type", "/admin/ws/messages", `{"type":"json","data":"{}"}`, http.StatusBadRequest},
+		{"Invalid hex", "/admin/ws/topics/messages?topic=a", `{"type":"binary","data":"xyz"}`, http.StatusBadRequest},
+		{"No topic", "/admin/ws/topics/messages", `{"data":"hello"}`, http.StatusBadRequest},
+		{"Invalid closure", "/admin/ws/close", `{"code":"normal"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			sim.requestHandler(w, httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body)))
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
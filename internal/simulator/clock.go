@@ -0,0 +1,18 @@
+package simulator
+
+import (
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+)
+
+type Clock = clock.Clock
+type RealClock = clock.Real
+type FakeClock = clock.Fake
+
+// NewFakeClock returns a clock that starts at now and only moves when it is advanced.
+// For lockstep backtesting, run the simulator on it with Config.Clock,
+// and step it with NewWsStepHandler or NewHttpStepResponder.
+func NewFakeClock(now time.Time) *FakeClock {
+	return clock.NewFake(now)
+}
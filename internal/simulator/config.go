@@ -1,11 +1,20 @@
 package simulator
 
 import (
-	"slices"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
+)
+
+// Compressions of recorded files, as the extension appended to their names
+const (
+	CompressionNone = ""
+	CompressionGzip = fileio.GzipExtension
+	CompressionZstd = fileio.ZstdExtension
 )
 
 type Config struct {
-	// HttpBasePath and WsEndpoint must not be a prefix of each other
+	// HttpBasePath, WsEndpoint, AdminBasePath and MetricsPath must not be a prefix of each other
 	ServerAddress string
 	HttpBasePath  string
 	HttpRules     []HttpRule
@@ -13,22 +22,45 @@ type Config struct {
 	WsRules       []WsRule
 	WsRedirectUrl string
 	WsRecordDir   string
-}
-
-func (c *Config) GetHttpRule(request HttpRequest) (HttpRule, bool) {
-	i := slices.IndexFunc(c.HttpRules, func(r HttpRule) bool { return r.MatchRequest(request) })
-	if i == -1 {
-		return nil, false
-	}
-
-	return c.HttpRules[i], true
-}
-
-func (c *Config) GetWsRule(message WsMessage) (WsRule, bool) {
-	i := slices.IndexFunc(c.WsRules, func(r WsRule) bool { return r.MatchMessage(message) })
-	if i == -1 {
-		return nil, false
-	}
-
-	return c.WsRules[i], true
+	// WsRecordCompression is the extension the files of WsRecordDir are compressed by:
+	// CompressionNone, CompressionGzip or CompressionZstd
+	WsRecordCompression string
+	// WsRecordFile is a JSON Lines file redirected server messages are appended to, as an alternative to WsRecordDir.
+	// It is compressed if it ends with .gz or .zst.
+	WsRecordFile string
+	// WsSessionRecordPath is a JSON Lines file every connection's traffic in both directions is appended to.
+	// It is compressed if it ends with .gz or .zst.
+	WsSessionRecordPath string
+	// WsWriteBufferSize is how many messages may be queued to be written to a connection, or to be handled by a sequential rule;
+	// 0 stands for DefaultWsWriteBufferSize. Reading blocks while the buffer of a rule is full,
+	// and WsSlowConsumerPolicy tells what writing does while the buffer of a connection is full.
+	WsWriteBufferSize    int
+	WsSlowConsumerPolicy WsSlowConsumerPolicy
+	// WsWriteTimeout is how long writing a message to a connection may take before the connection is closed; 0 stands for no limit
+	WsWriteTimeout time.Duration
+	// WsOnConnect are run in order as a connection opens, before any message of the client is handled,
+	// e.g. to send a welcome message. They are handed an empty message.
+	WsOnConnect []WsMessageHandler
+	// WsPeriodic are run on every connection until it closes, e.g. to send heartbeats
+	WsPeriodic []WsPeriodicHandler
+	// WsPing sends ping frames to every connection and closes those that do not answer with a pong
+	WsPing WsPingPolicy
+	// WsLimits limit the messages, subscriptions and connections of the clients
+	WsLimits WsLimits
+	// WsFault closes every connection as it tells, to test how clients reconnect.
+	// The messages of a connection are counted from when it opens, and so is its time.
+	WsFault WsConnectionFault
+	// Outages take the exchange down as scheduled, e.g. for maintenance; Simulator.StartOutage takes it down at once
+	Outages []ScheduledOutage
+	// AdminBasePath is where the admin API is served, which changes the rules as the simulator runs
+	// and pushes messages to the connections; empty serves none
+	AdminBasePath string
+	// MetricsPath is where the metrics are served in the Prometheus text format, such as /metrics; empty serves none
+	MetricsPath string
+	// JournalSize is how many of the latest HTTP requests and WebSocket messages and connections the journal keeps;
+	// 0 stands for DefaultJournalSize, and a negative size keeps none
+	JournalSize int
+	// Clock runs the simulator: it stamps the recordings and times every rule that is not given its own clock with WithClock.
+	// nil stands for the wall clock.
+	Clock Clock
 }
@@ -0,0 +1,119 @@
+package simulator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/har"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/http"
+)
+
+type Har = har.Har
+type HarEntry = har.Entry
+
+func NewHar() Har {
+	return har.New()
+}
+
+func ReadHar(path string) (Har, error) {
+	return har.ReadFromFile(path)
+}
+
+func WriteHar(path string, h Har) error {
+	return har.WriteToFile(path, h)
+}
+
+func DecodeHar(r io.Reader) (Har, error) {
+	return har.Decode(r)
+}
+
+// Import
+
+func NewHttpRulesFromHar(h Har) ([]HttpRule, error) {
+	return har.HttpRules(h)
+}
+
+func WriteHarRecords(h Har, dir string) error {
+	return har.WriteRecords(h, dir)
+}
+
+// Export
+
+func NewHarEntriesFromHttpRecords(method string, url string, dir string) ([]HarEntry, error) {
+	return har.FromHttpRecords(method, url, dir)
+}
+
+func NewHarEntryFromWsRecords(url string, dir string) (HarEntry, error) {
+	return har.FromWsRecords(url, dir)
+}
+
+// JournalHar returns the entries of the journal that match query as a HAR, as if the simulator was reached at baseUrl,
+// e.g. http://localhost:8080: an entry for every HTTP request, and one for every WebSocket connection with the messages
+// read from and written to it. The journal keeps neither headers nor response bodies, so the responses tell only their status.
+func (s Simulator) JournalHar(query JournalQuery, baseUrl string) (Har, error) {
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		return Har{}, fmt.Errorf("invalid base URL: %w", err)
+	}
+	wsBase := *base
+	wsBase.Scheme = "ws"
+	if base.Scheme == "https" {
+		wsBase.Scheme = "wss"
+	}
+
+	h := har.New()
+	// connections are the indices in h of the entries of the WebSocket connections
+	connections := map[uint64]int{}
+	for _, e := range s.Journal(query) {
+		switch e.Kind {
+		case JournalHttp:
+			u := base.JoinPath(s.config.HttpBasePath, e.Path)
+			u.RawQuery = e.QueryString
+			entry, err := har.FromHttpRequest(e.Method, u.String(), e.Time, e.Body, http.Response{StatusCode: e.StatusCode})
+			if err != nil {
+				return Har{}, err
+			}
+			h.Log.Entries = append(h.Log.Entries, entry)
+		case JournalWsOpen, JournalWsInbound, JournalWsOutbound:
+			i, ok := connections[e.ConnectionId]
+			if !ok {
+				entry, err := har.NewWsEntry(wsBase.JoinPath(s.config.WsEndpoint).String(), e.Time)
+				if err != nil {
+					return Har{}, err
+				}
+				i = len(h.Log.Entries)
+				connections[e.ConnectionId] = i
+				h.Log.Entries = append(h.Log.Entries, entry)
+			}
+			if e.Kind == JournalWsOpen {
+				continue
+			}
+
+			message, err := journaledMessage(e)
+			if err != nil {
+				return Har{}, err
+			}
+			messageType := har.WebSocketSend
+			if e.Kind == JournalWsOutbound {
+				messageType = har.WebSocketReceive
+			}
+			h.Log.Entries[i].WebSocketMessages = append(h.Log.Entries[i].WebSocketMessages, har.NewWebSocketMessage(messageType, e.Time, message))
+		}
+	}
+	return h, nil
+}
+
+// journaledMessage returns the message of a journal entry
+func journaledMessage(e JournalEntry) (WsMessage, error) {
+	if e.Type != "binary" {
+		return WsMessage{Type: WsMessageText, Data: []byte(e.Body)}, nil
+	}
+
+	data, err := hex.DecodeString(e.Body)
+	if err != nil {
+		return WsMessage{}, err
+	}
+	return WsMessage{Type: WsMessageBinary, Data: data}, nil
+}
@@ -0,0 +1,63 @@
+package simulator
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulator_httpRequestHandler_Fault(t *testing.T) {
+	body := `{"status":"ok"}`
+	tests := []struct {
+		name         string
+		fault        HttpFault
+		expectedBody string
+		expectedErr  bool
+		minDuration  time.Duration
+	}{
+		{
+			name:         "Trickle",
+			fault:        HttpFault{TrickleRate: 1, TrickleChunkSize: 4, TrickleInterval: 10 * time.Millisecond},
+			expectedBody: body,
+			minDuration:  30 * time.Millisecond,
+		},
+		{
+			name:        "Reset",
+			fault:       HttpFault{ResetRate: 1, Seed: 1},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := New(Config{
+				HttpBasePath: "/api",
+				HttpRules: []HttpRule{
+					NewHttpRule(NewHttpRequestPredicate("GET", "/test"), NewHttpFaultResponder(NewHttpResponseFromString(200, body, 0), tt.fault)),
+				},
+			})
+			server := httptest.NewServer(http.HandlerFunc(sim.requestHandler))
+			defer server.Close()
+
+			start := time.Now()
+			response, err := http.Get(server.URL + "/api/test")
+			require.NoError(t, err)
+			defer response.Body.Close()
+			assert.Equal(t, 200, response.StatusCode)
+			data, err := io.ReadAll(response.Body)
+
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedBody, string(data))
+			assert.GreaterOrEqual(t, time.Since(start), tt.minDuration)
+		})
+	}
+}
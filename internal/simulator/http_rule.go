@@ -9,6 +9,17 @@ import (
 type HttpRule = http.Rule
 type HttpRequest = http.Request
 type HttpResponse = http.Response
+type HttpResponder = http.Responder
+type HttpDelivery = http.Delivery
+type HttpFault = http.Fault
+type HttpLatency = http.Latency
+type HttpUniformLatency = http.UniformLatency
+type HttpNormalLatency = http.NormalLatency
+type HttpHistogramLatency = http.HistogramLatency
+type HttpLatencyBucket = http.LatencyBucket
+type HttpRateLimit = http.RateLimit
+type HttpRateLimiter = http.RateLimiter
+type HttpClientKey = http.ClientKey
 
 func NewHttpRule(requestMatcher http.RequestMatcher, responder http.Responder) http.RuleImpl {
 	return http.NewRule(requestMatcher, responder)
@@ -26,6 +37,35 @@ func NewHttpResponseFromFile(filePath string, responseTime time.Duration) http.R
 	return http.NewResponseFromFile(filePath, responseTime)
 }
 
+func NewHttpResponseFromFiles(dirPath string) http.ResponseFromFiles {
+	return http.NewResponseFromFiles(dirPath)
+}
+
+func NewHttpStepResponder(timeline *FakeClock, timeout time.Duration) http.StepResponder {
+	return http.NewStepResponder(timeline, timeout)
+}
+
+// NewHttpFaultResponder returns responder slowed down and failing as fault tells
+func NewHttpFaultResponder(responder http.Responder, fault HttpFault) http.FaultResponder {
+	return http.NewFaultResponder(responder, fault)
+}
+
+// NewHttpRateLimiter returns a rate limiter keyed by IP address; weigh the requests with its Limit method.
+// It panics if the window of a limit is not positive.
+func NewHttpRateLimiter(limits ...HttpRateLimit) HttpRateLimiter {
+	return http.NewRateLimiter(limits...)
+}
+
+// HttpClientIp keys the clients of a rate limiter by their IP address
+func HttpClientIp(request HttpRequest) string {
+	return http.ClientIp(request)
+}
+
+// HttpClientHeader keys the clients of a rate limiter by a request header such as X-MBX-APIKEY
+func HttpClientHeader(name string) HttpClientKey {
+	return http.ClientHeader(name)
+}
+
 func NewHttpRedirectResponder(targetUrl string, recordDir string) http.RedirectResponder {
 	return http.NewRedirectResponder(targetUrl, recordDir)
 }
@@ -0,0 +1,89 @@
+package clock
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the time and waits for it, so that the time the simulator runs on can be faked in tests
+type Clock interface {
+	Now() time.Time
+	// SleepUntil waits until t or until ctx is done, and returns the cause of ctx in the latter case
+	SleepUntil(ctx context.Context, t time.Time) error
+}
+
+// Sleep waits for d on c or until ctx is done
+func Sleep(ctx context.Context, c Clock, d time.Duration) error {
+	return c.SleepUntil(ctx, c.Now().Add(d))
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries c, the clock of whatever runs with ctx
+func NewContext(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the clock carried by ctx, or the wall clock if there is none
+func FromContext(ctx context.Context) Clock {
+	if c, ok := ctx.Value(contextKey{}).(Clock); ok {
+		return c
+	}
+	return Real{}
+}
+
+// Resolve returns c, or the clock carried by ctx if c is nil
+func Resolve(ctx context.Context, c Clock) Clock {
+	if c != nil {
+		return c
+	}
+	return FromContext(ctx)
+}
+
+// Attach tells c that the calling goroutine runs on the returned clock until detach is called.
+// A stepped Fake waits for the attached goroutines to sleep before it moves. Other clocks ignore it.
+func Attach(c Clock) (Clock, func()) {
+	fake, ok := c.(*Fake)
+	if !ok {
+		return c, func() {}
+	}
+
+	return attachedFake{fake}, fake.attach()
+}
+
+// Background returns c for a goroutine that waits on it in the background of what the clock runs, e.g. until an outage
+// or the next ping, so that a stepped Fake does not move to its wake-up times. Other clocks are returned as they are.
+func Background(c Clock) Clock {
+	fake, ok := c.(*Fake)
+	if !ok {
+		return c
+	}
+
+	return backgroundFake{fake}
+}
+
+// Ensure Real implements Clock
+var _ Clock = Real{}
+
+// Real is the wall clock
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) SleepUntil(ctx context.Context, t time.Time) error {
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,70 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReal_SleepUntil(t *testing.T) {
+	c := Real{}
+
+	start := time.Now()
+	err := c.SleepUntil(context.Background(), start.Add(10*time.Millisecond))
+	duration := time.Since(start)
+
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, duration, 10*time.Millisecond)
+	assert.Less(t, duration, 20*time.Millisecond)
+}
+
+func TestReal_SleepUntil_ContextCancellation(t *testing.T) {
+	c := Real{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := c.SleepUntil(ctx, start.Add(time.Hour))
+	duration := time.Since(start)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, duration, 20*time.Millisecond)
+}
+
+func TestSleep(t *testing.T) {
+	now := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	c := NewFake(now)
+
+	done := make(chan error)
+	go func() {
+		done <- Sleep(context.Background(), c, time.Minute)
+	}()
+
+	assert.NoError(t, c.WaitForSleepers(context.Background(), 1))
+	c.Advance(time.Minute)
+	assert.NoError(t, <-done)
+}
+
+func TestResolve(t *testing.T) {
+	fake := NewFake(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC))
+	other := NewFake(time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		clock    Clock
+		expected Clock
+	}{
+		{"Neither", context.Background(), nil, Real{}},
+		{"Context", NewContext(context.Background(), fake), nil, fake},
+		{"Given", NewContext(context.Background(), fake), other, other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Resolve(tt.ctx, tt.clock))
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package clock
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Ensure Fake implements Clock
+var _ Clock = (*Fake)(nil)
+
+// Fake is a clock that only moves when it is told to.
+// Sleeping on it blocks until the clock is advanced past the wake-up time.
+//
+// Stepping a fake clock runs it in lockstep: every goroutine attached to it with Attach gets to sleep
+// before the clock moves to the next wake-up time, so that the goroutines see the same sequence of events
+// however long the steps are apart.
+//
+// Sleeping on a clock made with Background, e.g. until a scheduled outage or the next ping, is woken up as the clock moves,
+// but neither AdvanceToNext nor Step moves the clock to it.
+type Fake struct {
+	lock     sync.Mutex
+	now      time.Time
+	sleepers []*sleeper
+	// attached is the number of goroutines attached with Attach
+	attached int
+	// changed is closed and replaced whenever a sleeper is added or a goroutine is detached
+	changed chan struct{}
+}
+
+type sleeper struct {
+	until time.Time
+	kind  sleeperKind
+	wake  chan struct{}
+}
+
+// sleeperKind tells how a sleeper takes part in stepping the clock
+type sleeperKind int
+
+const (
+	sleeperPlain sleeperKind = iota
+	// sleeperAttached is a goroutine attached with Attach, which Step waits for
+	sleeperAttached
+	// sleeperBackground is made with Background, which AdvanceToNext and Step do not move the clock to
+	sleeperBackground
+)
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{
+		now:     now,
+		changed: make(chan struct{}),
+	}
+}
+
+func (c *Fake) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+func (c *Fake) SleepUntil(ctx context.Context, t time.Time) error {
+	return c.sleepUntil(ctx, t, sleeperPlain)
+}
+
+func (c *Fake) sleepUntil(ctx context.Context, t time.Time, kind sleeperKind) error {
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	if !t.After(c.now) {
+		c.lock.Unlock()
+		return nil
+	}
+	s := &sleeper{until: t, kind: kind, wake: make(chan struct{})}
+	c.sleepers = append(c.sleepers, s)
+	c.notify()
+	c.lock.Unlock()
+
+	select {
+	case <-ctx.Done():
+		c.lock.Lock()
+		c.sleepers = slices.DeleteFunc(c.sleepers, func(other *sleeper) bool { return other == s })
+		c.lock.Unlock()
+		return context.Cause(ctx)
+	case <-s.wake:
+		return nil
+	}
+}
+
+// notify wakes up the goroutines waiting for a change. c.lock must be held.
+func (c *Fake) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Set moves the clock to t and wakes every sleeper whose time has come
+func (c *Fake) Set(t time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = t
+	c.sleepers = slices.DeleteFunc(c.sleepers, func(s *sleeper) bool {
+		if s.until.After(t) {
+			return false
+		}
+		close(s.wake)
+		return true
+	})
+}
+
+// Advance moves the clock forward by d
+func (c *Fake) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// AdvanceToNext moves the clock to the earliest time a sleeper waits for, and tells whether there was any.
+// Background sleepers are left out, but woken up if their time comes too.
+func (c *Fake) AdvanceToNext() bool {
+	c.lock.Lock()
+	var next time.Time
+	found := false
+	for _, s := range c.sleepers {
+		if s.kind != sleeperBackground && (!found || s.until.Before(next)) {
+			next = s.until
+			found = true
+		}
+	}
+	c.lock.Unlock()
+
+	if !found {
+		return false
+	}
+	c.Set(next)
+	return true
+}
+
+// Sleepers returns how many goroutines are sleeping on the clock
+func (c *Fake) Sleepers() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.sleepers)
+}
+
+// WaitForSleepers blocks until at least n goroutines sleep on the clock or until ctx is done,
+// so that the clock is advanced only once they are waiting for it
+func (c *Fake) WaitForSleepers(ctx context.Context, n int) error {
+	for {
+		c.lock.Lock()
+		count := len(c.sleepers)
+		changed := c.changed
+		c.lock.Unlock()
+
+		if count >= n {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-changed:
+		}
+	}
+}
+
+// attachedFake is a fake clock as seen by a goroutine attached to it
+type attachedFake struct {
+	*Fake
+}
+
+func (c attachedFake) SleepUntil(ctx context.Context, t time.Time) error {
+	return c.sleepUntil(ctx, t, sleeperAttached)
+}
+
+// backgroundFake is a fake clock as seen by a goroutine that waits in the background of it
+type backgroundFake struct {
+	*Fake
+}
+
+func (c backgroundFake) SleepUntil(ctx context.Context, t time.Time) error {
+	return c.sleepUntil(ctx, t, sleeperBackground)
+}
+
+func (c *Fake) attach() func() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.attached++
+	return sync.OnceFunc(func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		c.attached--
+		c.notify()
+	})
+}
+
+// Step waits until every attached goroutine sleeps on the clock, and then moves the clock to the earliest wake-up time.
+// It tells whether there was any sleeper to wake up.
+func (c *Fake) Step(ctx context.Context) (bool, error) {
+	for {
+		c.lock.Lock()
+		sleeping := 0
+		for _, s := range c.sleepers {
+			if s.kind == sleeperAttached {
+				sleeping++
+			}
+		}
+		if sleeping >= c.attached {
+			c.lock.Unlock()
+			return c.AdvanceToNext(), nil
+		}
+		changed := c.changed
+		c.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false, context.Cause(ctx)
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake_Now(t *testing.T) {
+	now := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	c := NewFake(now)
+
+	assert.Equal(t, now, c.Now())
+
+	c.Advance(time.Second)
+	assert.Equal(t, now.Add(time.Second), c.Now())
+
+	c.Set(now)
+	assert.Equal(t, now, c.Now())
+}
+
+func TestFake_SleepUntil(t *testing.T) {
+	now := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		until     time.Time
+		advance   time.Duration
+		expectErr bool
+	}{
+		{
+			name:    "Past",
+			until:   now.Add(-time.Second),
+			advance: 0,
+		},
+		{
+			name:    "Now",
+			until:   now,
+			advance: 0,
+		},
+		{
+			name:    "Future",
+			until:   now.Add(time.Hour),
+			advance: time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewFake(now)
+
+			done := make(chan error)
+			go func() {
+				done <- c.SleepUntil(context.Background(), tt.until)
+			}()
+
+			if tt.advance > 0 {
+				require.NoError(t, c.WaitForSleepers(context.Background(), 1))
+				c.Advance(tt.advance - time.Nanosecond)
+				select {
+				case <-done:
+					t.Fatal("woke up too early")
+				case <-time.After(time.Millisecond):
+				}
+				c.Advance(time.Nanosecond)
+			}
+
+			assert.NoError(t, <-done)
+			assert.Equal(t, 0, c.Sleepers())
+		})
+	}
+}
+
+func TestFake_SleepUntil_ContextCancellation(t *testing.T) {
+	now := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	c := NewFake(now)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- c.SleepUntil(ctx, now.Add(time.Hour))
+	}()
+
+	require.NoError(t, c.WaitForSleepers(context.Background(), 1))
+	cancel()
+
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Equal(t, 0, c.Sleepers())
+}
+
+func TestFake_AdvanceToNext(t *testing.T) {
+	now := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	c := NewFake(now)
+
+	assert.False(t, c.AdvanceToNext())
+
+	done := make(chan time.Duration, 2)
+	for _, d := range []time.Duration{2 * time.Second, time.Second} {
+		go func() {
+			_ = Sleep(context.Background(), c, d)
+			done <- d
+		}()
+	}
+	require.NoError(t, c.WaitForSleepers(context.Background(), 2))
+
+	assert.True(t, c.AdvanceToNext())
+	assert.Equal(t, time.Second, <-done)
+	assert.Equal(t, now.Add(time.Second), c.Now())
+
+	assert.True(t, c.AdvanceToNext())
+	assert.Equal(t, 2*time.Second, <-done)
+	assert.Equal(t, now.Add(2*time.Second), c.Now())
+}
+
+func TestFake_Step_Background(t *testing.T) {
+	now := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	c := NewFake(now)
+
+	// A background sleeper waking up before the replay, e.g. an outage, does not take its step
+	background := make(chan error, 1)
+	go func() { background <- Sleep(context.Background(), Background(c), time.Second) }()
+	attached, detach := Attach(c)
+	defer detach()
+	replay := make(chan error, 1)
+	go func() { replay <- Sleep(context.Background(), attached, 2*time.Second) }()
+	require.NoError(t, c.WaitForSleepers(context.Background(), 2))
+
+	ok, err := c.Step(context.Background())
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(2*time.Second), c.Now())
+	assert.NoError(t, <-replay)
+	// It is woken up all the same once its time has passed
+	assert.NoError(t, <-background)
+
+	go func() { background <- Sleep(context.Background(), Background(c), time.Second) }()
+	require.NoError(t, c.WaitForSleepers(context.Background(), 1))
+	assert.False(t, c.AdvanceToNext())
+	assert.Equal(t, now.Add(2*time.Second), c.Now())
+	c.Advance(time.Second)
+	assert.NoError(t, <-background)
+}
+
+func TestFake_WaitForSleepers_ContextCancellation(t *testing.T) {
+	c := NewFake(time.Time{})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	err := c.WaitForSleepers(ctx, 1)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestFake_Step(t *testing.T) {
+	now := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	c := NewFake(now)
+
+	// Two attached goroutines sleeping in turns, 3 and 2 seconds apart
+	events := make(chan string, 10)
+	for _, tt := range []struct {
+		name   string
+		period time.Duration
+	}{{"a", 3 * time.Second}, {"b", 2 * time.Second}} {
+		attached, detach := Attach(c)
+		go func() {
+			defer detach()
+			for range 2 {
+				_ = Sleep(context.Background(), attached, tt.period)
+				// Take a while to handle the event, which a step must wait for
+				time.Sleep(time.Millisecond)
+				events <- tt.name
+			}
+		}()
+	}
+
+	var stepped []time.Duration
+	for {
+		ok, err := c.Step(context.Background())
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+		stepped = append(stepped, c.Now().Sub(now))
+	}
+	close(events)
+
+	var names []string
+	for name := range events {
+		names = append(names, name)
+	}
+	assert.Equal(t, []time.Duration{2 * time.Second, 3 * time.Second, 4 * time.Second, 6 * time.Second}, stepped)
+	assert.Equal(t, []string{"b", "a", "b", "a"}, names)
+}
+
+func TestFake_Step_ContextCancellation(t *testing.T) {
+	c := NewFake(time.Time{})
+	_, detach := Attach(c)
+	defer detach()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	ok, err := c.Step(ctx)
+
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,67 @@
+package fileio
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+var archiveExtensions = []string{".tar", ".tar" + GzipExtension, ".tgz", ".tar" + ZstdExtension}
+
+// IsArchive tells whether path names a tar archive, optionally compressed
+func IsArchive(path string) bool {
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchiveReader reads the regular files of a tar archive in the order they were archived,
+// without loading the archive in memory
+type ArchiveReader struct {
+	file   io.ReadCloser
+	reader *tar.Reader
+}
+
+func OpenArchive(fsys fs.FS, name string) (*ArchiveReader, error) {
+	// A .tgz archive is gzip-compressed like a .tar.gz one
+	compressedName, ok := strings.CutSuffix(name, ".tgz")
+	if ok {
+		compressedName += ".tar" + GzipExtension
+	} else {
+		compressedName = name
+	}
+
+	f, err := openAs(fsys, name, compressedName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ArchiveReader{file: f, reader: tar.NewReader(f)}, nil
+}
+
+// Next advances to the next regular file and returns its name and a reader of its content,
+// which is valid until the next call. It returns io.EOF after the last file.
+func (a *ArchiveReader) Next() (string, io.Reader, error) {
+	for {
+		header, err := a.reader.Next()
+		if errors.Is(err, io.EOF) {
+			return "", nil, io.EOF
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			return header.Name, a.reader, nil
+		}
+	}
+}
+
+func (a *ArchiveReader) Close() error {
+	return a.file.Close()
+}
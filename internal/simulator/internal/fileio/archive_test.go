@@ -0,0 +1,76 @@
+package fileio
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeArchive(t *testing.T, path string, files map[string]string, names []string) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for _, name := range names {
+		content := files[name]
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	// WriteFile compresses by extension, so a .tgz archive is written as .tar.gz first
+	writePath := path
+	if filepath.Ext(path) == ".tgz" {
+		writePath = path + ".tar.gz"
+	}
+	require.NoError(t, WriteFile(writePath, buf.Bytes()))
+	require.NoError(t, os.Rename(writePath, path))
+}
+
+func TestIsArchive(t *testing.T) {
+	assert.True(t, IsArchive("data.tar"))
+	assert.True(t, IsArchive("data.tar.gz"))
+	assert.True(t, IsArchive("data.tgz"))
+	assert.True(t, IsArchive("data.tar.zst"))
+	assert.False(t, IsArchive("data"))
+	assert.False(t, IsArchive("data.jsonl.gz"))
+}
+
+func TestArchiveReader(t *testing.T) {
+	files := map[string]string{
+		"dir/a.yaml": "content a",
+		"dir/b.yaml": "content b",
+	}
+	names := []string{"dir/a.yaml", "dir/b.yaml"}
+
+	for _, filename := range []string{"data.tar", "data.tar.gz", "data.tgz", "data.tar.zst"} {
+		t.Run(filename, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), filename)
+			writeArchive(t, path, files, names)
+
+			a, err := OpenArchive(OS, path)
+			require.NoError(t, err)
+			defer a.Close()
+
+			for _, expectedName := range names {
+				name, r, err := a.Next()
+				require.NoError(t, err)
+				assert.Equal(t, expectedName, name)
+
+				content, err := io.ReadAll(r)
+				require.NoError(t, err)
+				assert.Equal(t, files[expectedName], string(content))
+			}
+
+			_, _, err = a.Next()
+			assert.True(t, errors.Is(err, io.EOF))
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package fileio
+
+import (
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Files are compressed according to their extension
+const (
+	GzipExtension = ".gz"
+	ZstdExtension = ".zst"
+)
+
+// TrimExtension removes a compression extension from name
+func TrimExtension(name string) string {
+	if n, ok := strings.CutSuffix(name, GzipExtension); ok {
+		return n
+	}
+	if n, ok := strings.CutSuffix(name, ZstdExtension); ok {
+		return n
+	}
+	return name
+}
+
+// OS is the file system of the operating system.
+// Unlike os.DirFS, it accepts absolute paths and paths relative to the working directory.
+var OS fs.FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+// Open opens a file of fsys for reading, decompressing it if it has a compression extension
+func Open(fsys fs.FS, name string) (io.ReadCloser, error) {
+	return openAs(fsys, name, name)
+}
+
+// openAs opens a file of fsys for reading, decompressing it according to the extension of compressedName
+func openAs(fsys fs.FS, name string, compressedName string) (io.ReadCloser, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newDecompressor(compressedName, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// ReadFile reads a whole file of fsys, decompressing it if it has a compression extension
+func ReadFile(fsys fs.FS, name string) ([]byte, error) {
+	r, err := Open(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// WriteFile writes data to a file, compressing it if it has a compression extension
+func WriteFile(path string, data []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := newCompressor(path, f)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// Decompress returns a reader of the decompressed content of r, according to the compression extension of name
+func Decompress(name string, r io.Reader) (io.ReadCloser, error) {
+	return newDecompressor(name, io.NopCloser(r))
+}
+
+func newDecompressor(name string, r io.ReadCloser) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(name, GzipExtension):
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return readCloser{Reader: gr, closers: []func() error{gr.Close, r.Close}}, nil
+	case strings.HasSuffix(name, ZstdExtension):
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return readCloser{Reader: zr, closers: []func() error{func() error { zr.Close(); return nil }, r.Close}}, nil
+	default:
+		return r, nil
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	closers []func() error
+}
+
+func (r readCloser) Close() error {
+	var firstErr error
+	for _, c := range r.closers {
+		if err := c(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,111 @@
+package fileio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrimExtension(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"message.yaml", "message.yaml"},
+		{"message.yaml.gz", "message.yaml"},
+		{"records.jsonl.zst", "records.jsonl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, TrimExtension(tt.name))
+		})
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"method":"depth_update"}`), 100)
+
+	tests := []struct {
+		name         string
+		filename     string
+		isCompressed bool
+	}{
+		{"Plain", "data.yaml", false},
+		{"Gzip", "data.yaml.gz", true},
+		{"Zstd", "data.yaml.zst", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.filename)
+
+			err := WriteFile(path, data)
+			require.NoError(t, err)
+
+			raw, err := os.ReadFile(path)
+			require.NoError(t, err)
+			if tt.isCompressed {
+				assert.Less(t, len(raw), len(data))
+			} else {
+				assert.Equal(t, data, raw)
+			}
+
+			read, err := ReadFile(OS, path)
+			require.NoError(t, err)
+			assert.Equal(t, data, read)
+		})
+	}
+}
+
+func TestReadFile_FS(t *testing.T) {
+	data := []byte(`{"method":"depth_update"}`)
+	path := filepath.Join(t.TempDir(), "data.yaml.gz")
+	require.NoError(t, WriteFile(path, data))
+	compressed, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"fixtures/data.yaml":    {Data: data},
+		"fixtures/data.yaml.gz": {Data: compressed},
+	}
+
+	for _, name := range []string{"fixtures/data.yaml", "fixtures/data.yaml.gz"} {
+		t.Run(name, func(t *testing.T) {
+			read, err := ReadFile(fsys, name)
+			require.NoError(t, err)
+			assert.Equal(t, data, read)
+		})
+	}
+}
+
+func TestOpen_Error(t *testing.T) {
+	_, err := Open(OS, "/non/existent/path.gz")
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "invalid.gz")
+	require.NoError(t, os.WriteFile(path, []byte("not gzip"), 0644))
+	_, err = Open(OS, path)
+	assert.Error(t, err)
+}
+
+func TestDecompress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.gz")
+	require.NoError(t, WriteFile(path, []byte("data")))
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	r, err := Decompress("data.gz", bytes.NewReader(raw))
+	require.NoError(t, err)
+	defer r.Close()
+
+	buf := new(bytes.Buffer)
+	_, err = buf.ReadFrom(r)
+	require.NoError(t, err)
+	assert.Equal(t, "data", buf.String())
+}
@@ -0,0 +1,102 @@
+package fileio
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Writer writes to a file, compressing if it has a compression extension.
+// Flush makes everything written so far readable without closing the writer. A compressed file is flushed by ending
+// its gzip member or zstd frame, so that what was flushed is read whole even if the writer is never closed,
+// e.g. as the process is killed.
+type Writer interface {
+	io.Writer
+	Flush() error
+	Close() error
+}
+
+// OpenAppend opens a file for appending, creating it if needed.
+// A compressed file is appended a new gzip member or zstd frame, which readers decompress as one stream.
+func OpenAppend(path string) (Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := newCompressor(path, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+type compressor interface {
+	io.Writer
+	Close() error
+	Reset(w io.Writer)
+}
+
+func newCompressor(name string, f *os.File) (Writer, error) {
+	switch {
+	case strings.HasSuffix(name, GzipExtension):
+		return &compressedWriter{compressor: gzip.NewWriter(f), file: f}, nil
+	case strings.HasSuffix(name, ZstdExtension):
+		zw, err := zstd.NewWriter(f)
+		if err != nil {
+			return nil, err
+		}
+		return &compressedWriter{compressor: zw, file: f}, nil
+	default:
+		return plainWriter{File: f}, nil
+	}
+}
+
+// compressedWriter writes a gzip member or zstd frame from the first write after it is opened or flushed to the next flush
+type compressedWriter struct {
+	compressor compressor
+	file       *os.File
+	// written tells whether anything was written since the writer was opened or flushed
+	written bool
+}
+
+func (w *compressedWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.compressor.Write(p)
+}
+
+// Flush ends the gzip member or zstd frame written to, and starts a new one for the next write
+func (w *compressedWriter) Flush() error {
+	if !w.written {
+		return nil
+	}
+
+	err := w.compressor.Close()
+	if err != nil {
+		return err
+	}
+	w.compressor.Reset(w.file)
+	w.written = false
+	return nil
+}
+
+func (w *compressedWriter) Close() error {
+	err := w.Flush()
+	if err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+type plainWriter struct {
+	*os.File
+}
+
+func (w plainWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,57 @@
+package fileio
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAppend(t *testing.T) {
+	for _, filename := range []string{"records.jsonl", "records.jsonl.gz", "records.jsonl.zst"} {
+		t.Run(filename, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), filename)
+
+			w, err := OpenAppend(path)
+			require.NoError(t, err)
+			_, err = w.Write([]byte("line1\n"))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			w, err = OpenAppend(path)
+			require.NoError(t, err)
+			_, err = w.Write([]byte("line2\n"))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			data, err := ReadFile(OS, path)
+			require.NoError(t, err)
+			assert.Equal(t, "line1\nline2\n", string(data))
+		})
+	}
+}
+
+func TestOpenAppend_Flush(t *testing.T) {
+	for _, filename := range []string{"records.jsonl", "records.jsonl.gz", "records.jsonl.zst"} {
+		t.Run(filename, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), filename)
+
+			w, err := OpenAppend(path)
+			require.NoError(t, err)
+			defer w.Close()
+			_, err = w.Write([]byte("line1\n"))
+			require.NoError(t, err)
+			require.NoError(t, w.Flush())
+
+			_, err = w.Write([]byte("line2\n"))
+			require.NoError(t, err)
+			require.NoError(t, w.Flush())
+
+			// What was flushed is read whole, though the writer is not closed
+			data, err := ReadFile(OS, path)
+			require.NoError(t, err)
+			assert.Equal(t, "line1\nline2\n", string(data))
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package har
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/http"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+)
+
+// FromHttpRecords returns an entry for every response recorded in dir, e.g. by RedirectResponder.
+// Recordings hold no request, so every entry is attributed to a request with the given method and URL.
+func FromHttpRecords(method string, requestUrl string, dir string) ([]Entry, error) {
+	files, times, err := listRecords(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(files))
+	for i, f := range files {
+		response, err := http.ReadFromFile(filepath.Join(dir, f))
+		if err != nil {
+			return nil, err
+		}
+
+		entry, err := newEntry(method, requestUrl, times[i])
+		if err != nil {
+			return nil, err
+		}
+		entry.Response = convertToHarResponse(response)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// FromWsRecords returns a single entry for a WebSocket connection to requestUrl
+// in which every message recorded in dir, e.g. through WsRecordDir, is a received frame,
+// and every message in dir/sent, as WriteRecords writes the frames sent by the client, is a sent frame.
+func FromWsRecords(requestUrl string, dir string) (Entry, error) {
+	received, err := readWsRecords(WebSocketReceive, dir)
+	if err != nil {
+		return Entry{}, err
+	}
+	sent, err := readWsRecords(WebSocketSend, filepath.Join(dir, wsSentDir))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Entry{}, err
+	}
+	messages := append(received, sent...)
+	slices.SortStableFunc(messages, func(a, b WebSocketMessage) int { return cmp.Compare(a.Time, b.Time) })
+
+	var startTime time.Time
+	if len(messages) > 0 {
+		startTime = fromUnixSeconds(messages[0].Time)
+	}
+	entry, err := NewWsEntry(requestUrl, startTime)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.WebSocketMessages = messages
+	return entry, nil
+}
+
+// readWsRecords returns the messages recorded in dir as frames of the given type
+func readWsRecords(messageType string, dir string) ([]WebSocketMessage, error) {
+	files, times, err := listRecords(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]WebSocketMessage, 0, len(files))
+	for i, f := range files {
+		message, err := ws.ReadFromFile(filepath.Join(dir, f))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, NewWebSocketMessage(messageType, times[i], message))
+	}
+	return messages, nil
+}
+
+// FromHttpRequest returns an entry for a request with method, URL and body made at t, and responded to with response
+func FromHttpRequest(method string, requestUrl string, t time.Time, body string, response http.Response) (Entry, error) {
+	entry, err := newEntry(method, requestUrl, t)
+	if err != nil {
+		return Entry{}, err
+	}
+	if body != "" {
+		entry.Request.PostData = &PostData{MimeType: "application/x-www-form-urlencoded", Text: body}
+		entry.Request.BodySize = len(body)
+	}
+	entry.Response = convertToHarResponse(response)
+	return entry, nil
+}
+
+// NewWsEntry returns an entry for a WebSocket connection to requestUrl opened at t, without frames
+func NewWsEntry(requestUrl string, t time.Time) (Entry, error) {
+	entry, err := newEntry("GET", requestUrl, t)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.Response.Status = 101
+	entry.Response.StatusText = "Switching Protocols"
+	return entry, nil
+}
+
+func newEntry(method string, requestUrl string, startedDateTime time.Time) (Entry, error) {
+	u, err := url.Parse(requestUrl)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid request URL: %w", err)
+	}
+
+	queryString := []NameValue{}
+	for name, values := range u.Query() {
+		for _, v := range values {
+			queryString = append(queryString, NameValue{Name: name, Value: v})
+		}
+	}
+
+	return Entry{
+		StartedDateTime: startedDateTime,
+		Request: Request{
+			Method:      method,
+			Url:         requestUrl,
+			HttpVersion: "HTTP/1.1",
+			Cookies:     []NameValue{},
+			Headers:     []NameValue{},
+			QueryString: queryString,
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: Response{
+			HttpVersion: "HTTP/1.1",
+			Cookies:     []NameValue{},
+			Headers:     []NameValue{},
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+	}, nil
+}
+
+func convertToHarResponse(response http.Response) Response {
+	return Response{
+		Status:      response.StatusCode,
+		HttpVersion: "HTTP/1.1",
+		Cookies:     []NameValue{},
+		Headers:     []NameValue{},
+		Content:     Content{Size: len(response.Body), MimeType: mimeType(response), Text: string(response.Body)},
+		HeadersSize: -1,
+		BodySize:    len(response.Body),
+	}
+}
+
+// mimeType returns the Content-Type of response. The recordings keep no headers, so a response without one is taken
+// to be JSON if its body is, as the responses of exchanges are.
+func mimeType(response http.Response) string {
+	contentType := textproto.MIMEHeader(response.Header).Get("Content-Type")
+	switch {
+	case contentType != "":
+		return contentType
+	case json.Valid(response.Body):
+		return "application/json"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// NewWebSocketMessage returns message as a frame of the given type at t, e.g. WebSocketReceive
+func NewWebSocketMessage(messageType string, t time.Time, message ws.Message) WebSocketMessage {
+	m := WebSocketMessage{Type: messageType, Time: unixSeconds(t)}
+	if message.Type == ws.MessageBinary {
+		m.Opcode = OpcodeBinary
+		m.Data = base64.StdEncoding.EncodeToString(message.Data)
+	} else {
+		m.Opcode = OpcodeText
+		m.Data = string(message.Data)
+	}
+	return m
+}
+
+// listRecords returns the YAML files in dir named by their RFC 3339 recording time, gzip or zstd compressed or not,
+// in time order
+func listRecords(dir string) ([]string, []time.Time, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type record struct {
+		file string
+		time time.Time
+	}
+	var records []record
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(fileio.TrimExtension(e.Name()), ".yaml")
+		if e.IsDir() || !ok {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, record{file: e.Name(), time: t})
+	}
+	slices.SortStableFunc(records, func(a, b record) int { return a.time.Compare(b.time) })
+
+	files := make([]string, len(records))
+	times := make([]time.Time, len(records))
+	for i, r := range records {
+		files[i] = r.file
+		times[i] = r.time
+	}
+	return files, times, nil
+}
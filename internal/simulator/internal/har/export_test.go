@@ -0,0 +1,91 @@
+package har
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/http"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromHttpRecords(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "2000-01-23T12:34:56.000000+09:00.yaml"), []byte("status: 200\nbody: '{}'\n"), 0644)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(tempDir, "2000-01-23T12:34:57.000000+09:00.yaml"), []byte("status: 503\nbody: 'unavailable'\n"), 0644)
+	require.NoError(t, err)
+
+	entries, err := FromHttpRecords("GET", "https://api.binance.com/api/v3/ping?a=1", tempDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "GET", entries[0].Request.Method)
+	assert.Equal(t, "https://api.binance.com/api/v3/ping?a=1", entries[0].Request.Url)
+	assert.Equal(t, []NameValue{{Name: "a", Value: "1"}}, entries[0].Request.QueryString)
+	assert.Equal(t, 200, entries[0].Response.Status)
+	assert.Equal(t, "{}", entries[0].Response.Content.Text)
+	assert.Equal(t, "application/json", entries[0].Response.Content.MimeType)
+	assert.Equal(t, 503, entries[1].Response.Status)
+	assert.Equal(t, "unavailable", entries[1].Response.Content.Text)
+	assert.Equal(t, "application/octet-stream", entries[1].Response.Content.MimeType)
+	assert.True(t, entries[0].StartedDateTime.Before(entries[1].StartedDateTime))
+}
+
+func TestFromWsRecords(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "2000-01-23T12:34:56.000000+09:00.yaml"), []byte("type: text\ndata: 'pong'\n"), 0644)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(tempDir, "2000-01-23T12:34:56.500000+09:00.yaml"), []byte("type: binary\ndata: '010203'\n"), 0644)
+	require.NoError(t, err)
+
+	entry, err := FromWsRecords("wss://api.whitebit.com/ws", tempDir)
+	require.NoError(t, err)
+
+	startTime := time.Date(2000, 1, 23, 12, 34, 56, 0, time.FixedZone("", 9*60*60))
+	assert.True(t, startTime.Equal(entry.StartedDateTime))
+	assert.Equal(t, 101, entry.Response.Status)
+	assert.Equal(t, []WebSocketMessage{
+		{Type: WebSocketReceive, Time: unixSeconds(startTime), Opcode: OpcodeText, Data: "pong"},
+		{Type: WebSocketReceive, Time: unixSeconds(startTime.Add(500 * time.Millisecond)), Opcode: OpcodeBinary, Data: "AQID"},
+	}, entry.WebSocketMessages)
+}
+
+func TestFromHttpRequest(t *testing.T) {
+	startTime := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	response := http.Response{StatusCode: 400, Body: []byte("rejected"), Header: map[string][]string{"Content-Type": {"text/plain"}}}
+
+	entry, err := FromHttpRequest("POST", "https://api.binance.com/api/v3/order?symbol=BTCUSDT", startTime, "side=SELL", response)
+	require.NoError(t, err)
+	assert.Equal(t, startTime, entry.StartedDateTime)
+	assert.Equal(t, []NameValue{{Name: "symbol", Value: "BTCUSDT"}}, entry.Request.QueryString)
+	assert.Equal(t, &PostData{MimeType: "application/x-www-form-urlencoded", Text: "side=SELL"}, entry.Request.PostData)
+	assert.Equal(t, 400, entry.Response.Status)
+	assert.Equal(t, Content{Size: 8, MimeType: "text/plain", Text: "rejected"}, entry.Response.Content)
+}
+
+func TestFromWsRecords_Compressed(t *testing.T) {
+	tempDir := t.TempDir()
+	messages := []ws.Message{
+		{Type: ws.MessageText, Data: []byte("data1")},
+		{Type: ws.MessageText, Data: []byte("data2")},
+	}
+	require.NoError(t, ws.WriteToFile(filepath.Join(tempDir, "2000-01-23T12:34:56.000000+09:00.yaml.gz"), messages[0]))
+	require.NoError(t, ws.WriteToFile(filepath.Join(tempDir, "2000-01-23T12:34:57.000000+09:00.yaml.zst"), messages[1]))
+
+	entry, err := FromWsRecords("wss://api.whitebit.com/ws", tempDir)
+	require.NoError(t, err)
+
+	require.Len(t, entry.WebSocketMessages, 2)
+	assert.Equal(t, "data1", entry.WebSocketMessages[0].Data)
+	assert.Equal(t, "data2", entry.WebSocketMessages[1].Data)
+}
+
+func TestFromHttpRecords_Error(t *testing.T) {
+	_, err := FromHttpRecords("GET", "https://example.com", "/non/existent/path")
+	assert.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package har
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// Har is the root of a HTTP Archive 1.2 document.
+// Only the fields the simulator reads or writes are modeled.
+type Har struct {
+	Log Log `json:"log"`
+}
+
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	// Time is the total elapsed time of the request in milliseconds
+	Time     float64  `json:"time"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+	Cache    struct{} `json:"cache"`
+	Timings  Timings  `json:"timings"`
+
+	// WebSocketMessages is the Chrome DevTools extension for WebSocket frames
+	WebSocketMessages []WebSocketMessage `json:"_webSocketMessages,omitempty"`
+}
+
+type Request struct {
+	Method      string      `json:"method"`
+	Url         string      `json:"url"`
+	HttpVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HttpVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectUrl string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type Content struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type WebSocketMessage struct {
+	// Type is either "send" or "receive"
+	Type string `json:"type"`
+	// Time is the Unix time of the frame in seconds
+	Time   float64 `json:"time"`
+	Opcode int     `json:"opcode"`
+	Data   string  `json:"data"`
+}
+
+const (
+	WebSocketSend    = "send"
+	WebSocketReceive = "receive"
+
+	OpcodeText   = 1
+	OpcodeBinary = 2
+)
+
+func New() Har {
+	return Har{
+		Log: Log{
+			Version: "1.2",
+			Creator: Creator{Name: "exchangesimulator", Version: "1.0"},
+			Entries: []Entry{},
+		},
+	}
+}
+
+func Decode(r io.Reader) (Har, error) {
+	var h Har
+	err := json.NewDecoder(r).Decode(&h)
+	if err != nil {
+		return Har{}, err
+	}
+
+	return h, nil
+}
+
+func Encode(w io.Writer, h Har) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(h)
+}
+
+func ReadFromFile(path string) (Har, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Har{}, err
+	}
+	defer f.Close()
+
+	return Decode(f)
+}
+
+func WriteToFile(path string, h Har) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = Encode(f, h)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+func fromUnixSeconds(s float64) time.Time {
+	return time.Unix(0, int64(s*float64(time.Second)))
+}
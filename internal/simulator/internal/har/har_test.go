@@ -0,0 +1,73 @@
+package har
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	input := `{
+		"log": {
+			"version": "1.2",
+			"creator": {"name": "WebInspector", "version": "537.36"},
+			"entries": [
+				{
+					"startedDateTime": "2024-10-04T05:53:53.686Z",
+					"time": 12.5,
+					"request": {"method": "GET", "url": "wss://api.whitebit.com/ws", "headers": []},
+					"response": {"status": 101, "content": {"size": 0, "mimeType": "x-unknown"}},
+					"_webSocketMessages": [
+						{"type": "send", "time": 1728021233.6866, "opcode": 1, "data": "ping"}
+					]
+				}
+			]
+		}
+	}`
+
+	h, err := Decode(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, h.Log.Entries, 1)
+
+	e := h.Log.Entries[0]
+	assert.Equal(t, time.Date(2024, 10, 4, 5, 53, 53, 686000000, time.UTC), e.StartedDateTime)
+	assert.Equal(t, 12.5, e.Time)
+	assert.Equal(t, "wss://api.whitebit.com/ws", e.Request.Url)
+	assert.Equal(t, 101, e.Response.Status)
+	assert.Equal(t, []WebSocketMessage{{Type: WebSocketSend, Time: 1728021233.6866, Opcode: OpcodeText, Data: "ping"}}, e.WebSocketMessages)
+}
+
+func TestEncode(t *testing.T) {
+	h := New()
+	h.Log.Entries = append(h.Log.Entries, Entry{Request: Request{Method: "GET", Url: "https://example.com"}})
+
+	var buf bytes.Buffer
+	err := Encode(&buf, h)
+	require.NoError(t, err)
+
+	decoded, err := Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, h, decoded)
+}
+
+func TestWriteToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.har")
+	h := New()
+
+	err := WriteToFile(path, h)
+	require.NoError(t, err)
+
+	decoded, err := ReadFromFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, h, decoded)
+}
+
+func TestReadFromFile_Error(t *testing.T) {
+	_, err := ReadFromFile("/non/existent/path.har")
+	assert.Error(t, err)
+}
@@ -0,0 +1,217 @@
+package har
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/http"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+)
+
+// WriteRecords converts the entries of h into the simulator's recording layout under dir.
+// HTTP responses are written to dir/http/<method>/<path>/<time>.yaml, which ResponseFromFile can serve,
+// or to dir/http/<method>/<path>/<query>/<time>.yaml if the request has a query string, escaped as a path segment,
+// so that the responses to different queries of a path stay apart.
+// WebSocket frames received by the client are written to dir/ws/<host>/<path>/<time>.yaml, which MessageFromFiles can replay,
+// and the frames sent by the client to dir/ws/<host>/<path>/sent/<time>.yaml.
+// Records sharing a time are moved apart by a nanosecond each, in the order of h.
+func WriteRecords(h Har, dir string) error {
+	paths := recordPaths{}
+	for _, e := range h.Log.Entries {
+		u, err := url.Parse(e.Request.Url)
+		if err != nil {
+			return fmt.Errorf("invalid request URL: %w", err)
+		}
+
+		if isWebSocket(e, u) {
+			err = writeWsRecords(e, filepath.Join(dir, "ws", u.Host, filepath.FromSlash(u.Path)), paths)
+		} else {
+			err = writeHttpRecord(e, httpRecordDir(dir, e.Request.Method, u), paths)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HttpRules returns a rule for every distinct method and path in h, answering with the first recorded response.
+// WebSocket entries are skipped.
+func HttpRules(h Har) ([]http.Rule, error) {
+	var rules []http.Rule
+	seen := map[string]bool{}
+	for _, e := range h.Log.Entries {
+		u, err := url.Parse(e.Request.Url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request URL: %w", err)
+		}
+		if isWebSocket(e, u) {
+			continue
+		}
+
+		key := e.Request.Method + " " + u.Path
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		response, err := convertResponse(e.Response)
+		if err != nil {
+			return nil, err
+		}
+
+		responseTime := time.Duration(e.Time * float64(time.Millisecond))
+		rules = append(rules, http.NewRule(
+			http.NewRequestPredicate(e.Request.Method, u.Path),
+			http.NewResponseFromString(response.StatusCode, string(response.Body), responseTime),
+		))
+	}
+	return rules, nil
+}
+
+// wsMessages returns the frames of a WebSocket entry with the given type, keyed by their time.
+func wsMessages(e Entry, messageType string) ([]time.Time, []ws.Message, error) {
+	var times []time.Time
+	var messages []ws.Message
+	for _, m := range e.WebSocketMessages {
+		if m.Type != messageType {
+			continue
+		}
+
+		message, err := convertWebSocketMessage(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		times = append(times, fromUnixSeconds(m.Time))
+		messages = append(messages, message)
+	}
+	return times, messages, nil
+}
+
+// httpRecordDir returns the directory in dir that the responses to method and u are written to
+func httpRecordDir(dir string, method string, u *url.URL) string {
+	recordDir := filepath.Join(dir, "http", method, filepath.FromSlash(u.Path))
+	if u.RawQuery != "" {
+		recordDir = filepath.Join(recordDir, url.PathEscape(u.RawQuery))
+	}
+	return recordDir
+}
+
+func isWebSocket(e Entry, u *url.URL) bool {
+	return len(e.WebSocketMessages) > 0 || u.Scheme == "ws" || u.Scheme == "wss"
+}
+
+func writeHttpRecord(e Entry, dir string, paths recordPaths) error {
+	response, err := convertResponse(e.Response)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	return http.WriteToFile(paths.next(dir, e.StartedDateTime), response)
+}
+
+// writeWsRecords writes the frames of e received by the client to dir, and those sent by it to dir/sent
+func writeWsRecords(e Entry, dir string, paths recordPaths) error {
+	err := writeWsMessages(e, WebSocketReceive, dir, paths)
+	if err != nil {
+		return err
+	}
+	return writeWsMessages(e, WebSocketSend, filepath.Join(dir, wsSentDir), paths)
+}
+
+// writeWsMessages writes the frames of e with the given type to dir
+func writeWsMessages(e Entry, messageType string, dir string, paths recordPaths) error {
+	times, messages, err := wsMessages(e, messageType)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	for i, m := range messages {
+		err = ws.WriteToFile(paths.next(dir, times[i]), m)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wsSentDir is the directory the frames sent by the client are written to, in the directory of the frames it received
+const wsSentDir = "sent"
+
+func convertResponse(r Response) (http.Response, error) {
+	body, err := decodeContent(r.Content.Text, r.Content.Encoding)
+	if err != nil {
+		return http.Response{}, err
+	}
+
+	return http.Response{StatusCode: r.Status, Body: body}, nil
+}
+
+func convertWebSocketMessage(m WebSocketMessage) (ws.Message, error) {
+	switch m.Opcode {
+	case OpcodeText:
+		return ws.Message{Type: ws.MessageText, Data: []byte(m.Data)}, nil
+	case OpcodeBinary:
+		// DevTools stores binary frames base64-encoded
+		data, err := base64.StdEncoding.DecodeString(m.Data)
+		if err != nil {
+			return ws.Message{}, fmt.Errorf("failed to decode binary frame: %w", err)
+		}
+		return ws.Message{Type: ws.MessageBinary, Data: data}, nil
+	default:
+		return ws.Message{}, fmt.Errorf("unsupported opcode: %d", m.Opcode)
+	}
+}
+
+func decodeContent(text string, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "":
+		return []byte(text), nil
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode content: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+func recordFilename(t time.Time) string {
+	return t.Local().Format(time.RFC3339Nano) + ".yaml"
+}
+
+// recordPaths hands out the paths of records, which are named by their time.
+// DevTools often records bursts of frames with the same time, which would overwrite each other.
+type recordPaths map[string]bool
+
+// next returns the path in dir of a record at t, or a nanosecond later for as long as the path was handed out already
+func (p recordPaths) next(dir string, t time.Time) string {
+	for {
+		path := filepath.Join(dir, recordFilename(t))
+		if !p[path] {
+			p[path] = true
+			return path
+		}
+		t = t.Add(time.Nanosecond)
+	}
+}
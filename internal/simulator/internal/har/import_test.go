@@ -0,0 +1,190 @@
+package har
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/http"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testHar() Har {
+	startTime := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+
+	h := New()
+	h.Log.Entries = []Entry{
+		{
+			StartedDateTime: startTime,
+			Time:            50,
+			Request:         Request{Method: "GET", Url: "https://api.binance.com/api/v3/ping"},
+			Response:        Response{Status: 200, Content: Content{Text: "{}"}},
+		},
+		{
+			StartedDateTime: startTime.Add(time.Second),
+			Time:            10,
+			Request:         Request{Method: "GET", Url: "https://api.binance.com/api/v3/ping"},
+			Response:        Response{Status: 500, Content: Content{Text: "error"}},
+		},
+		{
+			StartedDateTime: startTime,
+			Request:         Request{Method: "POST", Url: "https://api.binance.com/api/v3/order?symbol=BTCUSDT"},
+			Response:        Response{Status: 201, Content: Content{Text: "Y3JlYXRlZA==", Encoding: "base64"}},
+		},
+		{
+			StartedDateTime: startTime,
+			Request:         Request{Method: "GET", Url: "wss://api.whitebit.com/ws"},
+			Response:        Response{Status: 101},
+			WebSocketMessages: []WebSocketMessage{
+				{Type: WebSocketSend, Time: unixSeconds(startTime), Opcode: OpcodeText, Data: "ping"},
+				{Type: WebSocketReceive, Time: unixSeconds(startTime.Add(10 * time.Millisecond)), Opcode: OpcodeText, Data: "pong"},
+				{Type: WebSocketReceive, Time: unixSeconds(startTime.Add(20 * time.Millisecond)), Opcode: OpcodeBinary, Data: "AQID"},
+			},
+		},
+	}
+	return h
+}
+
+func TestWriteRecords(t *testing.T) {
+	tempDir := t.TempDir()
+
+	err := WriteRecords(testHar(), tempDir)
+	require.NoError(t, err)
+
+	pingDir := filepath.Join(tempDir, "http", "GET", "api", "v3", "ping")
+	files, err := os.ReadDir(pingDir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	response, err := http.ReadFromFile(filepath.Join(pingDir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, http.Response{StatusCode: 200, Body: []byte("{}")}, response)
+
+	orderDir := filepath.Join(tempDir, "http", "POST", "api", "v3", "order", "symbol=BTCUSDT")
+	files, err = os.ReadDir(orderDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	response, err = http.ReadFromFile(filepath.Join(orderDir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, http.Response{StatusCode: 201, Body: []byte("created")}, response)
+
+	wsDir := filepath.Join(tempDir, "ws", "api.whitebit.com", "ws")
+	files, err = os.ReadDir(wsDir)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	assert.True(t, files[2].IsDir())
+	message, err := ws.ReadFromFile(filepath.Join(wsDir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, ws.Message{Type: ws.MessageText, Data: []byte("pong")}, message)
+	message, err = ws.ReadFromFile(filepath.Join(wsDir, files[1].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, ws.Message{Type: ws.MessageBinary, Data: []byte{0x01, 0x02, 0x03}}, message)
+
+	sentDir := filepath.Join(wsDir, "sent")
+	files, err = os.ReadDir(sentDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	message, err = ws.ReadFromFile(filepath.Join(sentDir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, ws.Message{Type: ws.MessageText, Data: []byte("ping")}, message)
+
+	// Both directions are exported again in the order they were recorded in
+	entry, err := FromWsRecords("wss://api.whitebit.com/ws", wsDir)
+	require.NoError(t, err)
+	assert.Equal(t, testHar().Log.Entries[3].WebSocketMessages, entry.WebSocketMessages)
+}
+
+func TestWriteRecords_Query(t *testing.T) {
+	tempDir := t.TempDir()
+	startTime := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	h := New()
+	h.Log.Entries = []Entry{
+		{StartedDateTime: startTime, Request: Request{Method: "GET", Url: "https://api.binance.com/api/v3/depth?symbol=BTCUSDT"}, Response: Response{Status: 200, Content: Content{Text: "btc"}}},
+		{StartedDateTime: startTime, Request: Request{Method: "GET", Url: "https://api.binance.com/api/v3/depth?symbol=ETHUSDT&path=a/b"}, Response: Response{Status: 200, Content: Content{Text: "eth"}}},
+	}
+
+	err := WriteRecords(h, tempDir)
+	require.NoError(t, err)
+
+	depthDir := filepath.Join(tempDir, "http", "GET", "api", "v3", "depth")
+	for query, body := range map[string]string{"symbol=BTCUSDT": "btc", "symbol=ETHUSDT&path=a%2Fb": "eth"} {
+		files, err := os.ReadDir(filepath.Join(depthDir, query))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		response, err := http.ReadFromFile(filepath.Join(depthDir, query, files[0].Name()))
+		require.NoError(t, err)
+		assert.Equal(t, body, string(response.Body))
+	}
+}
+
+func TestWriteRecords_SameTime(t *testing.T) {
+	tempDir := t.TempDir()
+	startTime := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	h := New()
+	h.Log.Entries = []Entry{{
+		StartedDateTime: startTime,
+		Request:         Request{Method: "GET", Url: "wss://api.whitebit.com/ws"},
+		WebSocketMessages: []WebSocketMessage{
+			{Type: WebSocketReceive, Time: unixSeconds(startTime), Opcode: OpcodeText, Data: "data1"},
+			{Type: WebSocketReceive, Time: unixSeconds(startTime), Opcode: OpcodeText, Data: "data2"},
+			{Type: WebSocketReceive, Time: unixSeconds(startTime), Opcode: OpcodeText, Data: "data3"},
+		},
+	}}
+
+	err := WriteRecords(h, tempDir)
+	require.NoError(t, err)
+
+	entry, err := FromWsRecords("wss://api.whitebit.com/ws", filepath.Join(tempDir, "ws", "api.whitebit.com", "ws"))
+	require.NoError(t, err)
+	require.Len(t, entry.WebSocketMessages, 3)
+	for i, data := range []string{"data1", "data2", "data3"} {
+		assert.Equal(t, data, entry.WebSocketMessages[i].Data)
+	}
+}
+
+func TestHttpRules(t *testing.T) {
+	rules, err := HttpRules(testHar())
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+
+	tests := []struct {
+		name             string
+		request          http.Request
+		expectedRule     int
+		expectedResponse http.Response
+	}{
+		{
+			name:             "First recorded response",
+			request:          http.Request{Method: "GET", Path: "/api/v3/ping"},
+			expectedRule:     0,
+			expectedResponse: http.Response{StatusCode: 200, Body: []byte("{}")},
+		},
+		{
+			name:             "Base64 content",
+			request:          http.Request{Method: "POST", Path: "/api/v3/order"},
+			expectedRule:     1,
+			expectedResponse: http.Response{StatusCode: 201, Body: []byte("created")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := rules[tt.expectedRule]
+			assert.True(t, rule.MatchRequest(tt.request))
+
+			response, err := rule.Response(tt.request)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedResponse, response)
+		})
+	}
+}
+
+func TestConvertWebSocketMessage_Error(t *testing.T) {
+	_, err := convertWebSocketMessage(WebSocketMessage{Opcode: 9})
+	assert.Error(t, err)
+
+	_, err = convertWebSocketMessage(WebSocketMessage{Opcode: OpcodeBinary, Data: "not base64"})
+	assert.Error(t, err)
+}
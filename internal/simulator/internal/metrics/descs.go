@@ -0,0 +1,77 @@
+package metrics
+
+// The metrics of the simulator
+var (
+	HttpRequests = &Desc{
+		Name:   "exchangesimulator_http_requests_total",
+		Help:   "HTTP requests responded to, by the id of the rule that responded, or none, and the status code.",
+		Kind:   Counter,
+		Labels: []string{"rule", "status_code"},
+	}
+	HttpUnmatchedRequests = &Desc{
+		Name: "exchangesimulator_http_unmatched_requests_total",
+		Help: "HTTP requests that no rule matched.",
+		Kind: Counter,
+	}
+	WsConnections = &Desc{
+		Name:   "exchangesimulator_ws_connections_total",
+		Help:   "WebSocket connections opened.",
+		Kind:   Counter,
+		Labels: []string{"endpoint"},
+	}
+	WsConnectionsOpen = &Desc{
+		Name:   "exchangesimulator_ws_connections_open",
+		Help:   "WebSocket connections open.",
+		Kind:   Gauge,
+		Labels: []string{"endpoint"},
+	}
+	WsMessages = &Desc{
+		Name:   "exchangesimulator_ws_messages_total",
+		Help:   "WebSocket messages read from the clients (in) and written to them (out).",
+		Kind:   Counter,
+		Labels: []string{"endpoint", "direction"},
+	}
+	WsUnmatchedMessages = &Desc{
+		Name:   "exchangesimulator_ws_unmatched_messages_total",
+		Help:   "WebSocket messages read from the clients that no rule matched.",
+		Kind:   Counter,
+		Labels: []string{"endpoint"},
+	}
+	WsWriteQueued = &Desc{
+		Name:   "exchangesimulator_ws_write_queued",
+		Help:   "Messages waiting to be written to a WebSocket connection.",
+		Kind:   Gauge,
+		Labels: []string{"connection_id"},
+	}
+	WsWriteMaxQueued = &Desc{
+		Name:   "exchangesimulator_ws_write_max_queued",
+		Help:   "The most messages that have waited to be written to a WebSocket connection.",
+		Kind:   Gauge,
+		Labels: []string{"connection_id"},
+	}
+	WsWriteDropped = &Desc{
+		Name:   "exchangesimulator_ws_write_dropped",
+		Help:   "Messages dropped rather than written to a WebSocket connection that fell behind.",
+		Kind:   Gauge,
+		Labels: []string{"connection_id"},
+	}
+	ReplayLag = &Desc{
+		Name:    "exchangesimulator_replay_lag_seconds",
+		Help:    "How late replayed messages are written, behind the time they are scheduled for.",
+		Kind:    Histogram,
+		Buckets: DefaultBuckets,
+	}
+	RedirectDuration = &Desc{
+		Name:    "exchangesimulator_redirect_duration_seconds",
+		Help:    "How long redirecting to the upstream exchange takes: a HTTP round trip, or connecting a WebSocket.",
+		Kind:    Histogram,
+		Labels:  []string{"protocol"},
+		Buckets: DefaultBuckets,
+	}
+	RedirectErrors = &Desc{
+		Name:   "exchangesimulator_redirect_errors_total",
+		Help:   "Failures to redirect to the upstream exchange or to relay its messages.",
+		Kind:   Counter,
+		Labels: []string{"protocol"},
+	}
+)
@@ -0,0 +1,227 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Kind is the type of a metric in the Prometheus text format
+type Kind string
+
+const (
+	Counter   Kind = "counter"
+	Gauge     Kind = "gauge"
+	Histogram Kind = "histogram"
+)
+
+// DefaultBuckets are the upper bounds of the buckets of a histogram in seconds, as in the Prometheus client libraries
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Desc describes a metric: a family of series told apart by the values of its labels
+type Desc struct {
+	Name   string
+	Help   string
+	Kind   Kind
+	Labels []string
+	// Buckets are the upper bounds of the buckets of a histogram, in increasing order
+	Buckets []float64
+}
+
+// Registry keeps the metrics of a simulator, and writes them in the Prometheus text format.
+// A nil registry keeps nothing, so that whatever runs without one need not tell.
+type Registry struct {
+	lock     sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	desc   *Desc
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// counts are the observations of a histogram in each bucket, not cumulated, and in +Inf last
+	counts []uint64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// Add adds v to the counter or gauge d with labelValues
+func (r *Registry) Add(d *Desc, v float64, labelValues ...string) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.series(d, labelValues).value += v
+}
+
+// Set sets the gauge d with labelValues to v
+func (r *Registry) Set(d *Desc, v float64, labelValues ...string) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.series(d, labelValues).value = v
+}
+
+// Observe counts v in the histogram d with labelValues
+func (r *Registry) Observe(d *Desc, v float64, labelValues ...string) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	s := r.series(d, labelValues)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(d.Buckets)+1)
+	}
+	i, _ := slices.BinarySearch(d.Buckets, v)
+	s.counts[i]++
+	s.value += v
+}
+
+// series returns the series of d with labelValues, adding it if there is none. r.lock must be held.
+func (r *Registry) series(d *Desc, labelValues []string) *series {
+	f, ok := r.families[d.Name]
+	if !ok {
+		f = &family{desc: d, series: map[string]*series{}}
+		r.families[d.Name] = f
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: slices.Clone(labelValues)}
+		f.series[key] = s
+	}
+	return s
+}
+
+// WriteText writes every metric in the Prometheus text format, ordered by name and labels, together with the metrics
+// of snapshots, e.g. the gauges of every connection set anew for each scrape as the connections come and go.
+// A series in a snapshot takes the place of the same series in r.
+func (r *Registry) WriteText(w io.Writer, snapshots ...*Registry) error {
+	families := map[string]*family{}
+	for _, registry := range append([]*Registry{r}, snapshots...) {
+		registry.lock.Lock()
+		defer registry.lock.Unlock()
+
+		for name, f := range registry.families {
+			merged, ok := families[name]
+			if !ok {
+				merged = &family{desc: f.desc, series: map[string]*series{}}
+				families[name] = merged
+			}
+			for key, s := range f.series {
+				merged.series[key] = s
+			}
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		f := families[name]
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, helpEscaper.Replace(f.desc.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, f.desc.Kind)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			f.writeSeries(bw, f.series[key])
+		}
+	}
+	return bw.Flush()
+}
+
+func (f *family) writeSeries(w io.Writer, s *series) {
+	d := f.desc
+	if d.Kind != Histogram {
+		fmt.Fprintf(w, "%s%s %s\n", d.Name, labels(d.Labels, s.labelValues, "", 0), formatFloat(s.value))
+		return
+	}
+
+	var count uint64
+	for i, bound := range append(slices.Clone(d.Buckets), math.Inf(1)) {
+		count += s.counts[i]
+		fmt.Fprintf(w, "%s_bucket%s %d\n", d.Name, labels(d.Labels, s.labelValues, "le", bound), count)
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", d.Name, labels(d.Labels, s.labelValues, "", 0), formatFloat(s.value))
+	fmt.Fprintf(w, "%s_count%s %d\n", d.Name, labels(d.Labels, s.labelValues, "", 0), count)
+}
+
+// labels formats the labels of a series, with the le label of a histogram bucket if le is not empty
+func labels(names []string, values []string, le string, bound float64) string {
+	var pairs []string
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, name+`="`+labelEscaper.Replace(value)+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, le+`="`+formatFloat(bound)+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries r, the registry whatever runs with ctx counts in
+func NewContext(ctx context.Context, r *Registry) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the registry carried by ctx, or nil if there is none
+func FromContext(ctx context.Context) *Registry {
+	r, _ := ctx.Value(contextKey{}).(*Registry)
+	return r
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testCounter = &Desc{Name: "test_requests_total", Help: "Requests.\nAll of them.", Kind: Counter, Labels: []string{"path", "status_code"}}
+	testGauge   = &Desc{Name: "test_open", Help: "Open connections.", Kind: Gauge}
+	testLatency = &Desc{Name: "test_latency_seconds", Help: "Latency.", Kind: Histogram, Labels: []string{"protocol"}, Buckets: []float64{0.1, 1}}
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	r.Add(testCounter, 1, "/order", "200")
+	r.Add(testCounter, 2, "/order", "200")
+	r.Add(testCounter, 1, `/"quoted"`, "404")
+	r.Set(testGauge, 5)
+	r.Set(testGauge, 3)
+	for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+		r.Observe(testLatency, v, "http")
+	}
+
+	var text strings.Builder
+	assert.NoError(t, r.WriteText(&text))
+	assert.Equal(t, `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{protocol="http",le="0.1"} 2
+test_latency_seconds_bucket{protocol="http",le="1"} 3
+test_latency_seconds_bucket{protocol="http",le="+Inf"} 4
+test_latency_seconds_sum{protocol="http"} 2.65
+test_latency_seconds_count{protocol="http"} 4
+# HELP test_open Open connections.
+# TYPE test_open gauge
+test_open 3
+# HELP test_requests_total Requests.\nAll of them.
+# TYPE test_requests_total counter
+test_requests_total{path="/\"quoted\"",status_code="404"} 1
+test_requests_total{path="/order",status_code="200"} 3
+`, text.String())
+}
+
+func TestRegistry_WriteText_Snapshots(t *testing.T) {
+	r := NewRegistry()
+	r.Set(testGauge, 1)
+	r.Add(testCounter, 1, "/order", "200")
+	snapshot := NewRegistry()
+	snapshot.Set(testGauge, 2)
+	snapshot.Add(testCounter, 1, "/time", "200")
+
+	var text strings.Builder
+	assert.NoError(t, r.WriteText(&text, snapshot))
+	assert.Equal(t, `# HELP test_open Open connections.
+# TYPE test_open gauge
+test_open 2
+# HELP test_requests_total Requests.\nAll of them.
+# TYPE test_requests_total counter
+test_requests_total{path="/order",status_code="200"} 1
+test_requests_total{path="/time",status_code="200"} 1
+`, text.String())
+}
+
+func TestRegistry_Nil(t *testing.T) {
+	var r *Registry
+
+	assert.NotPanics(t, func() {
+		r.Add(testCounter, 1, "/order", "200")
+		r.Set(testGauge, 1)
+		r.Observe(testLatency, 1, "http")
+	})
+}
+
+func TestFromContext(t *testing.T) {
+	r := NewRegistry()
+
+	assert.Same(t, r, FromContext(NewContext(context.Background(), r)))
+	assert.Nil(t, FromContext(context.Background()))
+}
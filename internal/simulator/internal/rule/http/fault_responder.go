@@ -0,0 +1,153 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+)
+
+// Fault tells how a responder fails, to test how clients cope with an unreliable exchange.
+// Rates are probabilities from 0 to 1, and the zero value leaves the responses alone.
+type Fault struct {
+	// Latency is how long a response takes on top of the responder's own response time; nil adds nothing
+	Latency Latency
+	// ErrorRates tell how often a status code such as 500, 502, 503 or 504 is responded with instead of the response
+	ErrorRates map[int]float64
+	// HangRate is how often no response is made at all, until the client gives up
+	HangRate float64
+	// ResetRate is how often the connection is reset while the body is written
+	ResetRate float64
+	// TrickleRate is how often the body is written slowly, TrickleChunkSize bytes every TrickleInterval.
+	// TrickleChunkSize 0 stands for 1.
+	TrickleRate      float64
+	TrickleChunkSize int
+	TrickleInterval  time.Duration
+	// Seed seeds the faults, so that requests made one at a time fail the same way from run to run
+	Seed uint64
+}
+
+// Ensure FaultResponder implements Responder
+var _ Responder = (*FaultResponder)(nil)
+
+// FaultResponder responds as a responder does, but slows down and fails as its Fault tells
+type FaultResponder struct {
+	responder Responder
+	fault     Fault
+	clock     clock.Clock
+	state     *faultResponderState
+}
+
+type faultResponderState struct {
+	lock   sync.Mutex
+	random *rand.Rand
+}
+
+func NewFaultResponder(responder Responder, fault Fault) FaultResponder {
+	return FaultResponder{
+		responder: responder,
+		fault:     fault,
+		state:     &faultResponderState{random: rand.New(rand.NewPCG(fault.Seed, 0))},
+	}
+}
+
+// WithClock returns a copy of r that waits on c instead of the clock of the simulator
+func (r FaultResponder) WithClock(c clock.Clock) FaultResponder {
+	r.clock = c
+	return r
+}
+
+// Reset reseeds the faults, so that they are drawn again as from the start
+func (r FaultResponder) Reset() {
+	r.state.lock.Lock()
+	r.state.random = rand.New(rand.NewPCG(r.fault.Seed, 0))
+	r.state.lock.Unlock()
+
+	reset(r.responder)
+}
+
+// faultDraw is what a fault does to one response
+type faultDraw struct {
+	hang       bool
+	latency    time.Duration
+	statusCode int
+	reset      bool
+	resetAt    float64
+	trickle    bool
+}
+
+func (r FaultResponder) draw() faultDraw {
+	r.state.lock.Lock()
+	defer r.state.lock.Unlock()
+
+	var d faultDraw
+	random := r.state.random
+	happens := func(rate float64) bool {
+		return rate > 0 && random.Float64() < rate
+	}
+
+	d.hang = happens(r.fault.HangRate)
+	if r.fault.Latency != nil {
+		d.latency = r.fault.Latency.Draw(random)
+	}
+	// The status codes are drawn in order, so that the draws do not depend on the order of the map
+	codes := make([]int, 0, len(r.fault.ErrorRates))
+	for code := range r.fault.ErrorRates {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		if happens(r.fault.ErrorRates[code]) {
+			d.statusCode = code
+			break
+		}
+	}
+	d.reset = happens(r.fault.ResetRate)
+	if d.reset {
+		d.resetAt = random.Float64()
+	}
+	d.trickle = happens(r.fault.TrickleRate)
+	return d
+}
+
+func (r FaultResponder) Response(request Request) (Response, error) {
+	ctx := request.Context()
+	d := r.draw()
+	if d.hang {
+		<-ctx.Done()
+		return Response{}, context.Cause(ctx)
+	}
+
+	err := clock.Sleep(ctx, clock.Resolve(ctx, r.clock), d.latency)
+	if err != nil {
+		return Response{}, err
+	}
+
+	var response Response
+	if d.statusCode != 0 {
+		response = Response{
+			StatusCode: d.statusCode,
+			Body:       []byte(fmt.Sprintf(`{"error":%q}`, http.StatusText(d.statusCode))),
+		}
+	} else {
+		response, err = r.responder.Response(request)
+		if err != nil {
+			return Response{}, err
+		}
+	}
+
+	if d.reset {
+		response.Delivery.Reset = true
+		response.Delivery.ResetAfter = int(d.resetAt * float64(len(response.Body)))
+	}
+	if d.trickle {
+		response.Delivery.ChunkSize = max(1, r.fault.TrickleChunkSize)
+		response.Delivery.ChunkInterval = r.fault.TrickleInterval
+	}
+	return response, nil
+}
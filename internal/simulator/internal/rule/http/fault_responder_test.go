@@ -0,0 +1,98 @@
+package http
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultResponder_Response(t *testing.T) {
+	body := `{"status":"ok"}`
+	tests := []struct {
+		name     string
+		fault    Fault
+		expected Response
+	}{
+		{
+			name:     "No fault",
+			expected: Response{StatusCode: 200, Body: []byte(body)},
+		},
+		{
+			name:     "Error status",
+			fault:    Fault{ErrorRates: map[int]float64{502: 0, 503: 1}},
+			expected: Response{StatusCode: 503, Body: []byte(`{"error":"Service Unavailable"}`)},
+		},
+		{
+			name:     "Reset",
+			fault:    Fault{ResetRate: 1, Seed: 1},
+			expected: Response{StatusCode: 200, Body: []byte(body), Delivery: Delivery{Reset: true, ResetAfter: 7}},
+		},
+		{
+			name:     "Trickle",
+			fault:    Fault{TrickleRate: 1, TrickleInterval: time.Second},
+			expected: Response{StatusCode: 200, Body: []byte(body), Delivery: Delivery{ChunkSize: 1, ChunkInterval: time.Second}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewFaultResponder(NewResponseFromString(200, body, 0), tt.fault)
+
+			response, err := r.Response(Request{})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, response)
+		})
+	}
+}
+
+func TestFaultResponder_Response_Latency(t *testing.T) {
+	c := clock.NewFake(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC))
+	r := NewFaultResponder(NewResponseFromString(200, "OK", 0), Fault{Latency: UniformLatency{Min: time.Second, Max: time.Second}})
+	request := Request{}.WithContext(clock.NewContext(context.Background(), c))
+
+	done := make(chan error)
+	go func() {
+		_, err := r.Response(request)
+		done <- err
+	}()
+	require.NoError(t, c.WaitForSleepers(context.Background(), 1))
+	c.Advance(time.Second)
+
+	assert.NoError(t, <-done)
+}
+
+func TestFaultResponder_Response_Hang(t *testing.T) {
+	r := NewFaultResponder(NewResponseFromString(200, "OK", 0), Fault{HangRate: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := r.Response(Request{}.WithContext(ctx))
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestFaultResponder_Response_Seed(t *testing.T) {
+	fault := Fault{ErrorRates: map[int]float64{500: 0.2, 502: 0.2}, ResetRate: 0.2, Seed: 3}
+	statusCodes := func(fault Fault) []int {
+		r := NewFaultResponder(NewResponseFromString(200, "OK", 0), fault)
+		var codes []int
+		for range 20 {
+			response, err := r.Response(Request{})
+			require.NoError(t, err)
+			codes = append(codes, response.StatusCode)
+		}
+		return codes
+	}
+
+	codes := statusCodes(fault)
+	assert.Contains(t, codes, 200)
+	assert.Equal(t, codes, statusCodes(fault))
+	fault.Seed = 4
+	assert.NotEqual(t, codes, statusCodes(fault))
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Latency is a distribution that response times are drawn from
+type Latency interface {
+	Draw(random *rand.Rand) time.Duration
+}
+
+// Ensure UniformLatency implements Latency
+var _ Latency = (*UniformLatency)(nil)
+
+// UniformLatency draws response times uniformly between Min and Max
+type UniformLatency struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+func (l UniformLatency) Draw(random *rand.Rand) time.Duration {
+	if l.Max <= l.Min {
+		return l.Min
+	}
+	return l.Min + time.Duration(random.Int64N(int64(l.Max-l.Min)+1))
+}
+
+// Ensure NormalLatency implements Latency
+var _ Latency = (*NormalLatency)(nil)
+
+// NormalLatency draws response times from a normal distribution, and takes the negative ones for 0
+type NormalLatency struct {
+	Mean   time.Duration
+	StdDev time.Duration
+}
+
+func (l NormalLatency) Draw(random *rand.Rand) time.Duration {
+	return max(0, l.Mean+time.Duration(random.NormFloat64()*float64(l.StdDev)))
+}
+
+// Ensure HistogramLatency implements Latency
+var _ Latency = (*HistogramLatency)(nil)
+
+// HistogramLatency draws response times from a recorded histogram:
+// a bucket is picked as often as its count tells, and a response time uniformly within it
+type HistogramLatency struct {
+	Buckets []LatencyBucket
+}
+
+// LatencyBucket counts the response times up to UpTo, from the UpTo of the previous bucket or 0
+type LatencyBucket struct {
+	UpTo  time.Duration
+	Count int
+}
+
+func (l HistogramLatency) Draw(random *rand.Rand) time.Duration {
+	total := 0
+	for _, b := range l.Buckets {
+		total += max(0, b.Count)
+	}
+	if total == 0 {
+		return 0
+	}
+
+	n := random.IntN(total)
+	var from time.Duration
+	for _, b := range l.Buckets {
+		if n < b.Count {
+			return UniformLatency{Min: from, Max: b.UpTo}.Draw(random)
+		}
+		n -= max(0, b.Count)
+		from = b.UpTo
+	}
+	return from
+}
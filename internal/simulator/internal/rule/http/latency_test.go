@@ -0,0 +1,79 @@
+package http
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatency_Draw(t *testing.T) {
+	tests := []struct {
+		name    string
+		latency Latency
+		check   func(t *testing.T, d time.Duration)
+	}{
+		{
+			name:    "Uniform",
+			latency: UniformLatency{Min: 10 * time.Millisecond, Max: 20 * time.Millisecond},
+			check: func(t *testing.T, d time.Duration) {
+				assert.GreaterOrEqual(t, d, 10*time.Millisecond)
+				assert.LessOrEqual(t, d, 20*time.Millisecond)
+			},
+		},
+		{
+			name:    "Uniform without a range",
+			latency: UniformLatency{Min: 10 * time.Millisecond},
+			check:   func(t *testing.T, d time.Duration) { assert.Equal(t, 10*time.Millisecond, d) },
+		},
+		{
+			name:    "Normal",
+			latency: NormalLatency{Mean: 10 * time.Millisecond, StdDev: 20 * time.Millisecond},
+			check:   func(t *testing.T, d time.Duration) { assert.GreaterOrEqual(t, d, time.Duration(0)) },
+		},
+		{
+			name: "Histogram",
+			latency: HistogramLatency{Buckets: []LatencyBucket{
+				{UpTo: 10 * time.Millisecond, Count: 0},
+				{UpTo: 20 * time.Millisecond, Count: 5},
+				{UpTo: 30 * time.Millisecond, Count: 0},
+			}},
+			check: func(t *testing.T, d time.Duration) {
+				assert.GreaterOrEqual(t, d, 10*time.Millisecond)
+				assert.LessOrEqual(t, d, 20*time.Millisecond)
+			},
+		},
+		{
+			name:    "Empty histogram",
+			latency: HistogramLatency{},
+			check:   func(t *testing.T, d time.Duration) { assert.Zero(t, d) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			random := rand.New(rand.NewPCG(1, 0))
+			for range 100 {
+				tt.check(t, tt.latency.Draw(random))
+			}
+		})
+	}
+}
+
+func TestHistogramLatency_Draw_Counts(t *testing.T) {
+	l := HistogramLatency{Buckets: []LatencyBucket{
+		{UpTo: 10 * time.Millisecond, Count: 9},
+		{UpTo: 20 * time.Millisecond, Count: 1},
+	}}
+	random := rand.New(rand.NewPCG(1, 0))
+
+	fast := 0
+	for range 1000 {
+		if l.Draw(random) <= 10*time.Millisecond {
+			fast++
+		}
+	}
+
+	assert.InDelta(t, 900, fast, 50)
+}
@@ -0,0 +1,283 @@
+package http
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+)
+
+// RateLimit limits the weight of the requests a client makes in a window,
+// such as the request weight of 6000 per minute of Binance
+type RateLimit struct {
+	// Window is how long the weight is counted for. The windows start at the multiples of Window since the zero time,
+	// e.g. on every minute for a minute. It must be positive.
+	Window time.Duration
+	Limit  int
+	// Header is the response header the weight used in the window is told in, e.g. X-MBX-USED-WEIGHT-1M; empty tells none
+	Header string
+}
+
+// ClientKey tells which client a request is made by, for the requests of a client to be limited together
+type ClientKey func(Request) string
+
+// ClientIp keys the clients by their IP address
+func ClientIp(request Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// ClientHeader keys the clients by a request header such as an API key, or by their IP address if they do not send it
+func ClientHeader(name string) ClientKey {
+	return func(request Request) string {
+		value := http.Header(request.Header).Get(name)
+		if value == "" {
+			return ClientIp(request)
+		}
+		return value
+	}
+}
+
+// RateLimiter tracks the weight of the requests of every client against its limits, as an exchange does.
+// A request over a limit is rejected with 429 Too Many Requests, and a client that keeps making them is banned
+// with 418 I'm a teapot, as on Binance. The requests are weighed by the responders made with Limit.
+// A client is forgotten once its windows are over and it is not banned, as it would start afresh anyway.
+type RateLimiter struct {
+	limits      []RateLimit
+	key         ClientKey
+	banAfter    int
+	banDuration time.Duration
+	clock       clock.Clock
+	state       *rateLimiterState
+}
+
+type rateLimiterState struct {
+	lock    sync.Mutex
+	clients map[string]*rateLimitedClient
+	// pruned is when the clients were last looked through for the ones to forget
+	pruned time.Time
+}
+
+type rateLimitedClient struct {
+	windows     []time.Time
+	used        []int
+	rejected    int
+	bannedUntil time.Time
+}
+
+func NewRateLimiter(limits ...RateLimit) RateLimiter {
+	for _, limit := range limits {
+		if limit.Window <= 0 {
+			panic(fmt.Sprintf("invalid rate limit window: %s", limit.Window))
+		}
+	}
+	return RateLimiter{
+		limits: limits,
+		key:    ClientIp,
+		state:  &rateLimiterState{clients: map[string]*rateLimitedClient{}},
+	}
+}
+
+// WithClientKey returns a copy of l that tells the clients apart by key instead of by their IP address
+func (l RateLimiter) WithClientKey(key ClientKey) RateLimiter {
+	l.key = key
+	return l
+}
+
+// WithBan returns a copy of l that bans a client for duration once after requests in a row are rejected
+func (l RateLimiter) WithBan(after int, duration time.Duration) RateLimiter {
+	l.banAfter = after
+	l.banDuration = duration
+	return l
+}
+
+// WithClock returns a copy of l that counts the windows on c instead of the clock of the simulator
+func (l RateLimiter) WithClock(c clock.Clock) RateLimiter {
+	l.clock = c
+	return l
+}
+
+// Reset forgets the weight used by every client, and lifts their bans
+func (l RateLimiter) Reset() {
+	l.state.lock.Lock()
+	defer l.state.lock.Unlock()
+
+	clear(l.state.clients)
+}
+
+// Limit returns a responder that weighs the requests to responder by weight against the limits of l
+func (l RateLimiter) Limit(responder Responder, weight int) RateLimitResponder {
+	return RateLimitResponder{responder: responder, limiter: l, weight: weight}
+}
+
+// rateLimitDecision is what a rate limiter decides for a request
+type rateLimitDecision struct {
+	header map[string][]string
+	// rejection is the response the request is rejected with, if it is
+	rejection *Response
+}
+
+func (l RateLimiter) take(request Request, weight int) rateLimitDecision {
+	ctx := request.Context()
+	now := clock.Resolve(ctx, l.clock).Now()
+	key := l.key(request)
+
+	l.state.lock.Lock()
+	defer l.state.lock.Unlock()
+
+	l.prune(now)
+	client, ok := l.state.clients[key]
+	if !ok {
+		client = &rateLimitedClient{
+			windows: make([]time.Time, len(l.limits)),
+			used:    make([]int, len(l.limits)),
+		}
+		l.state.clients[key] = client
+	}
+
+	var d rateLimitDecision
+	if now.Before(client.bannedUntil) {
+		d.header = l.usageHeader(client)
+		d.rejection = l.banResponse(key, client.bannedUntil, now)
+		return d
+	}
+
+	var exceeded *RateLimit
+	var retryAt time.Time
+	for i, limit := range l.limits {
+		window := now.Truncate(limit.Window)
+		if !window.Equal(client.windows[i]) {
+			client.windows[i] = window
+			client.used[i] = 0
+		}
+		client.used[i] += weight
+		if client.used[i] > limit.Limit && (exceeded == nil || window.Add(limit.Window).After(retryAt)) {
+			exceeded = &l.limits[i]
+			retryAt = window.Add(limit.Window)
+		}
+	}
+	d.header = l.usageHeader(client)
+	if exceeded == nil {
+		client.rejected = 0
+		return d
+	}
+
+	client.rejected++
+	if l.banAfter > 0 && client.rejected >= l.banAfter {
+		client.rejected = 0
+		client.bannedUntil = now.Add(l.banDuration)
+		d.rejection = l.banResponse(key, client.bannedUntil, now)
+		return d
+	}
+	d.rejection = &Response{
+		StatusCode: http.StatusTooManyRequests,
+		Body: []byte(fmt.Sprintf(
+			`{"code":-1003,"msg":"Too many requests; current limit of %s request weight is %d per %s. Please use WebSocket Streams for live updates to avoid polling the API."}`,
+			key, exceeded.Limit, exceeded.Window,
+		)),
+		Header: map[string][]string{"Retry-After": {retryAfter(retryAt, now)}},
+	}
+	return d
+}
+
+// prune forgets the clients whose windows are all over and who are not banned, at most once in the shortest window.
+// l.state.lock must be held.
+func (l RateLimiter) prune(now time.Time) {
+	if len(l.limits) == 0 {
+		return
+	}
+	shortest := slices.MinFunc(l.limits, func(a, b RateLimit) int { return cmp.Compare(a.Window, b.Window) }).Window
+	if now.Before(l.state.pruned.Add(shortest)) {
+		return
+	}
+	l.state.pruned = now
+
+	maps.DeleteFunc(l.state.clients, func(_ string, client *rateLimitedClient) bool {
+		if now.Before(client.bannedUntil) {
+			return false
+		}
+		for i, limit := range l.limits {
+			if now.Before(client.windows[i].Add(limit.Window)) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (l RateLimiter) banResponse(key string, until time.Time, now time.Time) *Response {
+	return &Response{
+		StatusCode: http.StatusTeapot,
+		Body: []byte(fmt.Sprintf(
+			`{"code":-1003,"msg":"Way too many requests; %s banned until %d. Please use WebSocket Streams for live updates to avoid bans."}`,
+			key, until.UnixMilli(),
+		)),
+		Header: map[string][]string{"Retry-After": {retryAfter(until, now)}},
+	}
+}
+
+// usageHeader tells the weight client used in every limit that has a header. l.state.lock must be held.
+func (l RateLimiter) usageHeader(client *rateLimitedClient) map[string][]string {
+	header := map[string][]string{}
+	for i, limit := range l.limits {
+		if limit.Header != "" {
+			header[http.CanonicalHeaderKey(limit.Header)] = []string{strconv.Itoa(client.used[i])}
+		}
+	}
+	return header
+}
+
+// retryAfter returns the seconds from now to t, rounded up, as the value of a Retry-After header
+func retryAfter(t time.Time, now time.Time) string {
+	return strconv.FormatInt(int64((t.Sub(now)+time.Second-1)/time.Second), 10)
+}
+
+// Ensure RateLimitResponder implements Responder
+var _ Responder = (*RateLimitResponder)(nil)
+
+// RateLimitResponder responds as a responder does to the requests that its rate limiter lets through,
+// and tells the weight used in the headers of every response
+type RateLimitResponder struct {
+	responder Responder
+	limiter   RateLimiter
+	weight    int
+}
+
+func (r RateLimitResponder) Reset() {
+	r.limiter.Reset()
+	reset(r.responder)
+}
+
+func (r RateLimitResponder) Response(request Request) (Response, error) {
+	d := r.limiter.take(request, r.weight)
+	if d.rejection != nil {
+		response := *d.rejection
+		for key, values := range d.header {
+			response.Header[key] = values
+		}
+		return response, nil
+	}
+
+	response, err := r.responder.Response(request)
+	if err != nil {
+		return Response{}, err
+	}
+	response.Header = maps.Clone(response.Header)
+	if response.Header == nil {
+		response.Header = map[string][]string{}
+	}
+	for key, values := range d.header {
+		response.Header[key] = values
+	}
+	return response, nil
+}
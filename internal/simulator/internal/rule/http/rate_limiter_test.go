@@ -0,0 +1,137 @@
+package http
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIp(t *testing.T) {
+	assert.Equal(t, "1.2.3.4", ClientIp(Request{RemoteAddr: "1.2.3.4:5678"}))
+	assert.Equal(t, "::1", ClientIp(Request{RemoteAddr: "[::1]:5678"}))
+	assert.Equal(t, "pipe", ClientIp(Request{RemoteAddr: "pipe"}))
+}
+
+func TestClientHeader(t *testing.T) {
+	key := ClientHeader("X-MBX-APIKEY")
+
+	assert.Equal(t, "key", key(Request{Header: map[string][]string{"X-Mbx-Apikey": {"key"}}, RemoteAddr: "1.2.3.4:5678"}))
+	assert.Equal(t, "1.2.3.4", key(Request{RemoteAddr: "1.2.3.4:5678"}))
+}
+
+func TestRateLimitResponder_Response(t *testing.T) {
+	c := clock.NewFake(time.Date(2000, 1, 23, 12, 34, 30, 0, time.UTC))
+	ctx := clock.NewContext(context.Background(), c)
+	limiter := NewRateLimiter(RateLimit{Window: time.Minute, Limit: 10, Header: "X-MBX-USED-WEIGHT-1M"})
+	r := limiter.Limit(NewResponseFromString(200, "OK", 0), 4)
+	request := Request{RemoteAddr: "1.2.3.4:5678"}.WithContext(ctx)
+
+	for _, used := range []string{"4", "8"} {
+		response, err := r.Response(request)
+		require.NoError(t, err)
+		assert.Equal(t, Response{StatusCode: 200, Body: []byte("OK"), Header: map[string][]string{"X-Mbx-Used-Weight-1m": {used}}}, response)
+	}
+
+	response, err := r.Response(request)
+	require.NoError(t, err)
+	assert.Equal(t, 429, response.StatusCode)
+	assert.JSONEq(t, `{"code":-1003,"msg":"Too many requests; current limit of 1.2.3.4 request weight is 10 per 1m0s. Please use WebSocket Streams for live updates to avoid polling the API."}`, string(response.Body))
+	assert.Equal(t, map[string][]string{"X-Mbx-Used-Weight-1m": {"12"}, "Retry-After": {"30"}}, response.Header)
+
+	// Another client has a limit of its own
+	response, err = r.Response(Request{RemoteAddr: "5.6.7.8:5678"}.WithContext(ctx))
+	require.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	// The weight is counted anew in the next window
+	c.Advance(30 * time.Second)
+	response, err = r.Response(request)
+	require.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, []string{"4"}, response.Header["X-Mbx-Used-Weight-1m"])
+}
+
+func TestRateLimitResponder_Response_Ban(t *testing.T) {
+	c := clock.NewFake(time.Date(2000, 1, 23, 12, 34, 0, 0, time.UTC))
+	ctx := clock.NewContext(context.Background(), c)
+	limiter := NewRateLimiter(RateLimit{Window: time.Minute, Limit: 1}).WithBan(2, 2*time.Minute)
+	r := limiter.Limit(NewResponseFromString(200, "OK", 0), 1)
+	request := Request{RemoteAddr: "1.2.3.4:5678"}.WithContext(ctx)
+
+	var statusCodes []int
+	for range 4 {
+		response, err := r.Response(request)
+		require.NoError(t, err)
+		statusCodes = append(statusCodes, response.StatusCode)
+	}
+	assert.Equal(t, []int{200, 429, 418, 418}, statusCodes)
+
+	c.Advance(time.Minute)
+	response, err := r.Response(request)
+	require.NoError(t, err)
+	assert.Equal(t, 418, response.StatusCode)
+	assert.Equal(t, []string{"60"}, response.Header["Retry-After"])
+	assert.JSONEq(t, `{"code":-1003,"msg":"Way too many requests; 1.2.3.4 banned until 948630960000. Please use WebSocket Streams for live updates to avoid bans."}`, string(response.Body))
+
+	c.Advance(time.Minute)
+	response, err = r.Response(request)
+	require.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+}
+
+func TestRateLimiter_Reset(t *testing.T) {
+	c := clock.NewFake(time.Date(2000, 1, 23, 12, 34, 0, 0, time.UTC))
+	ctx := clock.NewContext(context.Background(), c)
+	limiter := NewRateLimiter(RateLimit{Window: time.Minute, Limit: 1}).WithBan(1, time.Hour)
+	r := limiter.Limit(NewResponseFromString(200, "OK", 0), 1)
+	request := Request{RemoteAddr: "1.2.3.4:5678"}.WithContext(ctx)
+
+	var statusCodes []int
+	for range 3 {
+		response, err := r.Response(request)
+		require.NoError(t, err)
+		statusCodes = append(statusCodes, response.StatusCode)
+	}
+	assert.Equal(t, []int{200, 418, 418}, statusCodes)
+
+	r.Reset()
+	response, err := r.Response(request)
+	require.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+}
+
+func TestNewRateLimiter_InvalidWindow(t *testing.T) {
+	assert.Panics(t, func() { NewRateLimiter(RateLimit{Limit: 1}) })
+	assert.Panics(t, func() {
+		NewRateLimiter(RateLimit{Window: time.Minute, Limit: 1}, RateLimit{Window: -time.Second, Limit: 1})
+	})
+}
+
+func TestRateLimiter_prune(t *testing.T) {
+	c := clock.NewFake(time.Date(2000, 1, 23, 12, 34, 0, 0, time.UTC))
+	ctx := clock.NewContext(context.Background(), c)
+	limiter := NewRateLimiter(RateLimit{Window: time.Minute, Limit: 1}).WithBan(1, time.Hour)
+	r := limiter.Limit(NewResponseFromString(200, "OK", 0), 1)
+	respond := func(addr string) int {
+		response, err := r.Response(Request{RemoteAddr: addr}.WithContext(ctx))
+		require.NoError(t, err)
+		return response.StatusCode
+	}
+
+	assert.Equal(t, 200, respond("1.1.1.1:1"))
+	assert.Equal(t, 200, respond("2.2.2.2:1"))
+	assert.Equal(t, 418, respond("2.2.2.2:1"))
+	assert.Len(t, limiter.state.clients, 2)
+
+	// The first client is forgotten once its window is over, while the second one is still banned
+	c.Advance(time.Minute)
+	assert.Equal(t, 200, respond("3.3.3.3:1"))
+	assert.Len(t, limiter.state.clients, 2)
+	assert.NotContains(t, limiter.state.clients, "1.1.1.1")
+	assert.Equal(t, 418, respond("2.2.2.2:1"))
+}
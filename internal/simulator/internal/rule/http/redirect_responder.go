@@ -11,14 +11,18 @@ import (
 	"time"
 
 	"alphanonce.com/exchangesimulator/internal/log"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/metrics"
 )
 
 // Ensure RedirectResponder implements Responder
 var _ Responder = (*RedirectResponder)(nil)
 
 type RedirectResponder struct {
-	targetUrl string
-	recordDir string
+	targetUrl   string
+	recordDir   string
+	compression string
+	clock       clock.Clock
 }
 
 func NewRedirectResponder(targetUrl string, recordDir string) RedirectResponder {
@@ -28,6 +32,18 @@ func NewRedirectResponder(targetUrl string, recordDir string) RedirectResponder
 	}
 }
 
+// WithCompression returns a copy of r that compresses the recorded responses by extension, either .gz or .zst
+func (r RedirectResponder) WithCompression(extension string) RedirectResponder {
+	r.compression = extension
+	return r
+}
+
+// WithClock returns a copy of r that names recordings by the time of c instead of the clock of the simulator
+func (r RedirectResponder) WithClock(c clock.Clock) RedirectResponder {
+	r.clock = c
+	return r
+}
+
 func (r RedirectResponder) Response(request Request) (Response, error) {
 	url, err := url.Parse(r.targetUrl)
 	if err != nil {
@@ -35,27 +51,33 @@ func (r RedirectResponder) Response(request Request) (Response, error) {
 	}
 	url.Path = request.Path
 	url.RawQuery = request.QueryString
-	req, err := http.NewRequest(request.Method, url.String(), bytes.NewReader(request.Body))
+	req, err := http.NewRequestWithContext(request.Context(), request.Method, url.String(), bytes.NewReader(request.Body))
 	if err != nil {
 		return Response{}, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header = request.Header
 
+	registry := metrics.FromContext(request.Context())
+	// The round trip takes as long as the network does, so it is timed on the wall clock
+	start := time.Now()
 	resp, err := http.DefaultTransport.RoundTrip(req)
 	if err != nil {
+		registry.Add(metrics.RedirectErrors, 1, "http")
 		return Response{}, fmt.Errorf("failed to reach target server: %w", err)
 	}
 	defer resp.Body.Close()
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
+		registry.Add(metrics.RedirectErrors, 1, "http")
 		return Response{}, fmt.Errorf("failed to read response data: %w", err)
 	}
+	registry.Observe(metrics.RedirectDuration, time.Since(start).Seconds(), "http")
 
 	response := Response{StatusCode: resp.StatusCode, Body: data}
 
 	if r.recordDir != "" {
-		err = r.saveResponseToFile(response)
+		err = r.saveResponseToFile(response, clock.Resolve(request.Context(), r.clock).Now())
 		if err != nil {
 			return Response{}, fmt.Errorf("failed to save to a file: %w", err)
 		}
@@ -63,13 +85,13 @@ func (r RedirectResponder) Response(request Request) (Response, error) {
 	return response, nil
 }
 
-func (r RedirectResponder) saveResponseToFile(response Response) error {
+func (r RedirectResponder) saveResponseToFile(response Response, t time.Time) error {
 	err := os.MkdirAll(r.recordDir, 0755)
 	if err != nil {
 		return err
 	}
 
-	filename := time.Now().Format(time.RFC3339Nano) + ".yaml"
+	filename := t.Format(time.RFC3339Nano) + ".yaml" + r.compression
 	path := filepath.Join(r.recordDir, filename)
 
 	err = WriteToFile(path, response)
@@ -5,7 +5,9 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -97,7 +99,7 @@ func TestRedirectResponder_saveResponseToFile(t *testing.T) {
 
 			responder := NewRedirectResponder("", tempDir)
 
-			err := responder.saveResponseToFile(tt.response)
+			err := responder.saveResponseToFile(tt.response, time.Now())
 			assert.NoError(t, err)
 
 			files, err := os.ReadDir(tempDir)
@@ -110,3 +112,26 @@ func TestRedirectResponder_saveResponseToFile(t *testing.T) {
 		})
 	}
 }
+
+func TestRedirectResponder_saveResponseToFile_Compressed(t *testing.T) {
+	for _, extension := range []string{".gz", ".zst"} {
+		t.Run(extension, func(t *testing.T) {
+			tempDir := t.TempDir()
+			response := Response{StatusCode: 200, Body: []byte("Hello, World!")}
+
+			responder := NewRedirectResponder("", tempDir).WithCompression(extension)
+
+			err := responder.saveResponseToFile(response, time.Now())
+			assert.NoError(t, err)
+
+			files, err := os.ReadDir(tempDir)
+			assert.NoError(t, err)
+			require.Len(t, files, 1)
+			assert.True(t, strings.HasSuffix(files[0].Name(), ".yaml"+extension))
+
+			read, err := ReadFromFile(filepath.Join(tempDir, files[0].Name()))
+			assert.NoError(t, err)
+			assert.Equal(t, response, read)
+		})
+	}
+}
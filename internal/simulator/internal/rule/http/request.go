@@ -1,5 +1,7 @@
 package http
 
+import "context"
+
 type Request struct {
 	Method      string
 	Host        string
@@ -7,4 +9,22 @@ type Request struct {
 	QueryString string
 	Header      map[string][]string
 	Body        []byte
+	// RemoteAddr is the address of the client, as IP:port
+	RemoteAddr string
+	ctx        context.Context
+}
+
+// Context returns the context of the request, which is cancelled when the client goes away
+// and carries the clock of the simulator. It is context.Background() if none was set.
+func (r Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// WithContext returns a copy of r with its context changed to ctx
+func (r Request) WithContext(ctx context.Context) Request {
+	r.ctx = ctx
+	return r
 }
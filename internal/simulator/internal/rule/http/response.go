@@ -3,8 +3,11 @@ package http
 import (
 	"errors"
 	"fmt"
-	"os"
+	"io/fs"
 	"strconv"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,6 +15,20 @@ import (
 type Response struct {
 	StatusCode int
 	Body       []byte
+	// Header is added to the headers of the response. It is not saved to the files.
+	Header map[string][]string
+	// Delivery tells how the response is written. It is not saved to the files.
+	Delivery Delivery
+}
+
+// Delivery tells how a response is written, e.g. to simulate a failing network. The zero value writes it at once.
+type Delivery struct {
+	// ChunkSize and ChunkInterval trickle the body, ChunkSize bytes every ChunkInterval; ChunkSize 0 writes it at once
+	ChunkSize     int
+	ChunkInterval time.Duration
+	// Reset resets the connection once ResetAfter bytes of the body are written
+	Reset      bool
+	ResetAfter int
 }
 
 func (r *Response) MarshalYAML() (any, error) {
@@ -73,13 +90,14 @@ func (r *Response) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
+// WriteToFile writes a response as YAML, compressed if path ends with .gz or .zst
 func WriteToFile(path string, response Response) error {
 	data, err := yaml.Marshal(&response)
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(path, data, 0644)
+	err = fileio.WriteFile(path, data)
 	if err != nil {
 		return err
 	}
@@ -87,8 +105,14 @@ func WriteToFile(path string, response Response) error {
 	return nil
 }
 
+// ReadFromFile reads a response written by WriteToFile
 func ReadFromFile(path string) (Response, error) {
-	data, err := os.ReadFile(path)
+	return ReadFromFS(fileio.OS, path)
+}
+
+// ReadFromFS reads a response written by WriteToFile from a file of fsys
+func ReadFromFS(fsys fs.FS, name string) (Response, error) {
+	data, err := fileio.ReadFile(fsys, name)
 	if err != nil {
 		return Response{}, err
 	}
@@ -1,32 +1,56 @@
 package http
 
 import (
+	"io/fs"
 	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
 )
 
 // Ensure ResponseFromFile implements Responder
 var _ Responder = (*ResponseFromFile)(nil)
 
 type ResponseFromFile struct {
+	fsys         fs.FS
 	filePath     string
 	responseTime time.Duration
+	clock        clock.Clock
 }
 
 func NewResponseFromFile(filePath string, responseTime time.Duration) ResponseFromFile {
 	return ResponseFromFile{
+		fsys:         fileio.OS,
 		filePath:     filePath,
 		responseTime: responseTime,
 	}
 }
 
-func (r ResponseFromFile) Response(_ Request) (Response, error) {
-	startTime := time.Now()
+// WithClock returns a copy of r that waits on c instead of the clock of the simulator
+func (r ResponseFromFile) WithClock(c clock.Clock) ResponseFromFile {
+	r.clock = c
+	return r
+}
+
+// WithFS returns a copy of r that reads filePath from fsys, e.g. an embed.FS, instead of the operating system
+func (r ResponseFromFile) WithFS(fsys fs.FS) ResponseFromFile {
+	r.fsys = fsys
+	return r
+}
 
-	response, err := ReadFromFile(r.filePath)
+func (r ResponseFromFile) Response(request Request) (Response, error) {
+	ctx := request.Context()
+	c := clock.Resolve(ctx, r.clock)
+	startTime := c.Now()
+
+	response, err := ReadFromFS(r.fsys, r.filePath)
 	if err != nil {
 		return response, err
 	}
 
-	time.Sleep(time.Until(startTime.Add(r.responseTime)))
+	err = c.SleepUntil(ctx, startTime.Add(r.responseTime))
+	if err != nil {
+		return Response{}, err
+	}
 	return response, nil
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/stretchr/testify/assert"
@@ -53,3 +54,30 @@ func TestResponseFromFile_Response(t *testing.T) {
 		})
 	}
 }
+
+func TestResponseFromFile_Response_Compressed(t *testing.T) {
+	for _, filename := range []string{"response.yaml.gz", "response.yaml.zst"} {
+		t.Run(filename, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), filename)
+			expectedResponse := Response{StatusCode: 200, Body: []byte("Hello, World!")}
+			err := WriteToFile(path, expectedResponse)
+			assert.NoError(t, err)
+
+			response, err := NewResponseFromFile(path, 0).Response(Request{})
+
+			assert.NoError(t, err)
+			assert.Equal(t, expectedResponse, response)
+		})
+	}
+}
+
+func TestResponseFromFile_WithFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"http/GET/api/v3/time.yaml": {Data: []byte("status: 200\nbody: |-\n    Hello, World!\n")},
+	}
+
+	response, err := NewResponseFromFile("http/GET/api/v3/time.yaml", 0).WithFS(fsys).Response(Request{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, Response{StatusCode: 200, Body: []byte("Hello, World!")}, response)
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
+)
+
+// Ensure ResponseFromFiles implements Responder
+var _ Responder = (*ResponseFromFiles)(nil)
+
+// ResponseFromFiles serves the responses of a directory written by RedirectResponder as time goes by.
+// Each response is named by the RFC 3339 time it was recorded at and may be gzip or zstd compressed.
+// The latest response recorded at or before the time of the clock is served, or the earliest one if there is none,
+// so that a REST snapshot matches the WebSocket updates replayed on the same clock.
+type ResponseFromFiles struct {
+	fsys    fs.FS
+	dirPath string
+	clock   clock.Clock
+}
+
+func NewResponseFromFiles(dirPath string) ResponseFromFiles {
+	return ResponseFromFiles{
+		fsys:    fileio.OS,
+		dirPath: dirPath,
+	}
+}
+
+// WithFS returns a copy of r that reads dirPath from fsys, e.g. an embed.FS, instead of the operating system
+func (r ResponseFromFiles) WithFS(fsys fs.FS) ResponseFromFiles {
+	r.fsys = fsys
+	return r
+}
+
+// WithClock returns a copy of r that picks the responses by the time of c instead of the clock of the simulator
+func (r ResponseFromFiles) WithClock(c clock.Clock) ResponseFromFiles {
+	r.clock = c
+	return r
+}
+
+func (r ResponseFromFiles) Response(request Request) (Response, error) {
+	entries, err := fs.ReadDir(r.fsys, r.dirPath)
+	if err != nil {
+		return Response{}, err
+	}
+
+	now := clock.Resolve(request.Context(), r.clock).Now()
+	var latest, earliest string
+	var latestTime, earliestTime time.Time
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(fileio.TrimExtension(e.Name()), ".yaml")
+		if e.IsDir() || !ok {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, name)
+		if err != nil {
+			return Response{}, err
+		}
+
+		if !t.After(now) && (latest == "" || t.After(latestTime)) {
+			latest, latestTime = e.Name(), t
+		}
+		if earliest == "" || t.Before(earliestTime) {
+			earliest, earliestTime = e.Name(), t
+		}
+	}
+
+	switch {
+	case latest != "":
+		return ReadFromFS(r.fsys, path.Join(r.dirPath, latest))
+	case earliest != "":
+		return ReadFromFS(r.fsys, path.Join(r.dirPath, earliest))
+	default:
+		return Response{}, errors.New("no response recorded in " + r.dirPath)
+	}
+}
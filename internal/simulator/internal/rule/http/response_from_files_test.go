@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewResponseFromFiles(t *testing.T) {
+	dirPath := "/test/path"
+
+	r := NewResponseFromFiles(dirPath)
+
+	assert.Equal(t, dirPath, r.dirPath)
+	assert.Nil(t, r.clock)
+}
+
+func TestResponseFromFiles_Response(t *testing.T) {
+	fsys := fstest.MapFS{
+		"depth/2023-07-22T10:00:00.000000Z.yaml": {Data: []byte("status: 200\nbody: first\n")},
+		"depth/2023-07-22T10:00:10.000000Z.yaml": {Data: []byte("status: 200\nbody: second\n")},
+		"depth/README.md":                        {Data: []byte("not a response")},
+	}
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		expectedBody string
+	}{
+		{"Before the first", time.Date(2023, 7, 22, 9, 0, 0, 0, time.UTC), "first"},
+		{"At the first", time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC), "first"},
+		{"Between", time.Date(2023, 7, 22, 10, 0, 9, 0, time.UTC), "first"},
+		{"After the last", time.Date(2023, 7, 22, 11, 0, 0, 0, time.UTC), "second"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResponseFromFiles("depth").WithFS(fsys).WithClock(clock.NewFake(tt.now))
+
+			response, err := r.Response(Request{})
+
+			assert.NoError(t, err)
+			assert.Equal(t, Response{StatusCode: 200, Body: []byte(tt.expectedBody)}, response)
+		})
+	}
+}
+
+func TestResponseFromFiles_Response_ContextClock(t *testing.T) {
+	fsys := fstest.MapFS{
+		"depth/2023-07-22T10:00:00.000000Z.yaml": {Data: []byte("status: 200\nbody: first\n")},
+		"depth/2023-07-22T10:00:10.000000Z.yaml": {Data: []byte("status: 200\nbody: second\n")},
+	}
+	c := clock.NewFake(time.Date(2023, 7, 22, 10, 0, 10, 0, time.UTC))
+	request := Request{}.WithContext(clock.NewContext(context.Background(), c))
+
+	response, err := NewResponseFromFiles("depth").WithFS(fsys).Response(request)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Response{StatusCode: 200, Body: []byte("second")}, response)
+}
+
+func TestResponseFromFiles_Response_Error(t *testing.T) {
+	fsys := fstest.MapFS{
+		"empty/README.md": {Data: []byte("not a response")},
+	}
+
+	_, err := NewResponseFromFiles("empty").WithFS(fsys).Response(Request{})
+	assert.Error(t, err)
+
+	_, err = NewResponseFromFiles("missing").WithFS(fsys).Response(Request{})
+	assert.Error(t, err)
+}
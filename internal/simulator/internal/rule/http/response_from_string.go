@@ -2,6 +2,8 @@ package http
 
 import (
 	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
 )
 
 // Ensure ResponseFromString implements Responder
@@ -11,6 +13,7 @@ type ResponseFromString struct {
 	statusCode   int
 	body         string
 	responseTime time.Duration
+	clock        clock.Clock
 }
 
 func NewResponseFromString(statusCode int, body string, responseTime time.Duration) ResponseFromString {
@@ -21,11 +24,21 @@ func NewResponseFromString(statusCode int, body string, responseTime time.Durati
 	}
 }
 
-func (r ResponseFromString) Response(_ Request) (Response, error) {
+// WithClock returns a copy of r that waits on c instead of the clock of the simulator
+func (r ResponseFromString) WithClock(c clock.Clock) ResponseFromString {
+	r.clock = c
+	return r
+}
+
+func (r ResponseFromString) Response(request Request) (Response, error) {
 	resp := Response{
 		StatusCode: r.statusCode,
 		Body:       []byte(r.body),
 	}
-	time.Sleep(r.responseTime)
+	ctx := request.Context()
+	err := clock.Sleep(ctx, clock.Resolve(ctx, r.clock), r.responseTime)
+	if err != nil {
+		return Response{}, err
+	}
 	return resp, nil
 }
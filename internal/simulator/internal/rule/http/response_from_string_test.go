@@ -1,10 +1,14 @@
 package http
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewResponseFromString(t *testing.T) {
@@ -31,3 +35,29 @@ func TestResponseFromString_Response(t *testing.T) {
 	assert.GreaterOrEqual(t, duration, 50*time.Millisecond)
 	assert.LessOrEqual(t, duration, 100*time.Millisecond)
 }
+
+func TestResponseFromString_Response_ContextClock(t *testing.T) {
+	c := clock.NewFake(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC))
+	r := NewResponseFromString(200, "OK", time.Hour)
+	request := Request{}.WithContext(clock.NewContext(context.Background(), c))
+
+	done := make(chan error)
+	go func() {
+		_, err := r.Response(request)
+		done <- err
+	}()
+	require.NoError(t, c.WaitForSleepers(context.Background(), 1))
+	c.Advance(time.Hour)
+
+	assert.NoError(t, <-done)
+}
+
+func TestResponseFromString_Response_ContextCancellation(t *testing.T) {
+	r := NewResponseFromString(200, "OK", time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := r.Response(Request{}.WithContext(ctx))
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -15,6 +15,19 @@ type Responder interface {
 	Response(Request) (Response, error)
 }
 
+// Resetter is implemented by the rules and responders that keep state as they respond, such as rate limits,
+// to put it back as it was when they were made, e.g. as the simulator is reset. The wrappers of a rule or responder reset it.
+type Resetter interface {
+	Reset()
+}
+
+// reset resets v if it is a Resetter
+func reset(v any) {
+	if r, ok := v.(Resetter); ok {
+		r.Reset()
+	}
+}
+
 // Ensure RuleImpl implements Rule
 var _ Rule = (*RuleImpl)(nil)
 
@@ -26,3 +39,7 @@ type RuleImpl struct {
 func NewRule(requestMatcher RequestMatcher, responder Responder) RuleImpl {
 	return RuleImpl{RequestMatcher: requestMatcher, Responder: responder}
 }
+
+func (r RuleImpl) Reset() {
+	reset(r.Responder)
+}
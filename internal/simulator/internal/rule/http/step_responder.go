@@ -0,0 +1,65 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+)
+
+// Ensure StepResponder implements Responder
+var _ Responder = (*StepResponder)(nil)
+
+// StepResponder steps a lockstep timeline on every request and responds with the time it stepped to,
+// e.g. {"time":"2023-07-22T10:00:01Z","stepped":true}. stepped is false if nothing was left to step to.
+// If the replays on the timeline are not ready to step within timeout, e.g. as one is stuck writing to a client,
+// it responds with 503 Service Unavailable instead. A timeout of 0 waits for as long as the request lasts.
+type StepResponder struct {
+	timeline *clock.Fake
+	timeout  time.Duration
+	// start is the time of the timeline when the responder was made
+	start time.Time
+}
+
+func NewStepResponder(timeline *clock.Fake, timeout time.Duration) StepResponder {
+	return StepResponder{
+		timeline: timeline,
+		timeout:  timeout,
+		start:    timeline.Now(),
+	}
+}
+
+// Reset moves the timeline back to the time it was at when r was made
+func (r StepResponder) Reset() {
+	r.timeline.Set(r.start)
+}
+
+type stepResponseJson struct {
+	Time    time.Time `json:"time"`
+	Stepped bool      `json:"stepped"`
+}
+
+func (r StepResponder) Response(request Request) (Response, error) {
+	ctx := request.Context()
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	stepped, err := r.timeline.Step(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Response{StatusCode: 503, Body: []byte(`{"error":"timed out waiting for the replays to be ready to step"}`)}, nil
+	}
+	if err != nil {
+		return Response{}, err
+	}
+
+	body, err := json.Marshal(stepResponseJson{Time: r.timeline.Now(), Stepped: stepped})
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{StatusCode: 200, Body: body}, nil
+}
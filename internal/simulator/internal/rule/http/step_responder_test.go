@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepResponder_Response(t *testing.T) {
+	now := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	timeline := clock.NewFake(now)
+	r := NewStepResponder(timeline, time.Second)
+
+	done := make(chan error)
+	go func() {
+		done <- clock.Sleep(context.Background(), timeline, time.Second)
+	}()
+	require.NoError(t, timeline.WaitForSleepers(context.Background(), 1))
+
+	response, err := r.Response(Request{})
+	assert.NoError(t, err)
+	assert.Equal(t, Response{StatusCode: 200, Body: []byte(`{"time":"2023-07-22T10:00:01Z","stepped":true}`)}, response)
+	assert.NoError(t, <-done)
+
+	response, err = r.Response(Request{})
+	assert.NoError(t, err)
+	assert.Equal(t, Response{StatusCode: 200, Body: []byte(`{"time":"2023-07-22T10:00:01Z","stepped":false}`)}, response)
+}
+
+func TestStepResponder_Response_Timeout(t *testing.T) {
+	timeline := clock.NewFake(time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC))
+	// An attached replay that never sleeps
+	_, detach := clock.Attach(timeline)
+	defer detach()
+
+	response, err := NewStepResponder(timeline, time.Millisecond).Response(Request{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 503, response.StatusCode)
+}
+
+func TestStepResponder_Response_NoTimeout(t *testing.T) {
+	now := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	timeline := clock.NewFake(now)
+	// An attached replay that takes a while to be ready to step
+	attached, detach := clock.Attach(timeline)
+	defer detach()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = clock.Sleep(context.Background(), attached, time.Second)
+	}()
+
+	response, err := NewStepResponder(timeline, 0).Response(Request{})
+	assert.NoError(t, err)
+	assert.Equal(t, Response{StatusCode: 200, Body: []byte(`{"time":"2023-07-22T10:00:01Z","stepped":true}`)}, response)
+
+	// It waits until the request is done
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err = NewStepResponder(timeline, 0).Response(Request{}.WithContext(ctx))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestStepResponder_Reset(t *testing.T) {
+	now := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	timeline := clock.NewFake(now)
+	r := NewStepResponder(timeline, time.Second)
+
+	timeline.Advance(time.Minute)
+	r.Reset()
+	assert.Equal(t, now, timeline.Now())
+}
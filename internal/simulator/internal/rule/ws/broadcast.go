@@ -0,0 +1,182 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// Ensure Broadcast implements MessageHandler
+var _ MessageHandler = (*Broadcast)(nil)
+
+// Broadcast runs a feed once for all its subscribers, e.g. the replay of a topic, and writes every message of it to every
+// connection it handles, as an exchange broadcasts one market to all its subscribers.
+// It is meant as the update handler of a subscription rule, or a feed of a topic subscription rule.
+//
+// The feed starts when the first connection is handled and stops once the last one is done. If the feed ends on its own,
+// e.g. as a replay runs out of records, it is started again for the next connection handled.
+// A connection joining later receives the messages from then on, preceded by the snapshot if there is one.
+// Every connection is written to at its own pace, so that a slow one holds up neither the feed nor the others.
+// Handling a connection returns once it is done, or once a message fails to be written to it.
+type Broadcast struct {
+	feed     MessageHandler
+	snapshot MessageHandler
+	state    *broadcastState
+}
+
+type broadcastState struct {
+	lock        sync.Mutex
+	subscribers []*broadcastSubscriber
+	// feed is the feed running, or nil if none is
+	feed *broadcastFeed
+}
+
+type broadcastFeed struct {
+	stop func()
+}
+
+// broadcastSubscriber keeps the messages of the feed yet to be written to a connection
+type broadcastSubscriber struct {
+	conn    Connection
+	lock    sync.Mutex
+	backlog []Message
+	// ready is signalled as messages are added to backlog
+	ready chan struct{}
+}
+
+func NewBroadcast(feed MessageHandler) Broadcast {
+	return Broadcast{
+		feed:  feed,
+		state: &broadcastState{},
+	}
+}
+
+// WithSnapshot returns a copy of b that has snapshot write what a connection needs to know before the feed, e.g. the order book,
+// as soon as it joins. No message of the feed is written to the connection until the snapshot is; the messages broadcast
+// while the snapshot is written follow it.
+func (b Broadcast) WithSnapshot(snapshot MessageHandler) Broadcast {
+	b.snapshot = snapshot
+	return b
+}
+
+func (b Broadcast) Handle(ctx context.Context, message Message, connClient Connection, connServer Connection) error {
+	subscriber := &broadcastSubscriber{conn: connClient, ready: make(chan struct{}, 1)}
+	b.join(ctx, message, subscriber)
+	defer b.leave(subscriber)
+
+	if b.snapshot != nil {
+		err := b.snapshot.Handle(ctx, message, connClient, connServer)
+		if err != nil {
+			return err
+		}
+	}
+	return subscriber.forward(ctx)
+}
+
+func (b Broadcast) Reset() {
+	reset(b.feed)
+	reset(b.snapshot)
+}
+
+// join adds s to the subscribers, starting the feed if it is not running
+func (b Broadcast) join(ctx context.Context, message Message, s *broadcastSubscriber) {
+	b.state.lock.Lock()
+	defer b.state.lock.Unlock()
+
+	b.state.subscribers = append(b.state.subscribers, s)
+	if b.state.feed != nil {
+		return
+	}
+
+	// The feed outlives the connection that started it, but runs on the same clock
+	stop, done := runUpdates(context.WithoutCancel(ctx), b.feed, message, broadcastConnection{b.state}, nil)
+	feed := &broadcastFeed{stop: stop}
+	b.state.feed = feed
+	go func() {
+		<-done
+		b.state.lock.Lock()
+		defer b.state.lock.Unlock()
+
+		// The feed ended on its own, unless it was stopped and another one started since
+		if b.state.feed == feed {
+			b.state.feed = nil
+		}
+	}()
+}
+
+// leave removes s from the subscribers, stopping the feed once there are none
+func (b Broadcast) leave(s *broadcastSubscriber) {
+	b.state.lock.Lock()
+	defer b.state.lock.Unlock()
+
+	b.state.subscribers = slices.DeleteFunc(b.state.subscribers, func(other *broadcastSubscriber) bool { return other == s })
+	if len(b.state.subscribers) == 0 && b.state.feed != nil {
+		b.state.feed.stop()
+		b.state.feed = nil
+	}
+}
+
+// Subscribers returns how many connections the feed is broadcast to
+func (b Broadcast) Subscribers() int {
+	b.state.lock.Lock()
+	defer b.state.lock.Unlock()
+
+	return len(b.state.subscribers)
+}
+
+// push adds message to the backlog of s
+func (s *broadcastSubscriber) push(message Message) {
+	s.lock.Lock()
+	s.backlog = append(s.backlog, message)
+	s.lock.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// forward writes the backlog of s to its connection as it fills, until ctx is done or a message fails to be written
+func (s *broadcastSubscriber) forward(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-s.ready:
+		}
+
+		s.lock.Lock()
+		messages := s.backlog
+		s.backlog = nil
+		s.lock.Unlock()
+
+		for _, message := range messages {
+			err := s.conn.Write(ctx, message)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// broadcastConnection is the connection the feed of a broadcast writes to
+type broadcastConnection struct {
+	state *broadcastState
+}
+
+func (c broadcastConnection) Read(context.Context) (Message, error) {
+	return Message{}, errors.New("a broadcast cannot be read from")
+}
+
+// Write adds message to the backlog of every subscriber, which writes it to its connection
+func (c broadcastConnection) Write(ctx context.Context, message Message) error {
+	c.state.lock.Lock()
+	subscribers := slices.Clone(c.state.subscribers)
+	c.state.lock.Unlock()
+
+	for _, s := range subscribers {
+		s.push(message)
+	}
+	return context.Cause(ctx)
+}
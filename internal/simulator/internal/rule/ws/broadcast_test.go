@@ -0,0 +1,161 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// startedFeed returns a feed that hands over the connection it writes to, and runs until its context is done
+func startedFeed(t *testing.T) (*MockMessageHandler, chan Connection) {
+	conns := make(chan Connection, 1)
+	feed := NewMockMessageHandler(t)
+	feed.On("Handle", mock.Anything, mock.Anything, mock.Anything, nil).Return(nil).Run(func(args mock.Arguments) {
+		conns <- args.Get(2).(Connection)
+		<-args.Get(0).(context.Context).Done()
+	}).Once()
+	return feed, conns
+}
+
+// recordingConnection returns a connection that sends what is written to it
+func recordingConnection(t *testing.T) (*MockConnection, chan string) {
+	written := make(chan string, 10)
+	conn := NewMockConnection(t)
+	conn.On("Write", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		written <- string(args.Get(1).(Message).Data)
+	}).Maybe()
+	return conn, written
+}
+
+func text(data string) Message {
+	return Message{Type: MessageText, Data: []byte(data)}
+}
+
+func TestBroadcast_Handle(t *testing.T) {
+	feed, feedConns := startedFeed(t)
+	b := NewBroadcast(feed).WithSnapshot(NewMessageFromString(MessageText, "snapshot", 0))
+
+	// The first subscriber starts the feed
+	conn1, written1 := recordingConnection(t)
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	done1 := make(chan error)
+	go func() { done1 <- b.Handle(ctx1, text("subscribe"), conn1, nil) }()
+	feedConn := <-feedConns
+	assert.Equal(t, "snapshot", <-written1)
+	require.NoError(t, feedConn.Write(context.Background(), text("update 1")))
+	assert.Equal(t, "update 1", <-written1)
+
+	// The second subscriber joins later, and the feed is not started again
+	conn2, written2 := recordingConnection(t)
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	done2 := make(chan error)
+	go func() { done2 <- b.Handle(ctx2, text("subscribe"), conn2, nil) }()
+	assert.Equal(t, "snapshot", <-written2)
+	assert.Eventually(t, func() bool { return b.Subscribers() == 2 }, time.Second, time.Millisecond)
+
+	require.NoError(t, feedConn.Write(context.Background(), text("update 2")))
+	assert.Equal(t, "update 2", <-written1)
+	assert.Equal(t, "update 2", <-written2)
+
+	// The first subscriber leaves, and the feed keeps going for the second one
+	cancel1()
+	assert.ErrorIs(t, <-done1, context.Canceled)
+	require.NoError(t, feedConn.Write(context.Background(), text("update 3")))
+	assert.Equal(t, "update 3", <-written2)
+	assert.Empty(t, written1)
+
+	cancel2()
+	assert.ErrorIs(t, <-done2, context.Canceled)
+	assert.Equal(t, 0, b.Subscribers())
+}
+
+func TestBroadcast_Handle_WriteError(t *testing.T) {
+	feed, feedConns := startedFeed(t)
+	b := NewBroadcast(feed)
+	writeErr := errors.New("write error")
+
+	conn := NewMockConnection(t)
+	conn.On("Write", mock.Anything, text("update")).Return(writeErr).Once()
+	done := make(chan error)
+	go func() { done <- b.Handle(context.Background(), text("subscribe"), conn, nil) }()
+	feedConn := <-feedConns
+
+	require.NoError(t, feedConn.Write(context.Background(), text("update")))
+	assert.ErrorIs(t, <-done, writeErr)
+	assert.Equal(t, 0, b.Subscribers())
+}
+
+func TestBroadcast_Handle_SlowSubscriber(t *testing.T) {
+	feed, feedConns := startedFeed(t)
+	b := NewBroadcast(feed)
+
+	// The first subscriber is stuck writing the first update until released
+	release := make(chan struct{})
+	slowConn := NewMockConnection(t)
+	slowConn.On("Write", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		<-release
+	}).Maybe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Handle(ctx, text("subscribe"), slowConn, nil)
+	feedConn := <-feedConns
+
+	conn, written := recordingConnection(t)
+	go b.Handle(ctx, text("subscribe"), conn, nil)
+	require.Eventually(t, func() bool { return b.Subscribers() == 2 }, time.Second, time.Millisecond)
+
+	// Neither the feed nor the other subscriber waits for it
+	for _, update := range []string{"update 1", "update 2"} {
+		require.NoError(t, feedConn.Write(context.Background(), text(update)))
+		assert.Equal(t, update, <-written)
+	}
+	close(release)
+}
+
+func TestBroadcast_Handle_FeedLifetime(t *testing.T) {
+	// The feed runs until its context is done or it is ended, and is started again for the next subscriber once it ends
+	end := make(chan struct{})
+	stopped := make(chan struct{}, 2)
+	feed := NewMockMessageHandler(t)
+	feed.On("Handle", mock.Anything, mock.Anything, mock.Anything, nil).Return(nil).Run(func(args mock.Arguments) {
+		select {
+		case <-args.Get(0).(context.Context).Done():
+		case <-end:
+		}
+		stopped <- struct{}{}
+	}).Times(3)
+	b := NewBroadcast(feed)
+
+	// The feed stops once the last subscriber leaves
+	conn, _ := recordingConnection(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- b.Handle(ctx, text("subscribe"), conn, nil) }()
+	require.Eventually(t, func() bool { return b.Subscribers() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+	<-stopped
+
+	// The feed ends on its own, and the next subscriber starts it again
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go b.Handle(ctx, text("subscribe"), conn, nil)
+	require.Eventually(t, func() bool { return b.Subscribers() == 1 }, time.Second, time.Millisecond)
+	end <- struct{}{}
+	<-stopped
+	require.Eventually(t, func() bool {
+		b.state.lock.Lock()
+		defer b.state.lock.Unlock()
+		return b.state.feed == nil
+	}, time.Second, time.Millisecond)
+
+	go b.Handle(ctx, text("subscribe"), conn, nil)
+	require.Eventually(t, func() bool { return b.Subscribers() == 2 }, time.Second, time.Millisecond)
+	cancel()
+	<-stopped
+}
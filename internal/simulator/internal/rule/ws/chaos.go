@@ -0,0 +1,208 @@
+package ws
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+)
+
+// Chaos tells how the messages written by a handler are disturbed, to test how clients cope with an unreliable feed.
+// Rates are probabilities from 0 to 1, and the zero value leaves the messages alone.
+type Chaos struct {
+	// DropRate is how often a message is not written at all
+	DropRate float64
+	// DuplicateRate is how often a message is written twice
+	DuplicateRate float64
+	// ReorderWindow is how many messages are held back, of which a random one is written whenever another comes;
+	// 0 and 1 keep the messages in order. The messages still held once the handler returns are written in order.
+	ReorderWindow int
+	// Jitter is the longest a message is delayed by; the delay of each message is random up to it
+	Jitter time.Duration
+	// CorruptRate is how often a byte of a message is changed
+	CorruptRate float64
+	// Seed seeds the chaos, so that every time a handler is run, its messages are disturbed the same way
+	Seed uint64
+}
+
+// Ensure ChaosHandler implements MessageHandler
+var _ MessageHandler = (*ChaosHandler)(nil)
+
+// Ensure ChaosHandler implements clockAttacher
+var _ clockAttacher = (*ChaosHandler)(nil)
+
+// ChaosHandler runs a handler and disturbs the messages it writes to the client as its Chaos tells,
+// e.g. to make sequence gaps and duplicates in a replayed order book feed
+type ChaosHandler struct {
+	handler MessageHandler
+	chaos   Chaos
+	clock   clock.Clock
+}
+
+func NewChaosHandler(handler MessageHandler, chaos Chaos) ChaosHandler {
+	return ChaosHandler{handler: handler, chaos: chaos}
+}
+
+// WithClock returns a copy of h that delays the messages on c rather than on the clock of the simulator.
+// The handler runs on c too, unless it has a clock of its own.
+func (h ChaosHandler) WithClock(c clock.Clock) ChaosHandler {
+	h.clock = c
+	return h
+}
+
+func (h ChaosHandler) Handle(ctx context.Context, message Message, connClient Connection, connServer Connection) error {
+	// The delays and the handler sleep on the same attached clock, so that a stepped clock waits for either
+	c, detach := clock.Attach(clock.Resolve(ctx, h.clock))
+	defer detach()
+	conn := &chaosConnection{
+		Connection: connClient,
+		chaos:      h.chaos,
+		clock:      c,
+		random:     rand.New(rand.NewPCG(h.chaos.Seed, 0)),
+	}
+
+	err := h.handler.Handle(clock.NewContext(ctx, c), message, conn, connServer)
+	if err != nil {
+		return err
+	}
+	return conn.flush(ctx)
+}
+
+func (h ChaosHandler) Reset() {
+	reset(h.handler)
+}
+
+// attachClock attaches the clock that both the delays and the handler run on before the handler runs
+func (h ChaosHandler) attachClock(ctx context.Context) (MessageHandler, func()) {
+	var detach func()
+	h.clock, detach = clock.Attach(clock.Resolve(ctx, h.clock))
+	return h, detach
+}
+
+// Ensure chaosConnection implements Closer
+var _ Closer = (*chaosConnection)(nil)
+
+// chaosConnection is a connection that disturbs the messages written to it
+type chaosConnection struct {
+	Connection
+	chaos Chaos
+	clock clock.Clock
+
+	lock   sync.Mutex
+	random *rand.Rand
+	held   []Message
+}
+
+func (c *chaosConnection) Write(ctx context.Context, message Message) error {
+	c.lock.Lock()
+	writes := c.disturb(message)
+	c.lock.Unlock()
+
+	return c.write(ctx, writes)
+}
+
+// chaosWrite is a message to write after its delay
+type chaosWrite struct {
+	message Message
+	delay   time.Duration
+}
+
+// disturb draws what becomes of message: the messages to write in its place, and their delays. c.lock must be held.
+func (c *chaosConnection) disturb(message Message) []chaosWrite {
+	if c.happens(c.chaos.DropRate) {
+		return nil
+	}
+	if c.happens(c.chaos.CorruptRate) {
+		message = c.corrupt(message)
+	}
+	copies := 1
+	if c.happens(c.chaos.DuplicateRate) {
+		copies = 2
+	}
+
+	var writes []chaosWrite
+	for range copies {
+		if m, ok := c.hold(message); ok {
+			writes = append(writes, chaosWrite{message: m, delay: c.jitter()})
+		}
+	}
+	return writes
+}
+
+// happens tells whether something that happens at rate does this time. c.lock must be held.
+func (c *chaosConnection) happens(rate float64) bool {
+	return rate > 0 && c.random.Float64() < rate
+}
+
+// corrupt returns a copy of message with a random byte changed. c.lock must be held.
+func (c *chaosConnection) corrupt(message Message) Message {
+	if len(message.Data) == 0 {
+		return message
+	}
+
+	data := slices.Clone(message.Data)
+	data[c.random.IntN(len(data))] ^= byte(1 + c.random.IntN(255))
+	return Message{Type: message.Type, Data: data}
+}
+
+// hold adds message to the reorder window, and returns a random message of it to write once it is full. c.lock must be held.
+func (c *chaosConnection) hold(message Message) (Message, bool) {
+	if c.chaos.ReorderWindow <= 1 {
+		return message, true
+	}
+
+	c.held = append(c.held, message)
+	if len(c.held) < c.chaos.ReorderWindow {
+		return Message{}, false
+	}
+	i := c.random.IntN(len(c.held))
+	message = c.held[i]
+	c.held = slices.Delete(c.held, i, i+1)
+	return message, true
+}
+
+// jitter draws the delay of a message. c.lock must be held.
+func (c *chaosConnection) jitter() time.Duration {
+	if c.chaos.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(c.random.Int64N(int64(c.chaos.Jitter) + 1))
+}
+
+// write writes every message of writes after its delay. The delays are slept without c.lock held,
+// so that a message delayed does not hold up the chaos drawn for the others.
+func (c *chaosConnection) write(ctx context.Context, writes []chaosWrite) error {
+	for _, w := range writes {
+		if w.delay > 0 {
+			err := clock.Sleep(ctx, c.clock, w.delay)
+			if err != nil {
+				return err
+			}
+		}
+		err := c.Connection.Write(ctx, w.message)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush writes the messages held in the reorder window in order
+func (c *chaosConnection) flush(ctx context.Context) error {
+	c.lock.Lock()
+	writes := make([]chaosWrite, 0, len(c.held))
+	for _, message := range c.held {
+		writes = append(writes, chaosWrite{message: message, delay: c.jitter()})
+	}
+	c.held = nil
+	c.lock.Unlock()
+
+	return c.write(ctx, writes)
+}
+
+func (c *chaosConnection) Close(closure Closure) error {
+	return CloseConnection(c.Connection, closure)
+}
@@ -0,0 +1,177 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// countingHandler returns a handler that writes the numbers from 0 to n-1
+func countingHandler(t *testing.T, n int) *MockMessageHandler {
+	h := NewMockMessageHandler(t)
+	h.On("Handle", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		for i := range n {
+			err := args.Get(2).(Connection).Write(args.Get(0).(context.Context), text(fmt.Sprint(i)))
+			require.NoError(t, err)
+		}
+	})
+	return h
+}
+
+// chaosWritten returns what a handler writing the numbers from 0 to n-1 writes through chaos
+func chaosWritten(t *testing.T, n int, chaos Chaos) []string {
+	var written []string
+	conn := NewMockConnection(t)
+	conn.On("Write", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		written = append(written, string(args.Get(1).(Message).Data))
+	}).Maybe()
+	h := NewChaosHandler(countingHandler(t, n), chaos)
+
+	require.NoError(t, h.Handle(context.Background(), Message{}, conn, nil))
+	return written
+}
+
+func numbers(n int) []string {
+	var result []string
+	for i := range n {
+		result = append(result, fmt.Sprint(i))
+	}
+	return result
+}
+
+func TestChaosHandler_Handle(t *testing.T) {
+	tests := []struct {
+		name  string
+		chaos Chaos
+		check func(t *testing.T, written []string)
+	}{
+		{
+			name:  "No chaos",
+			chaos: Chaos{},
+			check: func(t *testing.T, written []string) { assert.Equal(t, numbers(8), written) },
+		},
+		{
+			name:  "Drop every message",
+			chaos: Chaos{DropRate: 1},
+			check: func(t *testing.T, written []string) { assert.Empty(t, written) },
+		},
+		{
+			name:  "Drop some messages",
+			chaos: Chaos{DropRate: 0.5, Seed: 1},
+			check: func(t *testing.T, written []string) {
+				assert.Less(t, len(written), 8)
+				assert.NotEmpty(t, written)
+				assert.True(t, slices.IsSortedFunc(written, func(a, b string) int { return slices.Index(numbers(8), a) - slices.Index(numbers(8), b) }))
+			},
+		},
+		{
+			name:  "Duplicate every message",
+			chaos: Chaos{DuplicateRate: 1},
+			check: func(t *testing.T, written []string) {
+				assert.Equal(t, []string{"0", "0", "1", "1", "2", "2", "3", "3", "4", "4", "5", "5", "6", "6", "7", "7"}, written)
+			},
+		},
+		{
+			name:  "Reorder",
+			chaos: Chaos{ReorderWindow: 3, Seed: 1},
+			check: func(t *testing.T, written []string) {
+				assert.NotEqual(t, numbers(8), written)
+				assert.ElementsMatch(t, numbers(8), written)
+			},
+		},
+		{
+			name:  "Corrupt every message",
+			chaos: Chaos{CorruptRate: 1},
+			check: func(t *testing.T, written []string) {
+				require.Len(t, written, 8)
+				for i, data := range written {
+					assert.Len(t, data, 1)
+					assert.NotEqual(t, fmt.Sprint(i), data)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, chaosWritten(t, 8, tt.chaos))
+		})
+	}
+}
+
+func TestChaosHandler_Handle_Seed(t *testing.T) {
+	chaos := Chaos{DropRate: 0.2, DuplicateRate: 0.2, ReorderWindow: 4, CorruptRate: 0.2, Seed: 7}
+	written := chaosWritten(t, 50, chaos)
+
+	assert.Equal(t, written, chaosWritten(t, 50, chaos))
+	chaos.Seed = 8
+	assert.NotEqual(t, written, chaosWritten(t, 50, chaos))
+}
+
+func TestChaosHandler_Handle_Jitter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := clock.NewFake(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC))
+	start := c.Now()
+	var times []time.Time
+	conn := NewMockConnection(t)
+	conn.On("Write", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		times = append(times, c.Now())
+	})
+	h := NewChaosHandler(countingHandler(t, 5), Chaos{Jitter: time.Second, Seed: 1}).WithClock(c)
+
+	done := make(chan error)
+	go func() { done <- h.Handle(ctx, Message{}, conn, nil) }()
+	for {
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+			require.Len(t, times, 5)
+			previous := start
+			for _, t1 := range times {
+				assert.LessOrEqual(t, t1.Sub(previous), time.Second)
+				previous = t1
+			}
+			assert.True(t, times[4].After(start))
+			return
+		default:
+		}
+		_, err := c.Step(ctx)
+		require.NoError(t, err)
+	}
+}
+
+func TestChaosHandler_Handle_JitterConcurrent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c := clock.NewFake(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC))
+	conn := NewMockConnection(t)
+	conn.On("Write", mock.Anything, mock.Anything).Return(nil)
+	h := NewMockMessageHandler(t)
+	h.On("Handle", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		ctx, conn := args.Get(0).(context.Context), args.Get(2).(Connection)
+		errs := make(chan error, 2)
+		for i := range 2 {
+			go func() { errs <- conn.Write(ctx, text(fmt.Sprint(i))) }()
+		}
+		// A message being delayed does not hold up the other
+		require.NoError(t, c.WaitForSleepers(ctx, 2))
+		c.Advance(time.Second)
+		require.NoError(t, <-errs)
+		require.NoError(t, <-errs)
+	})
+
+	err := NewChaosHandler(h, Chaos{Jitter: time.Second, Seed: 1}).WithClock(c).Handle(ctx, Message{}, conn, nil)
+
+	assert.NoError(t, err)
+	conn.AssertNumberOfCalls(t, "Write", 2)
+}
@@ -0,0 +1,47 @@
+package ws
+
+import (
+	"context"
+	"errors"
+)
+
+// Closure tells how a connection is closed
+type Closure struct {
+	// Code and Reason are sent in the close frame. Code 0 stands for 1001, going away, as an exchange closes for maintenance.
+	Code   int
+	Reason string
+	// Reset drops the connection without a close frame, as a network failure does
+	Reset bool
+}
+
+// Closer is a connection that the handlers can close.
+// The messages written to it before are written before it is closed.
+type Closer interface {
+	Close(Closure) error
+}
+
+// CloseConnection closes conn as closure tells, or fails if conn cannot be closed
+func CloseConnection(conn Connection, closure Closure) error {
+	closer, ok := conn.(Closer)
+	if !ok {
+		return errors.New("the connection cannot be closed")
+	}
+	return closer.Close(closure)
+}
+
+// Ensure CloseHandler implements MessageHandler
+var _ MessageHandler = (*CloseHandler)(nil)
+
+// CloseHandler closes the client connection of the messages it handles,
+// e.g. as the handler of a message that an exchange disconnects for
+type CloseHandler struct {
+	closure Closure
+}
+
+func NewCloseHandler(closure Closure) CloseHandler {
+	return CloseHandler{closure: closure}
+}
+
+func (h CloseHandler) Handle(ctx context.Context, message Message, connClient Connection, connServer Connection) error {
+	return CloseConnection(connClient, h.closure)
+}
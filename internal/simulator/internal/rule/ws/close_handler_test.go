@@ -0,0 +1,42 @@
+package ws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// closableConnection is a connection that sends how it is closed
+type closableConnection struct {
+	*MockConnection
+	closures chan Closure
+}
+
+func newClosableConnection(t *testing.T) closableConnection {
+	return closableConnection{MockConnection: NewMockConnection(t), closures: make(chan Closure, 10)}
+}
+
+func (c closableConnection) Close(closure Closure) error {
+	c.closures <- closure
+	return nil
+}
+
+func TestCloseHandler_Handle(t *testing.T) {
+	closure := Closure{Code: 4000, Reason: "bye"}
+	h := NewCloseHandler(closure)
+
+	conn := newClosableConnection(t)
+	err := h.Handle(context.Background(), text("close"), conn, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, closure, <-conn.closures)
+}
+
+func TestCloseHandler_Handle_NotCloser(t *testing.T) {
+	h := NewCloseHandler(Closure{})
+
+	err := h.Handle(context.Background(), text("close"), NewMockConnection(t), nil)
+
+	assert.EqualError(t, err, "the connection cannot be closed")
+}
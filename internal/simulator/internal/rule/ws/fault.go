@@ -0,0 +1,230 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/log"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+)
+
+// ConnectionFault tells when a connection is closed, to test how clients reconnect.
+// The first of its conditions that is met closes the connection; the zero value never closes it.
+type ConnectionFault struct {
+	// AfterMessages closes the connection once that many messages have been written to it
+	AfterMessages int
+	// After closes the connection once it has lasted that long, e.g. 24 hours as exchanges do
+	After time.Duration
+	// RandomMin and RandomMax close the connection once it has lasted a random duration between them.
+	// The durations are drawn from Seed, so that they are the same from run to run.
+	RandomMin time.Duration
+	RandomMax time.Duration
+	Seed      uint64
+	Closure   Closure
+}
+
+var errClosedByFault = errors.New("the connection was closed by a fault")
+
+// FaultInjector closes the connections given to it as a ConnectionFault tells.
+// The messages and the time of a connection are counted from when it is first given to the injector.
+type FaultInjector struct {
+	fault ConnectionFault
+	clock clock.Clock
+	state *faultInjectorState
+}
+
+type faultInjectorState struct {
+	lock        sync.Mutex
+	random      *rand.Rand
+	connections map[Connection]*faultyConnection
+}
+
+func NewFaultInjector(fault ConnectionFault) FaultInjector {
+	return FaultInjector{
+		fault: fault,
+		state: &faultInjectorState{
+			random:      rand.New(rand.NewPCG(fault.Seed, 0)),
+			connections: map[Connection]*faultyConnection{},
+		},
+	}
+}
+
+// WithClock returns a copy of i that times the connections on c rather than on the clock of the simulator
+func (i FaultInjector) WithClock(c clock.Clock) FaultInjector {
+	i.clock = c
+	return i
+}
+
+// Reset reseeds the random lifetimes, so that they are drawn again as from the start.
+// The connections given to i already keep their faults.
+func (i FaultInjector) Reset() {
+	i.state.lock.Lock()
+	defer i.state.lock.Unlock()
+
+	i.state.random = rand.New(rand.NewPCG(i.fault.Seed, 0))
+}
+
+// Inject returns conn as seen through the fault: the messages written to it are counted, and it is closed once the fault says so.
+// Injecting the same connection again returns the same connection, until ctx is done.
+func (i FaultInjector) Inject(ctx context.Context, conn Connection) Connection {
+	i.state.lock.Lock()
+	defer i.state.lock.Unlock()
+
+	if f, ok := i.state.connections[conn]; ok {
+		return f
+	}
+	f := &faultyConnection{Connection: conn, afterMessages: i.fault.AfterMessages, closure: i.fault.Closure}
+	i.state.connections[conn] = f
+	context.AfterFunc(ctx, func() {
+		i.state.lock.Lock()
+		defer i.state.lock.Unlock()
+
+		delete(i.state.connections, conn)
+	})
+
+	lifetime := i.lifetime()
+	if lifetime > 0 {
+		// The timer is attached to the clock before the goroutine starts, so that a stepped clock waits for it
+		c, detach := clock.Attach(clock.Resolve(ctx, i.clock))
+		end := c.Now().Add(lifetime)
+		go func() {
+			defer detach()
+			if c.SleepUntil(ctx, end) == nil {
+				f.closeWithFault()
+			}
+		}()
+	}
+	return f
+}
+
+// lifetime returns how long a new connection lasts, or 0 if it lasts until it is closed otherwise. i.state.lock must be held.
+func (i FaultInjector) lifetime() time.Duration {
+	var random time.Duration
+	if i.fault.RandomMax > 0 {
+		random = i.fault.RandomMin
+		if i.fault.RandomMax > i.fault.RandomMin {
+			random += time.Duration(i.state.random.Int64N(int64(i.fault.RandomMax-i.fault.RandomMin) + 1))
+		}
+	}
+
+	switch {
+	case i.fault.After <= 0:
+		return random
+	case random <= 0:
+		return i.fault.After
+	default:
+		return min(i.fault.After, random)
+	}
+}
+
+// Ensure faultyConnection implements Closer
+var _ Closer = (*faultyConnection)(nil)
+
+// faultyConnection is a connection that a fault closes
+type faultyConnection struct {
+	Connection
+	afterMessages int
+	closure       Closure
+
+	lock    sync.Mutex
+	written int
+	closed  bool
+}
+
+// Write writes message, and closes the connection if it was the last message the fault lets through
+func (c *faultyConnection) Write(ctx context.Context, message Message) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return errClosedByFault
+	}
+	err := c.Connection.Write(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	c.written++
+	if c.afterMessages > 0 && c.written >= c.afterMessages {
+		c.close(c.closure)
+	}
+	return nil
+}
+
+func (c *faultyConnection) Close(closure Closure) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return errClosedByFault
+	}
+	return c.close(closure)
+}
+
+func (c *faultyConnection) closeWithFault() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.closed {
+		c.close(c.closure)
+	}
+}
+
+// close closes the connection. c.lock must be held.
+func (c *faultyConnection) close(closure Closure) error {
+	c.closed = true
+	err := CloseConnection(c.Connection, closure)
+	if err != nil {
+		logger.Error("Error closing a connection for a fault", log.Any("error", err))
+	}
+	return err
+}
+
+// Ensure FaultHandler implements MessageHandler
+var _ MessageHandler = (*FaultHandler)(nil)
+
+// Ensure FaultHandler implements clockAttacher
+var _ clockAttacher = (*FaultHandler)(nil)
+
+// FaultHandler runs a handler on connections that a fault closes.
+// The messages the handler writes to a connection are counted, and the time is counted from the first message it handles on it.
+type FaultHandler struct {
+	handler  MessageHandler
+	injector FaultInjector
+}
+
+func NewFaultHandler(handler MessageHandler, fault ConnectionFault) FaultHandler {
+	return FaultHandler{
+		handler:  handler,
+		injector: NewFaultInjector(fault),
+	}
+}
+
+// WithClock returns a copy of h that times the connections on c rather than on the clock of the simulator
+func (h FaultHandler) WithClock(c clock.Clock) FaultHandler {
+	h.injector = h.injector.WithClock(c)
+	return h
+}
+
+func (h FaultHandler) Handle(ctx context.Context, message Message, connClient Connection, connServer Connection) error {
+	return h.handler.Handle(ctx, message, h.injector.Inject(ctx, connClient), connServer)
+}
+
+func (h FaultHandler) Reset() {
+	h.injector.Reset()
+	reset(h.handler)
+}
+
+func (h FaultHandler) attachClock(ctx context.Context) (MessageHandler, func()) {
+	a, ok := h.handler.(clockAttacher)
+	if !ok {
+		return h, func() {}
+	}
+
+	var detach func()
+	h.handler, detach = a.attachClock(ctx)
+	return h, detach
+}
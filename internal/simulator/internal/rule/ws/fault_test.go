@@ -0,0 +1,142 @@
+package ws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultInjector_Inject_AfterMessages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	closure := Closure{Code: 1001, Reason: "maintenance"}
+	i := NewFaultInjector(ConnectionFault{AfterMessages: 2, Closure: closure})
+	conn := newClosableConnection(t)
+	conn.On("Write", ctx, mock.Anything).Return(nil).Twice()
+
+	faulty := i.Inject(ctx, conn)
+	assert.Same(t, faulty, i.Inject(ctx, conn))
+
+	assert.NoError(t, faulty.Write(ctx, text("1")))
+	assert.Empty(t, conn.closures)
+	assert.NoError(t, faulty.Write(ctx, text("2")))
+	assert.Equal(t, closure, <-conn.closures)
+	assert.ErrorIs(t, faulty.Write(ctx, text("3")), errClosedByFault)
+	assert.Empty(t, conn.closures)
+}
+
+func TestFaultInjector_Inject_After(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := clock.NewFake(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC))
+	closure := Closure{Reset: true}
+	i := NewFaultInjector(ConnectionFault{After: 24 * time.Hour, Closure: closure}).WithClock(c)
+	conn := newClosableConnection(t)
+
+	i.Inject(ctx, conn)
+	stepped, err := c.Step(ctx)
+	require.NoError(t, err)
+	require.True(t, stepped)
+
+	assert.Equal(t, closure, <-conn.closures)
+	assert.Equal(t, time.Date(2000, 1, 24, 12, 34, 56, 0, time.UTC), c.Now())
+}
+
+func TestFaultInjector_Inject_Done(t *testing.T) {
+	c := clock.NewFake(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC))
+	i := NewFaultInjector(ConnectionFault{After: time.Hour}).WithClock(c)
+	conn := newClosableConnection(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	faulty := i.Inject(ctx, conn)
+	require.NoError(t, c.WaitForSleepers(context.Background(), 1))
+	cancel()
+
+	// The connection is forgotten and no longer timed once its context is done
+	require.NoError(t, c.WaitForSleepers(context.Background(), 0))
+	assert.Eventually(t, func() bool {
+		i.state.lock.Lock()
+		defer i.state.lock.Unlock()
+
+		return len(i.state.connections) == 0
+	}, time.Second, time.Millisecond)
+	assert.NotSame(t, faulty, i.Inject(context.Background(), conn))
+}
+
+func TestFaultInjector_lifetime(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault ConnectionFault
+		check func(t *testing.T, lifetime time.Duration)
+	}{
+		{
+			name:  "None",
+			fault: ConnectionFault{AfterMessages: 10},
+			check: func(t *testing.T, lifetime time.Duration) { assert.Zero(t, lifetime) },
+		},
+		{
+			name:  "After",
+			fault: ConnectionFault{After: time.Hour},
+			check: func(t *testing.T, lifetime time.Duration) { assert.Equal(t, time.Hour, lifetime) },
+		},
+		{
+			name:  "Random",
+			fault: ConnectionFault{RandomMin: time.Minute, RandomMax: time.Hour, Seed: 42},
+			check: func(t *testing.T, lifetime time.Duration) {
+				assert.GreaterOrEqual(t, lifetime, time.Minute)
+				assert.LessOrEqual(t, lifetime, time.Hour)
+			},
+		},
+		{
+			name:  "Earlier of after and random",
+			fault: ConnectionFault{After: time.Second, RandomMin: time.Minute, RandomMax: time.Hour},
+			check: func(t *testing.T, lifetime time.Duration) { assert.Equal(t, time.Second, lifetime) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := NewFaultInjector(tt.fault)
+			for range 10 {
+				tt.check(t, i.lifetime())
+			}
+		})
+	}
+}
+
+func TestFaultInjector_lifetime_Seed(t *testing.T) {
+	lifetimes := func(seed uint64) []time.Duration {
+		i := NewFaultInjector(ConnectionFault{RandomMin: time.Minute, RandomMax: time.Hour, Seed: seed})
+		var lifetimes []time.Duration
+		for range 5 {
+			lifetimes = append(lifetimes, i.lifetime())
+		}
+		return lifetimes
+	}
+
+	assert.Equal(t, lifetimes(1), lifetimes(1))
+	assert.NotEqual(t, lifetimes(1), lifetimes(2))
+}
+
+func TestFaultHandler_Handle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	closure := Closure{Code: 4000}
+	h := NewFaultHandler(NewMessageFromString(MessageText, "pong", 0), ConnectionFault{AfterMessages: 2, Closure: closure})
+	conn := newClosableConnection(t)
+	conn.On("Write", ctx, text("pong")).Return(nil).Twice()
+
+	assert.NoError(t, h.Handle(ctx, text("ping"), conn, nil))
+	assert.Empty(t, conn.closures)
+	assert.NoError(t, h.Handle(ctx, text("ping"), conn, nil))
+	assert.Equal(t, closure, <-conn.closures)
+	assert.ErrorIs(t, h.Handle(ctx, text("ping"), conn, nil), errClosedByFault)
+}
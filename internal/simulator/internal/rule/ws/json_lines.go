@@ -0,0 +1,93 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
+)
+
+// jsonLinesWriter appends values to a JSON Lines file, which is created on the first write.
+// The file is compressed if its path ends with .gz or .zst, and flushed after every value,
+// so that another process reads every value written so far.
+type jsonLinesWriter struct {
+	path string
+	lock sync.Mutex
+	file fileio.Writer
+}
+
+func (w *jsonLinesWriter) write(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		err := os.MkdirAll(filepath.Dir(w.path), 0755)
+		if err != nil {
+			return err
+		}
+
+		w.file, err = fileio.OpenAppend(w.path)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = w.file.Write(line)
+	if err != nil {
+		return err
+	}
+	return w.file.Flush()
+}
+
+func (w *jsonLinesWriter) close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// jsonLinesReader decodes the lines of a JSON Lines stream one at a time, skipping blank lines.
+// A stream that ends unexpectedly is truncated or corrupted.
+type jsonLinesReader struct {
+	reader *bufio.Reader
+}
+
+func newJsonLinesReader(r io.Reader) jsonLinesReader {
+	return jsonLinesReader{reader: bufio.NewReader(r)}
+}
+
+// read decodes the next line into v and returns io.EOF after the last line
+func (r jsonLinesReader) read(v any) error {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			return json.Unmarshal(line, v)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read JSON Lines: %w", err)
+		}
+	}
+}
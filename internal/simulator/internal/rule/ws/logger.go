@@ -0,0 +1,11 @@
+package ws
+
+import (
+	"alphanonce.com/exchangesimulator/internal/log"
+)
+
+var logger *log.Logger
+
+func init() {
+	logger = log.NewDefault().With(log.String("package", "ws"))
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"os"
+	"io/fs"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
 
 	"gopkg.in/yaml.v3"
 )
@@ -23,21 +25,9 @@ type Message struct {
 }
 
 func (m *Message) MarshalYAML() (any, error) {
-	var typeStr string
-	switch m.Type {
-	case MessageText:
-		typeStr = "text"
-	case MessageBinary:
-		typeStr = "binary"
-	default:
-		return nil, errors.New("invalid message type")
-	}
-
-	var dataValue string
-	if m.Type == MessageText {
-		dataValue = string(m.Data)
-	} else {
-		dataValue = hex.EncodeToString(m.Data)
+	typeStr, dataValue, err := formatMessage(*m)
+	if err != nil {
+		return nil, err
 	}
 
 	return &yaml.Node{
@@ -88,31 +78,51 @@ func (m *Message) UnmarshalYAML(value *yaml.Node) error {
 		}
 	}
 
+	message, err := parseMessage(typeStr, dataStr)
+	if err != nil {
+		return err
+	}
+
+	*m = message
+	return nil
+}
+
+// formatMessage returns the type name and the data of a message as stored in recordings,
+// where binary data is hex-encoded
+func formatMessage(m Message) (string, string, error) {
+	switch m.Type {
+	case MessageText:
+		return "text", string(m.Data), nil
+	case MessageBinary:
+		return "binary", hex.EncodeToString(m.Data), nil
+	default:
+		return "", "", errors.New("invalid message type")
+	}
+}
+
+func parseMessage(typeStr string, dataStr string) (Message, error) {
 	switch typeStr {
 	case "text":
-		m.Type = MessageText
-		m.Data = []byte(dataStr)
+		return Message{Type: MessageText, Data: []byte(dataStr)}, nil
 	case "binary":
-		m.Type = MessageBinary
 		d, err := hex.DecodeString(dataStr)
 		if err != nil {
-			return fmt.Errorf("failed to decode binary data: %v", err)
+			return Message{}, fmt.Errorf("failed to decode binary data: %v", err)
 		}
-		m.Data = d
+		return Message{Type: MessageBinary, Data: d}, nil
 	default:
-		return fmt.Errorf("invalid message type: %s", typeStr)
+		return Message{}, fmt.Errorf("invalid message type: %s", typeStr)
 	}
-
-	return nil
 }
 
+// WriteToFile writes a message as YAML, compressed if path ends with .gz or .zst
 func WriteToFile(path string, message Message) error {
 	data, err := yaml.Marshal(&message)
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(path, data, 0644)
+	err = fileio.WriteFile(path, data)
 	if err != nil {
 		return err
 	}
@@ -120,14 +130,24 @@ func WriteToFile(path string, message Message) error {
 	return nil
 }
 
+// ReadFromFile reads a message written by WriteToFile
 func ReadFromFile(path string) (Message, error) {
-	data, err := os.ReadFile(path)
+	return ReadFromFS(fileio.OS, path)
+}
+
+// ReadFromFS reads a message written by WriteToFile from a file of fsys
+func ReadFromFS(fsys fs.FS, name string) (Message, error) {
+	data, err := fileio.ReadFile(fsys, name)
 	if err != nil {
 		return Message{}, err
 	}
 
+	return decodeMessage(data)
+}
+
+func decodeMessage(data []byte) (Message, error) {
 	var m Message
-	err = yaml.Unmarshal(data, &m)
+	err := yaml.Unmarshal(data, &m)
 	if err != nil {
 		return Message{}, err
 	}
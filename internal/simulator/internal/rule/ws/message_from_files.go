@@ -2,74 +2,63 @@ package ws
 
 import (
 	"context"
-	"os"
-	"path/filepath"
-	"strings"
+	"io/fs"
 	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
 )
 
 // Ensure MessageFromFiles implements MessageHandler
 var _ MessageHandler = (*MessageFromFiles)(nil)
 
+// Ensure MessageFromFiles implements clockAttacher
+var _ clockAttacher = (*MessageFromFiles)(nil)
+
+// MessageFromFiles replays YAML messages named by the RFC 3339 time they were recorded at.
+// dirPath is either a directory or a tar archive, and each message may be gzip or zstd compressed.
 type MessageFromFiles struct {
+	fsys    fs.FS
 	dirPath string
+	options ReplayOptions
+	clock   clock.Clock
 }
 
 func NewMessageFromFiles(dirPath string) MessageFromFiles {
 	return MessageFromFiles{
+		fsys:    fileio.OS,
 		dirPath: dirPath,
 	}
 }
 
-func (r MessageFromFiles) Handle(ctx context.Context, _ Message, connClient Connection, _ Connection) error {
-	entries, err := os.ReadDir(r.dirPath)
-	if err != nil {
-		return err
-	}
+// WithClock returns a copy of r that waits on c instead of the clock of the simulator
+func (r MessageFromFiles) WithClock(c clock.Clock) MessageFromFiles {
+	r.clock = c
+	return r
+}
 
-	var files []string
-	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".yaml") {
-			continue
-		}
-		files = append(files, e.Name())
-	}
+// WithFS returns a copy of r that reads dirPath from fsys, e.g. an embed.FS, instead of the operating system
+func (r MessageFromFiles) WithFS(fsys fs.FS) MessageFromFiles {
+	r.fsys = fsys
+	return r
+}
 
-	if len(files) == 0 {
-		return nil
-	}
+// WithOptions returns a copy of r that replays the records as options tell
+func (r MessageFromFiles) WithOptions(options ReplayOptions) MessageFromFiles {
+	r.options = options
+	return r
+}
 
-	t0, err := parseTime(files[0])
-	if err != nil {
-		return err
+func (r MessageFromFiles) Handle(ctx context.Context, _ Message, connClient Connection, _ Connection) error {
+	open := func() (recordReader, error) {
+		return openMessageFiles(r.fsys, r.dirPath)
 	}
 
-	startTime := time.Now()
-
-	for _, f := range files {
-		if err := context.Cause(ctx); err != nil {
-			return err
-		}
-
-		ti, err := parseTime(f)
-		if err != nil {
-			return err
-		}
-
-		message, err := ReadFromFile(filepath.Join(r.dirPath, f))
-		if err != nil {
-			return err
-		}
-
-		interval := ti.Sub(t0)
-		time.Sleep(time.Until(startTime.Add(interval)))
-
-		connClient.Write(ctx, message)
-	}
-	return nil
+	return replay(ctx, connClient, open, time.Time{}, time.Time{}, r.options, r.clock)
 }
 
-func parseTime(filename string) (time.Time, error) {
-	f, _ := strings.CutSuffix(filename, ".yaml")
-	return time.Parse(time.RFC3339Nano, f)
+func (r MessageFromFiles) attachClock(ctx context.Context) (MessageHandler, func()) {
+	c, detach := clock.Attach(clock.Resolve(ctx, r.clock))
+	r.clock = c
+	return r, detach
 }
@@ -1,13 +1,19 @@
 package ws
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewMessageFromFiles(t *testing.T) {
@@ -82,3 +88,137 @@ func TestMessageFromFiles_Handle_ContextCancellation(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, context.Canceled, err)
 }
+
+func TestMessageFromFiles_Handle_Compressed(t *testing.T) {
+	tempDir := t.TempDir()
+
+	messages := []Message{
+		{Type: MessageText, Data: []byte("data1")},
+		{Type: MessageText, Data: []byte("data2")},
+		{Type: MessageBinary, Data: []byte{0x01, 0x02}},
+	}
+	filenames := []string{
+		"2000-01-23T12:34:56.000000+09:00.yaml",
+		"2000-01-23T12:34:56.010000+09:00.yaml.gz",
+		"2000-01-23T12:34:56.020000+09:00.yaml.zst",
+	}
+	for i, f := range filenames {
+		err := WriteToFile(filepath.Join(tempDir, f), messages[i])
+		assert.NoError(t, err)
+	}
+
+	ctx := context.Background()
+	mockConn := NewMockConnection(t)
+	for _, m := range messages {
+		mockConn.On("Write", ctx, m).Return(nil).Once()
+	}
+
+	err := NewMessageFromFiles(tempDir).Handle(ctx, Message{}, mockConn, nil)
+
+	assert.NoError(t, err)
+}
+
+func TestMessageFromFiles_Handle_Archive(t *testing.T) {
+	tempDir := t.TempDir()
+
+	messages := []Message{
+		{Type: MessageText, Data: []byte("data1")},
+		{Type: MessageText, Data: []byte("data2")},
+	}
+	filenames := []string{
+		"depth_update/2000-01-23T12:34:56.000000+09:00.yaml",
+		"depth_update/2000-01-23T12:34:56.010000+09:00.yaml.gz",
+	}
+
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for i, f := range filenames {
+		path := filepath.Join(tempDir, filepath.Base(f))
+		require.NoError(t, WriteToFile(path, messages[i]))
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: f, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err = tw.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	archivePath := filepath.Join(tempDir, "depth_update.tar.gz")
+	archive, err := os.Create(archivePath)
+	require.NoError(t, err)
+	gzipWriter := gzip.NewWriter(archive)
+	_, err = gzipWriter.Write(buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+	require.NoError(t, archive.Close())
+
+	ctx := context.Background()
+	mockConn := NewMockConnection(t)
+	for _, m := range messages {
+		mockConn.On("Write", ctx, m).Return(nil).Once()
+	}
+
+	err = NewMessageFromFiles(archivePath).Handle(ctx, Message{}, mockConn, nil)
+
+	assert.NoError(t, err)
+}
+
+func TestMessageFromFiles_Handle_TimeOrder(t *testing.T) {
+	// Fractional digits and time zones make names sort apart from times
+	fsys := fstest.MapFS{
+		"data/2000-01-23T03:34:56Z.yaml":         {Data: []byte("type: text\ndata: data1")},
+		"data/2000-01-23T03:34:56.01Z.yaml":      {Data: []byte("type: text\ndata: data2")},
+		"data/2000-01-23T12:34:56.02+09:00.yaml": {Data: []byte("type: text\ndata: data3")},
+	}
+
+	ctx := context.Background()
+	var written []string
+	mockConn := NewMockConnection(t)
+	mockConn.On("Write", ctx, mock.AnythingOfType("Message")).Return(nil).Run(func(args mock.Arguments) {
+		written = append(written, string(args.Get(1).(Message).Data))
+	})
+
+	err := NewMessageFromFiles("data").WithFS(fsys).WithOptions(ReplayOptions{Speed: math.Inf(1)}).Handle(ctx, Message{}, mockConn, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"data1", "data2", "data3"}, written)
+}
+
+func TestMessageFromFiles_Handle_ArchiveOutOfOrder(t *testing.T) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, f := range []string{"2000-01-23T12:34:56.010000+09:00.yaml", "2000-01-23T12:34:56.000000+09:00.yaml"} {
+		content := []byte("type: text\ndata: data")
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: f, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	fsys := fstest.MapFS{"depth_update.tar": {Data: buf.Bytes()}}
+
+	ctx := context.Background()
+	mockConn := NewMockConnection(t)
+	mockConn.On("Write", ctx, Message{Type: MessageText, Data: []byte("data")}).Return(nil).Once()
+
+	err := NewMessageFromFiles("depth_update.tar").WithFS(fsys).Handle(ctx, Message{}, mockConn, nil)
+
+	assert.ErrorContains(t, err, "time order")
+}
+
+func TestMessageFromFiles_WithFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"ws/depth_update/2000-01-23T12:34:56.000000+09:00.yaml": {Data: []byte("type: text\ndata: data1")},
+		"ws/depth_update/2000-01-23T12:34:56.010000+09:00.yaml": {Data: []byte("type: text\ndata: data2")},
+		"ws/depth_update/README.md":                             {Data: []byte("not a message")},
+	}
+
+	ctx := context.Background()
+	mockConn := NewMockConnection(t)
+	mockConn.On("Write", ctx, Message{Type: MessageText, Data: []byte("data1")}).Return(nil).Once()
+	mockConn.On("Write", ctx, Message{Type: MessageText, Data: []byte("data2")}).Return(nil).Once()
+
+	err := NewMessageFromFiles("ws/depth_update").WithFS(fsys).Handle(ctx, Message{}, mockConn, nil)
+
+	assert.NoError(t, err)
+}
@@ -0,0 +1,64 @@
+package ws
+
+import (
+	"context"
+	"io/fs"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
+)
+
+// Ensure MessageFromRecordFile implements MessageHandler
+var _ MessageHandler = (*MessageFromRecordFile)(nil)
+
+// Ensure MessageFromRecordFile implements clockAttacher
+var _ clockAttacher = (*MessageFromRecordFile)(nil)
+
+// MessageFromRecordFile replays a JSON Lines file written by RecordWriter, gzip or zstd compressed or not,
+// reading one record at a time
+type MessageFromRecordFile struct {
+	fsys     fs.FS
+	filePath string
+	options  ReplayOptions
+	clock    clock.Clock
+}
+
+func NewMessageFromRecordFile(filePath string) MessageFromRecordFile {
+	return MessageFromRecordFile{
+		fsys:     fileio.OS,
+		filePath: filePath,
+	}
+}
+
+// WithClock returns a copy of r that waits on c instead of the clock of the simulator
+func (r MessageFromRecordFile) WithClock(c clock.Clock) MessageFromRecordFile {
+	r.clock = c
+	return r
+}
+
+// WithFS returns a copy of r that reads filePath from fsys, e.g. an embed.FS, instead of the operating system
+func (r MessageFromRecordFile) WithFS(fsys fs.FS) MessageFromRecordFile {
+	r.fsys = fsys
+	return r
+}
+
+// WithOptions returns a copy of r that replays the records as options tell
+func (r MessageFromRecordFile) WithOptions(options ReplayOptions) MessageFromRecordFile {
+	r.options = options
+	return r
+}
+
+func (r MessageFromRecordFile) Handle(ctx context.Context, _ Message, connClient Connection, _ Connection) error {
+	open := func() (recordReader, error) {
+		return openRecordFile(r.fsys, r.filePath)
+	}
+
+	return replay(ctx, connClient, open, time.Time{}, time.Time{}, r.options, r.clock)
+}
+
+func (r MessageFromRecordFile) attachClock(ctx context.Context) (MessageHandler, func()) {
+	c, detach := clock.Attach(clock.Resolve(ctx, r.clock))
+	r.clock = c
+	return r, detach
+}
@@ -0,0 +1,96 @@
+package ws
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMessageFromRecordFile(t *testing.T) {
+	filePath := "/test/path.jsonl"
+
+	h := NewMessageFromRecordFile(filePath)
+
+	assert.Equal(t, filePath, h.filePath)
+}
+
+func TestMessageFromRecordFile_Handle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	content := `{"time":"2000-01-23T12:34:56.00+09:00","type":"text","data":"data1"}` + "\n" +
+		"\n" +
+		`{"time":"2000-01-23T12:34:56.01+09:00","type":"binary","data":"0102"}` + "\n" +
+		`{"time":"2000-01-23T12:34:56.02+09:00","type":"text","data":"data3"}`
+	err := os.WriteFile(path, []byte(content), 0644)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	mockConn := NewMockConnection(t)
+	mockConn.On("Write", ctx, Message{Type: MessageText, Data: []byte("data1")}).Return(nil).Once()
+	mockConn.On("Write", ctx, Message{Type: MessageBinary, Data: []byte{0x01, 0x02}}).Return(nil).Once()
+	mockConn.On("Write", ctx, Message{Type: MessageText, Data: []byte("data3")}).Return(nil).Once()
+
+	start := time.Now()
+	err = NewMessageFromRecordFile(path).Handle(ctx, Message{}, mockConn, nil)
+	duration := time.Since(start)
+
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, duration, 20*time.Millisecond)
+	assert.Less(t, duration, 40*time.Millisecond)
+}
+
+func TestMessageFromRecordFile_Handle_Error(t *testing.T) {
+	mockConn := NewMockConnection(t)
+
+	err := NewMessageFromRecordFile("/non/existent/path.jsonl").Handle(context.Background(), Message{}, mockConn, nil)
+
+	assert.Error(t, err)
+}
+
+func TestMessageFromRecordFile_Handle_Compressed(t *testing.T) {
+	for _, filename := range []string{"records.jsonl.gz", "records.jsonl.zst"} {
+		t.Run(filename, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), filename)
+			messages := []Message{
+				{Type: MessageText, Data: []byte("data1")},
+				{Type: MessageText, Data: []byte("data2")},
+			}
+
+			// The recorder is still open, as it is while the simulator runs
+			w := NewRecordWriter(path)
+			defer w.Close()
+			for i, m := range messages {
+				err := w.Write(Record{Time: time.Date(2000, 1, 23, 12, 34, 56, i*int(time.Millisecond), time.UTC), Message: m})
+				assert.NoError(t, err)
+			}
+
+			ctx := context.Background()
+			mockConn := NewMockConnection(t)
+			for _, m := range messages {
+				mockConn.On("Write", ctx, m).Return(nil).Once()
+			}
+
+			err := NewMessageFromRecordFile(path).Handle(ctx, Message{}, mockConn, nil)
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMessageFromRecordFile_WithFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"records.jsonl": {Data: []byte(`{"time":"2000-01-23T12:34:56.00+09:00","type":"text","data":"data1"}` + "\n")},
+	}
+
+	ctx := context.Background()
+	mockConn := NewMockConnection(t)
+	mockConn.On("Write", ctx, Message{Type: MessageText, Data: []byte("data1")}).Return(nil).Once()
+
+	err := NewMessageFromRecordFile("records.jsonl").WithFS(fsys).Handle(ctx, Message{}, mockConn, nil)
+
+	assert.NoError(t, err)
+}
@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"sync"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
+)
+
+// Ensure MessageFromStreams implements MessageHandler
+var _ MessageHandler = (*MessageFromStreams)(nil)
+
+// Ensure MessageFromStreams implements clockAttacher
+var _ clockAttacher = (*MessageFromStreams)(nil)
+
+// MessageFromStreams replays several recorded streams, e.g. the depth updates and the trades of a few symbols,
+// merged in time order onto a timeline shared with other replays.
+// A replay starts from the current time of the timeline rather than from the first record,
+// so a client subscribing late sees the market as it is by then.
+// Each path is a JSON Lines record file if it ends with .jsonl, optionally compressed,
+// and a directory or a tar archive of YAML messages otherwise.
+//
+// The streams are read once and kept decoded in memory, so that a replay starting late seeks
+// to the current time of the timeline rather than decoding the streams from the start.
+type MessageFromStreams struct {
+	fsys     fs.FS
+	timeline *Timeline
+	paths    []string
+	clock    clock.Clock
+	index    *streamIndex
+}
+
+// streamIndex keeps the records of the streams in time order, once they are read
+type streamIndex struct {
+	lock    sync.Mutex
+	loaded  bool
+	records []Record
+}
+
+func NewMessageFromStreams(timeline *Timeline, paths ...string) MessageFromStreams {
+	return MessageFromStreams{
+		fsys:     fileio.OS,
+		timeline: timeline,
+		paths:    paths,
+		index:    &streamIndex{},
+	}
+}
+
+// WithClock returns a copy of r that waits on c instead of the clock of the simulator
+func (r MessageFromStreams) WithClock(c clock.Clock) MessageFromStreams {
+	r.clock = c
+	return r
+}
+
+// WithFS returns a copy of r that reads the paths from fsys, e.g. an embed.FS, instead of the operating system
+func (r MessageFromStreams) WithFS(fsys fs.FS) MessageFromStreams {
+	r.fsys = fsys
+	r.index = &streamIndex{}
+	return r
+}
+
+func (r MessageFromStreams) Handle(ctx context.Context, _ Message, connClient Connection, _ Connection) error {
+	records, err := r.records()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	c := clock.Resolve(ctx, r.clock)
+	start, origin, err := r.timeline.begin(c, func() (time.Time, error) { return records[0].Time, nil })
+	if err != nil {
+		return err
+	}
+
+	from := r.timeline.at(c.Now())
+	i, _ := slices.BinarySearchFunc(records, from, func(record Record, t time.Time) int { return record.Time.Compare(t) })
+	open := func() (recordReader, error) {
+		return &sliceRecordReader{records: records[i:]}, nil
+	}
+	options := ReplayOptions{Speed: r.timeline.speed, From: from}
+	return replay(ctx, connClient, open, start, origin, options, c)
+}
+
+func (r MessageFromStreams) Reset() {
+	r.timeline.Reset()
+}
+
+func (r MessageFromStreams) attachClock(ctx context.Context) (MessageHandler, func()) {
+	c, detach := clock.Attach(clock.Resolve(ctx, r.clock))
+	r.clock = c
+	return r, detach
+}
+
+func (r MessageFromStreams) open() (recordReader, error) {
+	readers := make([]recordReader, 0, len(r.paths))
+	for _, p := range r.paths {
+		reader, err := openRecords(r.fsys, p)
+		if err != nil {
+			for _, opened := range readers {
+				opened.Close()
+			}
+			return nil, err
+		}
+		readers = append(readers, reader)
+	}
+	return newMergedRecordReader(readers), nil
+}
+
+// records returns the records of the streams merged in time order, reading them unless they were read already
+func (r MessageFromStreams) records() ([]Record, error) {
+	r.index.lock.Lock()
+	defer r.index.lock.Unlock()
+
+	if r.index.loaded {
+		return r.index.records, nil
+	}
+
+	reader, err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var records []Record
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	r.index.records = records
+	r.index.loaded = true
+	return records, nil
+}
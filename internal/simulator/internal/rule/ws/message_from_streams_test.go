@@ -0,0 +1,155 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewMessageFromStreams(t *testing.T) {
+	timeline := NewTimeline(time.Time{}, 0)
+
+	r := NewMessageFromStreams(timeline, "depth", "trades.jsonl")
+
+	assert.Equal(t, timeline, r.timeline)
+	assert.Equal(t, []string{"depth", "trades.jsonl"}, r.paths)
+	assert.Equal(t, 1.0, timeline.speed)
+}
+
+// streamedData replays h on c, advancing c whenever the replay sleeps,
+// and returns the data written and the time of c each was written at
+func streamedData(t *testing.T, c *clock.Fake, h MessageFromStreams) ([]string, []time.Time, error) {
+	ctx := context.Background()
+	var data []string
+	var times []time.Time
+	mockConn := NewMockConnection(t)
+	mockConn.On("Write", ctx, mock.AnythingOfType("Message")).Return(nil).Run(func(args mock.Arguments) {
+		data = append(data, string(args.Get(1).(Message).Data))
+		times = append(times, c.Now())
+	}).Maybe()
+
+	replayCtx, replayDone := context.WithCancel(context.Background())
+	var err error
+	go func() {
+		err = h.WithClock(c).Handle(ctx, Message{}, mockConn, nil)
+		replayDone()
+	}()
+	for c.WaitForSleepers(replayCtx, 1) == nil {
+		c.AdvanceToNext()
+	}
+	return data, times, err
+}
+
+func TestMessageFromStreams_Handle(t *testing.T) {
+	first := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	fsys := newReplayFS(first, 3)
+	fsys["trades.jsonl"] = &fstest.MapFile{Data: []byte(
+		fmt.Sprintf(`{"time":%q,"type":"text","data":"t5"}`+"\n", first.Add(5*time.Millisecond).Format(time.RFC3339Nano)) +
+			fmt.Sprintf(`{"time":%q,"type":"text","data":"t15"}`+"\n", first.Add(15*time.Millisecond).Format(time.RFC3339Nano)),
+	)}
+	start := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	c := clock.NewFake(start)
+	h := NewMessageFromStreams(NewTimeline(time.Time{}, 0), "data", "trades.jsonl").WithFS(fsys)
+
+	data, times, err := streamedData(t, c, h)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		fmt.Sprintf(`{"E":%d}`, first.UnixMilli()),
+		"t5",
+		fmt.Sprintf(`{"E":%d}`, first.Add(10*time.Millisecond).UnixMilli()),
+		"t15",
+		fmt.Sprintf(`{"E":%d}`, first.Add(20*time.Millisecond).UnixMilli()),
+	}, data)
+	assert.Equal(t, []time.Time{
+		start,
+		start.Add(5 * time.Millisecond),
+		start.Add(10 * time.Millisecond),
+		start.Add(15 * time.Millisecond),
+		start.Add(20 * time.Millisecond),
+	}, times)
+}
+
+func TestMessageFromStreams_Handle_LateSubscription(t *testing.T) {
+	first := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	fsys := newReplayFS(first, 5)
+	start := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	c := clock.NewFake(start)
+	timeline := NewTimeline(first, 2)
+
+	// The first subscription starts the timeline, the second one joins it 12.5ms later, at 25ms of market time
+	earlyData, earlyTimes, err := streamedData(t, c, NewMessageFromStreams(timeline, "data").WithFS(fsys))
+	assert.NoError(t, err)
+	c.Set(start.Add(12500 * time.Microsecond))
+	lateData, lateTimes, err := streamedData(t, c, NewMessageFromStreams(timeline, "data").WithFS(fsys))
+	assert.NoError(t, err)
+
+	assert.Len(t, earlyData, 5)
+	assert.Equal(t, earlyData[3:], lateData)
+	assert.Equal(t, []time.Time{start.Add(15 * time.Millisecond), start.Add(20 * time.Millisecond)}, lateTimes)
+	assert.Equal(t, earlyTimes[3:], lateTimes)
+}
+
+func TestMessageFromStreams_Handle_LateSubscription_ReadOnce(t *testing.T) {
+	first := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	fsys := newReplayFS(first, 5)
+	start := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	c := clock.NewFake(start)
+	h := NewMessageFromStreams(NewTimeline(first, 1), "data").WithFS(fsys)
+
+	_, _, err := streamedData(t, c, h)
+	assert.NoError(t, err)
+
+	// The late subscription seeks in the records read by the first one, rather than reading the streams again
+	for name := range fsys {
+		delete(fsys, name)
+	}
+	c.Set(start.Add(25 * time.Millisecond))
+	lateData, _, err := streamedData(t, c, h)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{fmt.Sprintf(`{"E":%d}`, first.Add(30*time.Millisecond).UnixMilli()), fmt.Sprintf(`{"E":%d}`, first.Add(40*time.Millisecond).UnixMilli())}, lateData)
+}
+
+func TestMessageFromStreams_Handle_InfiniteSpeed(t *testing.T) {
+	first := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	fsys := newReplayFS(first, 3)
+	start := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	c := clock.NewFake(start)
+	timeline := NewTimeline(time.Time{}, math.Inf(1))
+
+	// The first subscription is written every record at once, and one joining later is past the end of the streams
+	earlyData, earlyTimes, err := streamedData(t, c, NewMessageFromStreams(timeline, "data").WithFS(fsys))
+	assert.NoError(t, err)
+	assert.Len(t, earlyData, 3)
+	assert.Equal(t, []time.Time{start, start, start}, earlyTimes)
+
+	c.Set(start.Add(time.Nanosecond))
+	lateData, _, err := streamedData(t, c, NewMessageFromStreams(timeline, "data").WithFS(fsys))
+	assert.NoError(t, err)
+	assert.Empty(t, lateData)
+}
+
+func TestMessageFromStreams_Handle_NoRecords(t *testing.T) {
+	fsys := fstest.MapFS{"data/README.md": &fstest.MapFile{Data: []byte("no records")}}
+	mockConn := NewMockConnection(t)
+
+	err := NewMessageFromStreams(NewTimeline(time.Time{}, 0), "data").WithFS(fsys).Handle(context.Background(), Message{}, mockConn, nil)
+
+	assert.NoError(t, err)
+}
+
+func TestMessageFromStreams_Handle_Error(t *testing.T) {
+	mockConn := NewMockConnection(t)
+
+	err := NewMessageFromStreams(NewTimeline(time.Time{}, 0), "/non/existent/path").Handle(context.Background(), Message{}, mockConn, nil)
+
+	assert.Error(t, err)
+}
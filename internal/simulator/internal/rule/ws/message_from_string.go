@@ -3,6 +3,8 @@ package ws
 import (
 	"context"
 	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
 )
 
 // Ensure MessageFromString implements MessageHandler
@@ -12,6 +14,7 @@ type MessageFromString struct {
 	messageType  MessageType
 	data         []byte
 	responseTime time.Duration
+	clock        clock.Clock
 }
 
 func NewMessageFromString(messageType MessageType, data string, responseTime time.Duration) MessageFromString {
@@ -22,12 +25,22 @@ func NewMessageFromString(messageType MessageType, data string, responseTime tim
 	}
 }
 
+// WithClock returns a copy of r that waits on c instead of the clock of the simulator
+func (r MessageFromString) WithClock(c clock.Clock) MessageFromString {
+	r.clock = c
+	return r
+}
+
 func (r MessageFromString) Handle(ctx context.Context, _ Message, connClient Connection, _ Connection) error {
 	message := Message{
 		Type: r.messageType,
 		Data: r.data,
 	}
-	time.Sleep(r.responseTime)
-	err := connClient.Write(ctx, message)
+	err := clock.Sleep(ctx, clock.Resolve(ctx, r.clock), r.responseTime)
+	if err != nil {
+		return err
+	}
+
+	err = connClient.Write(ctx, message)
 	return err
 }
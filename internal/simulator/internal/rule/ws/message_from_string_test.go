@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -60,3 +62,31 @@ func TestMessageFromString_Handle(t *testing.T) {
 		})
 	}
 }
+
+func TestMessageFromString_WithClock(t *testing.T) {
+	c := clock.NewFake(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC))
+	h := NewMessageFromString(MessageText, "data", time.Hour).WithClock(c)
+
+	ctx := context.Background()
+	written := make(chan struct{})
+	mockConn := NewMockConnection(t)
+	mockConn.On("Write", ctx, Message{Type: MessageText, Data: []byte("data")}).Return(nil).Run(func(mock.Arguments) {
+		close(written)
+	}).Once()
+
+	done := make(chan error)
+	go func() {
+		done <- h.Handle(ctx, Message{}, mockConn, nil)
+	}()
+
+	assert.NoError(t, c.WaitForSleepers(ctx, 1))
+	c.Advance(time.Hour - time.Second)
+	select {
+	case <-written:
+		t.Fatal("written before the response time")
+	case <-time.After(time.Millisecond):
+	}
+
+	c.Advance(time.Second)
+	assert.NoError(t, <-done)
+}
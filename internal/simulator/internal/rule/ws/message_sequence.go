@@ -0,0 +1,49 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+)
+
+// Ensure MessageSequence implements MessageHandler
+var _ MessageHandler = (*MessageSequence)(nil)
+
+// Ensure MessageSequence implements clockAttacher
+var _ clockAttacher = (*MessageSequence)(nil)
+
+// MessageSequence replays records kept in memory.
+// Each record is written as long after the handling starts as it was recorded after origin.
+type MessageSequence struct {
+	origin  time.Time
+	records []Record
+	clock   clock.Clock
+}
+
+func NewMessageSequence(origin time.Time, records []Record) MessageSequence {
+	return MessageSequence{
+		origin:  origin,
+		records: records,
+	}
+}
+
+// WithClock returns a copy of r that waits on c instead of the clock of the simulator
+func (r MessageSequence) WithClock(c clock.Clock) MessageSequence {
+	r.clock = c
+	return r
+}
+
+func (r MessageSequence) Handle(ctx context.Context, _ Message, connClient Connection, _ Connection) error {
+	open := func() (recordReader, error) {
+		return &sliceRecordReader{records: r.records}, nil
+	}
+
+	return replay(ctx, connClient, open, time.Time{}, r.origin, ReplayOptions{}, r.clock)
+}
+
+func (r MessageSequence) attachClock(ctx context.Context) (MessageHandler, func()) {
+	c, detach := clock.Attach(clock.Resolve(ctx, r.clock))
+	r.clock = c
+	return r, detach
+}
@@ -0,0 +1,85 @@
+package ws
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewMessageSequence(t *testing.T) {
+	origin := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	records := []Record{{Time: origin, Message: Message{Type: MessageText, Data: []byte("data")}}}
+
+	h := NewMessageSequence(origin, records)
+
+	assert.Equal(t, origin, h.origin)
+	assert.Equal(t, records, h.records)
+}
+
+func TestMessageSequence_Handle(t *testing.T) {
+	origin := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	records := []Record{
+		{Time: origin.Add(5 * time.Millisecond), Message: Message{Type: MessageText, Data: []byte("data1")}},
+		{Time: origin.Add(10 * time.Millisecond), Message: Message{Type: MessageBinary, Data: []byte("data2")}},
+	}
+
+	ctx := context.Background()
+	mockConnClient := NewMockConnection(t)
+	mockConnClient.On("Write", ctx, records[0].Message).Return(nil).Once()
+	mockConnClient.On("Write", ctx, records[1].Message).Return(nil).Once()
+	mockConnServer := NewMockConnection(t)
+
+	start := time.Now()
+	err := NewMessageSequence(origin, records).Handle(ctx, Message{}, mockConnClient, mockConnServer)
+	duration := time.Since(start)
+
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, duration, 10*time.Millisecond)
+	assert.LessOrEqual(t, duration, 20*time.Millisecond)
+	mockConnServer.AssertNotCalled(t, "Write", mock.Anything)
+}
+
+func TestMessageSequence_Handle_ContextCancellation(t *testing.T) {
+	origin := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	records := []Record{{Time: origin.Add(time.Hour), Message: Message{Type: MessageText, Data: []byte("data")}}}
+
+	mockConnClient := NewMockConnection(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := NewMessageSequence(origin, records).Handle(ctx, Message{}, mockConnClient, nil)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	mockConnClient.AssertNotCalled(t, "Write", mock.Anything, mock.Anything)
+}
+
+func TestMessageSequence_Handle_ReplayLag(t *testing.T) {
+	origin := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	records := []Record{
+		{Time: origin, Message: Message{Type: MessageText, Data: []byte("data1")}},
+		{Time: origin.Add(10 * time.Millisecond), Message: Message{Type: MessageText, Data: []byte("data2")}},
+	}
+	c := clock.NewFake(origin)
+	registry := metrics.NewRegistry()
+	ctx := metrics.NewContext(context.Background(), registry)
+
+	// Every write takes 30ms, so that the messages fall 30ms and 50ms behind
+	mockConnClient := NewMockConnection(t)
+	mockConnClient.On("Write", ctx, mock.Anything).Run(func(mock.Arguments) { c.Advance(30 * time.Millisecond) }).Return(nil)
+
+	err := NewMessageSequence(origin, records).WithClock(c).Handle(ctx, Message{}, mockConnClient, nil)
+	assert.NoError(t, err)
+
+	var text strings.Builder
+	assert.NoError(t, registry.WriteText(&text))
+	assert.Contains(t, text.String(), `exchangesimulator_replay_lag_seconds_bucket{le="0.025"} 0`)
+	assert.Contains(t, text.String(), `exchangesimulator_replay_lag_seconds_bucket{le="0.05"} 2`)
+	assert.Contains(t, text.String(), "exchangesimulator_replay_lag_seconds_count 2")
+}
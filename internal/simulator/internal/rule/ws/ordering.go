@@ -0,0 +1,55 @@
+package ws
+
+// Ordering tells how the messages a rule handles are ordered with the other messages of a connection
+type Ordering int
+
+const (
+	// OrderingSequential handles the messages of a rule one after another, in the order they were received,
+	// but concurrently with the messages of other rules
+	OrderingSequential Ordering = iota
+	// OrderingConcurrent handles every message of a rule as soon as it is received, concurrently with any other message
+	OrderingConcurrent
+	// OrderingBlocking handles a message of a rule before the next message of the connection is read,
+	// as if the connection had nothing else to do
+	OrderingBlocking
+)
+
+// OrderedRule is a rule that tells how its messages are ordered.
+// The messages of a rule that does not are handled with OrderingSequential.
+type OrderedRule interface {
+	Rule
+	Ordering() Ordering
+}
+
+// OrderingOf returns how the messages of rule are ordered
+func OrderingOf(rule Rule) Ordering {
+	if r, ok := rule.(OrderedRule); ok {
+		return r.Ordering()
+	}
+	return OrderingSequential
+}
+
+// Ensure OrderedRuleImpl implements OrderedRule
+var _ OrderedRule = (*OrderedRuleImpl)(nil)
+
+type OrderedRuleImpl struct {
+	Rule
+	ordering Ordering
+}
+
+func NewOrderedRule(rule Rule, ordering Ordering) OrderedRuleImpl {
+	return OrderedRuleImpl{Rule: rule, ordering: ordering}
+}
+
+func (r OrderedRuleImpl) Ordering() Ordering {
+	return r.ordering
+}
+
+// Unwrap returns the rule whose messages are ordered
+func (r OrderedRuleImpl) Unwrap() Rule {
+	return r.Rule
+}
+
+func (r OrderedRuleImpl) Reset() {
+	reset(r.Rule)
+}
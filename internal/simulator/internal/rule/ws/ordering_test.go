@@ -0,0 +1,15 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderingOf(t *testing.T) {
+	rule := NewRule(nil, nil)
+
+	assert.Equal(t, OrderingSequential, OrderingOf(rule))
+	assert.Equal(t, OrderingConcurrent, OrderingOf(NewOrderedRule(rule, OrderingConcurrent)))
+	assert.Equal(t, OrderingBlocking, OrderingOf(NewOrderedRule(rule, OrderingBlocking)))
+}
@@ -0,0 +1,116 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
+)
+
+// Record is a message with the time it was sent or received at
+type Record struct {
+	Time    time.Time
+	Message Message
+}
+
+type recordJson struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	Data string    `json:"data"`
+}
+
+func (r Record) MarshalJSON() ([]byte, error) {
+	typeStr, dataStr, err := formatMessage(r.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(recordJson{Time: r.Time, Type: typeStr, Data: dataStr})
+}
+
+func (r *Record) UnmarshalJSON(data []byte) error {
+	var v recordJson
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+
+	message, err := parseMessage(v.Type, v.Data)
+	if err != nil {
+		return err
+	}
+
+	*r = Record{Time: v.Time, Message: message}
+	return nil
+}
+
+// RecordWriter appends records to a single JSON Lines file, one record per line.
+// The file is created on the first record.
+type RecordWriter struct {
+	writer jsonLinesWriter
+}
+
+func NewRecordWriter(path string) *RecordWriter {
+	return &RecordWriter{
+		writer: jsonLinesWriter{path: path},
+	}
+}
+
+func (w *RecordWriter) Write(record Record) error {
+	return w.writer.write(record)
+}
+
+func (w *RecordWriter) Close() error {
+	return w.writer.close()
+}
+
+// recordFileReader reads the records of a JSON Lines file one at a time
+type recordFileReader struct {
+	file   io.ReadCloser
+	reader jsonLinesReader
+}
+
+func openRecordFile(fsys fs.FS, name string) (*recordFileReader, error) {
+	f, err := fileio.Open(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recordFileReader{file: f, reader: newJsonLinesReader(f)}, nil
+}
+
+func (r *recordFileReader) Next() (Record, error) {
+	var record Record
+	err := r.reader.read(&record)
+	if err != nil {
+		return Record{}, err
+	}
+	return record, nil
+}
+
+func (r *recordFileReader) Close() error {
+	return r.file.Close()
+}
+
+func ReadRecordsFromFile(path string) ([]Record, error) {
+	reader, err := openRecordFile(fileio.OS, path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var records []Record
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,38 @@
+package ws
+
+import (
+	"errors"
+	"io"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
+)
+
+// ConvertRecordDir appends every message of a directory or archive in the one-file-per-message layout,
+// as replayed by MessageFromFiles, to a record file as replayed by MessageFromRecordFile
+func ConvertRecordDir(dirPath string, filePath string) error {
+	reader, err := openMessageFiles(fileio.OS, dirPath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	writer := NewRecordWriter(filePath)
+	defer writer.Close()
+
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		err = writer.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
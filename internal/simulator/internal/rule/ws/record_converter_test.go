@@ -0,0 +1,44 @@
+package ws
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertRecordDir(t *testing.T) {
+	tempDir := t.TempDir()
+	dirPath := filepath.Join(tempDir, "depth_update")
+	require.NoError(t, os.Mkdir(dirPath, 0755))
+	files := map[string]string{
+		"2000-01-23T12:34:56.000000+09:00.yaml": "type: text\ndata: 'data1'\n",
+		"2000-01-23T12:34:56.010000+09:00.yaml": "type: binary\ndata: '0102'\n",
+		"non_yaml.txt":                          "type: text\ndata: 'ignored'\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dirPath, name), []byte(content), 0644))
+	}
+
+	filePath := filepath.Join(tempDir, "depth_update.jsonl")
+	err := ConvertRecordDir(dirPath, filePath)
+	require.NoError(t, err)
+
+	records, err := ReadRecordsFromFile(filePath)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	t0 := time.Date(2000, 1, 23, 12, 34, 56, 0, time.FixedZone("", 9*60*60))
+	assert.True(t, t0.Equal(records[0].Time))
+	assert.Equal(t, Message{Type: MessageText, Data: []byte("data1")}, records[0].Message)
+	assert.True(t, t0.Add(10*time.Millisecond).Equal(records[1].Time))
+	assert.Equal(t, Message{Type: MessageBinary, Data: []byte{0x01, 0x02}}, records[1].Message)
+}
+
+func TestConvertRecordDir_Error(t *testing.T) {
+	err := ConvertRecordDir("/non/existent/path", filepath.Join(t.TempDir(), "out.jsonl"))
+	assert.Error(t, err)
+}
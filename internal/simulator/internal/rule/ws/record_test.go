@@ -0,0 +1,109 @@
+package ws
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord_MarshalJSON(t *testing.T) {
+	recordTime := time.Date(2000, 1, 23, 12, 34, 56, 10000000, time.FixedZone("", 9*60*60))
+
+	tests := []struct {
+		name         string
+		record       Record
+		expectedJson string
+	}{
+		{
+			name:         "Text message",
+			record:       Record{Time: recordTime, Message: Message{Type: MessageText, Data: []byte(`{"method":"depth_update"}`)}},
+			expectedJson: `{"time":"2000-01-23T12:34:56.01+09:00","type":"text","data":"{\"method\":\"depth_update\"}"}`,
+		},
+		{
+			name:         "Binary message",
+			record:       Record{Time: recordTime, Message: Message{Type: MessageBinary, Data: []byte{0x01, 0x02}}},
+			expectedJson: `{"time":"2000-01-23T12:34:56.01+09:00","type":"binary","data":"0102"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.record)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.expectedJson, string(data))
+
+			var decoded Record
+			err = json.Unmarshal(data, &decoded)
+			require.NoError(t, err)
+			assert.True(t, tt.record.Time.Equal(decoded.Time))
+			assert.Equal(t, tt.record.Message, decoded.Message)
+		})
+	}
+}
+
+func TestRecord_UnmarshalJSON_Error(t *testing.T) {
+	var r Record
+	assert.Error(t, json.Unmarshal([]byte(`{"time":"2000-01-23T12:34:56Z","type":"unknown","data":""}`), &r))
+	assert.Error(t, json.Unmarshal([]byte(`{"time":"invalid","type":"text","data":""}`), &r))
+}
+
+func TestRecordWriter_Write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records", "depth_update.jsonl")
+	records := []Record{
+		{Time: time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC), Message: Message{Type: MessageText, Data: []byte("data1")}},
+		{Time: time.Date(2000, 1, 23, 12, 34, 57, 0, time.UTC), Message: Message{Type: MessageText, Data: []byte("data2")}},
+	}
+
+	w := NewRecordWriter(path)
+	for _, r := range records {
+		require.NoError(t, w.Write(r))
+	}
+	require.NoError(t, w.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t,
+		`{"time":"2000-01-23T12:34:56Z","type":"text","data":"data1"}`+"\n"+
+			`{"time":"2000-01-23T12:34:57Z","type":"text","data":"data2"}`+"\n",
+		string(content),
+	)
+
+	read, err := ReadRecordsFromFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, records, read)
+}
+
+func TestReadRecordsFromFile_Error(t *testing.T) {
+	_, err := ReadRecordsFromFile("/non/existent/path.jsonl")
+	assert.Error(t, err)
+}
+
+func TestReadRecordsFromFile_Truncated(t *testing.T) {
+	for _, filename := range []string{"records.jsonl.gz", "records.jsonl.zst"} {
+		t.Run(filename, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), filename)
+			w := NewRecordWriter(path)
+			for i := range 100 {
+				record := Record{
+					Time:    time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC).Add(time.Duration(i) * time.Second),
+					Message: Message{Type: MessageText, Data: []byte(strings.Repeat("data", i))},
+				}
+				require.NoError(t, w.Write(record))
+			}
+			require.NoError(t, w.Close())
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, content[:len(content)-10], 0644))
+
+			_, err = ReadRecordsFromFile(path)
+			assert.Error(t, err)
+		})
+	}
+}
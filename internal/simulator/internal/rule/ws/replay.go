@@ -0,0 +1,432 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/metrics"
+)
+
+// recordReader yields records in time order. Next returns io.EOF after the last record.
+type recordReader interface {
+	Next() (Record, error)
+	Close() error
+}
+
+// clockAttacher is implemented by handlers that replay on a clock.
+// A handler run in a goroutine is attached to its clock before the goroutine starts,
+// so that a lockstep clock cannot be stepped before the replay is ready for it; see clock.Attach.
+type clockAttacher interface {
+	attachClock(ctx context.Context) (MessageHandler, func())
+}
+
+// ReplayOptions control how recorded messages are replayed.
+// The zero value replays every record once at the speed it was recorded at.
+type ReplayOptions struct {
+	// Speed is how many times as fast as recorded the records are replayed.
+	// Zero stands for 1, and math.Inf(1) writes the records as fast as possible.
+	Speed float64
+
+	// Loop replays the records over and over until the connection is closed.
+	// Each loop starts as long after the last record of the previous one as the records are apart on average,
+	// or a second after if they were all recorded at once.
+	// Unix timestamps in the text messages of later loops are shifted by as long as the loops are apart,
+	// so that they keep increasing; see shiftTimestamps.
+	Loop bool
+
+	// StartOffset skips the records recorded within this long after the first record
+	StartOffset time.Duration
+
+	// From and To skip the records recorded before From or at or after To, if they are not zero
+	From time.Time
+	To   time.Time
+}
+
+func (o ReplayOptions) speed() float64 {
+	if o.Speed <= 0 {
+		return 1
+	}
+	return o.Speed
+}
+
+// replay writes the records of open to conn as long after start as they were recorded after origin,
+// scaled by options.Speed. A zero start stands for when the replay starts,
+// and a zero origin for the time of the first record replayed. A nil c stands for the clock carried by ctx.
+func replay(ctx context.Context, conn Connection, open func() (recordReader, error), start time.Time, origin time.Time, options ReplayOptions, c clock.Clock) error {
+	c, detach := clock.Attach(clock.Resolve(ctx, c))
+	defer detach()
+
+	if start.IsZero() {
+		start = c.Now()
+	}
+	r := replayer{conn: conn, options: options, clock: c, startTime: start, origin: origin}
+	for loop := 0; ; loop++ {
+		if loop > 0 && (!options.Loop || r.count == 0) {
+			return nil
+		}
+
+		reader, err := open()
+		if err != nil {
+			return err
+		}
+		err = r.replayOnce(ctx, reader, loop)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type replayer struct {
+	conn      Connection
+	options   ReplayOptions
+	clock     clock.Clock
+	startTime time.Time
+	origin    time.Time
+
+	// The first and last time and the number of the records replayed by the first loop
+	first time.Time
+	last  time.Time
+	count int
+}
+
+// replayOnce replays the records of reader for the given loop, counted from 0
+func (r *replayer) replayOnce(ctx context.Context, reader recordReader, loop int) error {
+	shift := time.Duration(loop) * r.loopPeriod()
+	var skipBefore time.Time
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if skipBefore.IsZero() {
+			skipBefore = record.Time.Add(r.options.StartOffset)
+			if r.options.From.After(skipBefore) {
+				skipBefore = r.options.From
+			}
+		}
+		if record.Time.Before(skipBefore) {
+			continue
+		}
+		if !r.options.To.IsZero() && !record.Time.Before(r.options.To) {
+			return nil
+		}
+
+		if loop == 0 {
+			if r.count == 0 {
+				r.first = record.Time
+			}
+			r.last = record.Time
+			r.count++
+		} else if record.Message.Type == MessageText {
+			record.Message.Data = shiftTimestamps(record.Message.Data, r.first, r.last, shift)
+		}
+
+		if r.origin.IsZero() {
+			r.origin = record.Time
+		}
+		delay := time.Duration(float64(record.Time.Add(shift).Sub(r.origin)) / r.options.speed())
+		scheduled := r.startTime.Add(delay)
+		err = r.clock.SleepUntil(ctx, scheduled)
+		if err != nil {
+			return err
+		}
+
+		err = r.conn.Write(ctx, record.Message)
+		if err != nil {
+			return err
+		}
+		metrics.FromContext(ctx).Observe(metrics.ReplayLag, r.clock.Now().Sub(scheduled).Seconds())
+	}
+}
+
+// loopPeriod is how far apart the loops are
+func (r *replayer) loopPeriod() time.Duration {
+	span := r.last.Sub(r.first)
+	if span == 0 {
+		return time.Second
+	}
+	return span + span/time.Duration(r.count-1)
+}
+
+// timestampMargin is how far outside the time range of the recording a number may be and still be taken for a timestamp,
+// as exchanges stamp events a little before they are received
+const timestampMargin = time.Minute
+
+var integerPattern = regexp.MustCompile(`[0-9]+`)
+
+// shiftTimestamps adds shift to every Unix timestamp in data that lies between first and last, give or take timestampMargin.
+// A timestamp is an integer of 10, 13, 16 or 19 digits, in seconds, milliseconds, microseconds or nanoseconds respectively,
+// which is not the fraction of a decimal number.
+func shiftTimestamps(data []byte, first time.Time, last time.Time, shift time.Duration) []byte {
+	lower := first.Add(-timestampMargin).UnixNano()
+	upper := last.Add(timestampMargin).UnixNano()
+
+	var result []byte
+	prev := 0
+	for _, loc := range integerPattern.FindAllIndex(data, -1) {
+		if loc[0] > 0 && data[loc[0]-1] == '.' {
+			continue
+		}
+
+		var unit int64
+		switch loc[1] - loc[0] {
+		case 10:
+			unit = int64(time.Second)
+		case 13:
+			unit = int64(time.Millisecond)
+		case 16:
+			unit = int64(time.Microsecond)
+		case 19:
+			unit = int64(time.Nanosecond)
+		default:
+			continue
+		}
+
+		v, err := strconv.ParseInt(string(data[loc[0]:loc[1]]), 10, 64)
+		if err != nil || v > upper/unit || v < lower/unit {
+			continue
+		}
+
+		result = append(result, data[prev:loc[0]]...)
+		result = strconv.AppendInt(result, v+int64(shift)/unit, 10)
+		prev = loc[1]
+	}
+	if result == nil {
+		return data
+	}
+	return append(result, data[prev:]...)
+}
+
+// sliceRecordReader reads records kept in memory
+type sliceRecordReader struct {
+	records []Record
+}
+
+func (r *sliceRecordReader) Next() (Record, error) {
+	if len(r.records) == 0 {
+		return Record{}, io.EOF
+	}
+
+	record := r.records[0]
+	r.records = r.records[1:]
+	return record, nil
+}
+
+func (r *sliceRecordReader) Close() error {
+	return nil
+}
+
+// dirRecordReader reads a directory of YAML messages named by the RFC 3339 time they were recorded at,
+// one file at a time in time order
+type dirRecordReader struct {
+	fsys    fs.FS
+	dirPath string
+	files   []string
+	times   []time.Time
+}
+
+func openRecordDir(fsys fs.FS, dirPath string) (*dirRecordReader, error) {
+	entries, err := fs.ReadDir(fsys, dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &dirRecordReader{fsys: fsys, dirPath: dirPath}
+	for _, e := range entries {
+		if e.IsDir() || !isMessageFile(e.Name()) {
+			continue
+		}
+		t, err := parseTime(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		r.files = append(r.files, e.Name())
+		r.times = append(r.times, t)
+	}
+
+	// Names do not sort by time if they differ in the number of fractional digits or in the time zone
+	sort.Stable(r)
+	return r, nil
+}
+
+func (r *dirRecordReader) Len() int           { return len(r.files) }
+func (r *dirRecordReader) Less(i, j int) bool { return r.times[i].Before(r.times[j]) }
+func (r *dirRecordReader) Swap(i, j int) {
+	r.files[i], r.files[j] = r.files[j], r.files[i]
+	r.times[i], r.times[j] = r.times[j], r.times[i]
+}
+
+func (r *dirRecordReader) Next() (Record, error) {
+	if len(r.files) == 0 {
+		return Record{}, io.EOF
+	}
+
+	f, t := r.files[0], r.times[0]
+	r.files, r.times = r.files[1:], r.times[1:]
+
+	message, err := ReadFromFS(r.fsys, path.Join(r.dirPath, f))
+	if err != nil {
+		return Record{}, err
+	}
+
+	return Record{Time: t, Message: message}, nil
+}
+
+func (r *dirRecordReader) Close() error {
+	return nil
+}
+
+// archiveRecordReader reads the YAML messages of a tar archive one file at a time.
+// As the archive is streamed, the files must have been archived in time order, e.g. with tar --sort=name.
+type archiveRecordReader struct {
+	archive *fileio.ArchiveReader
+	last    time.Time
+}
+
+func openRecordArchive(fsys fs.FS, name string) (*archiveRecordReader, error) {
+	a, err := fileio.OpenArchive(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &archiveRecordReader{archive: a}, nil
+}
+
+func (r *archiveRecordReader) Next() (Record, error) {
+	for {
+		name, content, err := r.archive.Next()
+		if err != nil {
+			return Record{}, err
+		}
+
+		filename := path.Base(name)
+		if !isMessageFile(filename) {
+			continue
+		}
+
+		t, err := parseTime(filename)
+		if err != nil {
+			return Record{}, err
+		}
+		if t.Before(r.last) {
+			return Record{}, fmt.Errorf("%s is archived after a later message; archive the messages in time order, e.g. with tar --sort=name", name)
+		}
+		r.last = t
+
+		decompressed, err := fileio.Decompress(filename, content)
+		if err != nil {
+			return Record{}, err
+		}
+		data, err := io.ReadAll(decompressed)
+		decompressed.Close()
+		if err != nil {
+			return Record{}, err
+		}
+
+		message, err := decodeMessage(data)
+		if err != nil {
+			return Record{}, err
+		}
+
+		return Record{Time: t, Message: message}, nil
+	}
+}
+
+func (r *archiveRecordReader) Close() error {
+	return r.archive.Close()
+}
+
+// mergedRecordReader reads several record readers as one, in time order.
+// Records of the same time are read in the order of the readers.
+type mergedRecordReader struct {
+	readers []recordReader
+	// heads are the next record of each reader, or nil if it has not been read yet
+	heads []*Record
+}
+
+func newMergedRecordReader(readers []recordReader) *mergedRecordReader {
+	return &mergedRecordReader{readers: readers, heads: make([]*Record, len(readers))}
+}
+
+func (r *mergedRecordReader) Next() (Record, error) {
+	next := -1
+	for i, reader := range r.readers {
+		if reader == nil {
+			continue
+		}
+		if r.heads[i] == nil {
+			record, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				reader.Close()
+				r.readers[i] = nil
+				continue
+			}
+			if err != nil {
+				return Record{}, err
+			}
+			r.heads[i] = &record
+		}
+		if next < 0 || r.heads[i].Time.Before(r.heads[next].Time) {
+			next = i
+		}
+	}
+	if next < 0 {
+		return Record{}, io.EOF
+	}
+
+	record := *r.heads[next]
+	r.heads[next] = nil
+	return record, nil
+}
+
+func (r *mergedRecordReader) Close() error {
+	var errs []error
+	for _, reader := range r.readers {
+		if reader != nil {
+			errs = append(errs, reader.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// openRecords reads either a JSON Lines record file, optionally compressed, or the messages of a directory or a tar archive of fsys
+func openRecords(fsys fs.FS, name string) (recordReader, error) {
+	if strings.HasSuffix(fileio.TrimExtension(name), ".jsonl") {
+		return openRecordFile(fsys, name)
+	}
+	return openMessageFiles(fsys, name)
+}
+
+// openMessageFiles reads the messages of either a directory or a tar archive of fsys
+func openMessageFiles(fsys fs.FS, name string) (recordReader, error) {
+	if fileio.IsArchive(name) {
+		return openRecordArchive(fsys, name)
+	}
+	return openRecordDir(fsys, name)
+}
+
+// isMessageFile tells whether filename is a YAML message, optionally compressed
+func isMessageFile(filename string) bool {
+	return strings.HasSuffix(fileio.TrimExtension(filename), ".yaml")
+}
+
+func parseTime(filename string) (time.Time, error) {
+	f, _ := strings.CutSuffix(fileio.TrimExtension(filename), ".yaml")
+	return time.Parse(time.RFC3339Nano, f)
+}
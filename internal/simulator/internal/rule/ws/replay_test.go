@@ -0,0 +1,225 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShiftTimestamps(t *testing.T) {
+	first := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	last := first.Add(10 * time.Second)
+	shift := 20 * time.Second
+
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			name:     "Milliseconds",
+			data:     fmt.Sprintf(`{"E":%d,"T":%d,"u":123}`, first.UnixMilli(), last.UnixMilli()),
+			expected: fmt.Sprintf(`{"E":%d,"T":%d,"u":123}`, first.Add(shift).UnixMilli(), last.Add(shift).UnixMilli()),
+		},
+		{
+			name:     "Seconds, microseconds and nanoseconds",
+			data:     fmt.Sprintf(`[%d,"%d",%d]`, first.Unix(), first.UnixMicro(), first.UnixNano()),
+			expected: fmt.Sprintf(`[%d,"%d",%d]`, first.Add(shift).Unix(), first.Add(shift).UnixMicro(), first.Add(shift).UnixNano()),
+		},
+		{
+			name:     "Within margin",
+			data:     fmt.Sprintf(`{"E":%d}`, first.Add(-time.Second).UnixMilli()),
+			expected: fmt.Sprintf(`{"E":%d}`, first.Add(-time.Second).Add(shift).UnixMilli()),
+		},
+		{
+			name:     "Outside of recording",
+			data:     fmt.Sprintf(`{"E":%d}`, first.Add(-time.Hour).UnixMilli()),
+			expected: fmt.Sprintf(`{"E":%d}`, first.Add(-time.Hour).UnixMilli()),
+		},
+		{
+			name:     "Fraction",
+			data:     fmt.Sprintf(`{"p":0.%d}`, first.UnixMilli()),
+			expected: fmt.Sprintf(`{"p":0.%d}`, first.UnixMilli()),
+		},
+		{
+			name:     "No numbers",
+			data:     `{"e":"depthUpdate"}`,
+			expected: `{"e":"depthUpdate"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := shiftTimestamps([]byte(tt.data), first, last, shift)
+			assert.Equal(t, tt.expected, string(result))
+		})
+	}
+}
+
+// newReplayFS returns a directory of text messages recorded 10ms apart, each holding its time in milliseconds
+func newReplayFS(first time.Time, count int) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for i := range count {
+		t := first.Add(time.Duration(i) * 10 * time.Millisecond)
+		content := fmt.Sprintf("type: text\ndata: '{\"E\":%d}'", t.UnixMilli())
+		fsys["data/"+t.Format("2006-01-02T15:04:05.000000Z07:00")+".yaml"] = &fstest.MapFile{Data: []byte(content)}
+	}
+	return fsys
+}
+
+// replayedData replays h on a fake clock, advancing it whenever the replay sleeps,
+// and returns the data written and how long after the start each was written.
+// The replay is cancelled once limit messages are written if limit is positive.
+func replayedData(t *testing.T, h MessageFromFiles, limit int) ([]string, []time.Duration, error) {
+	start := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	c := clock.NewFake(start)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var data []string
+	var offsets []time.Duration
+	mockConn := NewMockConnection(t)
+	mockConn.On("Write", ctx, mock.AnythingOfType("Message")).Return(nil).Run(func(args mock.Arguments) {
+		data = append(data, string(args.Get(1).(Message).Data))
+		offsets = append(offsets, c.Now().Sub(start))
+		if len(data) == limit {
+			cancel()
+		}
+	}).Maybe()
+
+	replayCtx, replayDone := context.WithCancel(context.Background())
+	var err error
+	go func() {
+		err = h.WithClock(c).Handle(ctx, Message{}, mockConn, nil)
+		replayDone()
+	}()
+	for c.WaitForSleepers(replayCtx, 1) == nil {
+		c.AdvanceToNext()
+	}
+	return data, offsets, err
+}
+
+func TestMessageFromFiles_WithOptions(t *testing.T) {
+	first := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	fsys := newReplayFS(first, 5)
+	data := func(indexes ...int) []string {
+		var result []string
+		for _, i := range indexes {
+			result = append(result, fmt.Sprintf(`{"E":%d}`, first.Add(time.Duration(i)*10*time.Millisecond).UnixMilli()))
+		}
+		return result
+	}
+	ms := func(offsets ...float64) []time.Duration {
+		var result []time.Duration
+		for _, o := range offsets {
+			result = append(result, time.Duration(o*float64(time.Millisecond)))
+		}
+		return result
+	}
+
+	tests := []struct {
+		name            string
+		options         ReplayOptions
+		expectedData    []string
+		expectedOffsets []time.Duration
+	}{
+		{
+			name:            "Default",
+			options:         ReplayOptions{},
+			expectedData:    data(0, 1, 2, 3, 4),
+			expectedOffsets: ms(0, 10, 20, 30, 40),
+		},
+		{
+			name:            "Speed",
+			options:         ReplayOptions{Speed: 4},
+			expectedData:    data(0, 1, 2, 3, 4),
+			expectedOffsets: ms(0, 2.5, 5, 7.5, 10),
+		},
+		{
+			name:            "As fast as possible",
+			options:         ReplayOptions{Speed: math.Inf(1)},
+			expectedData:    data(0, 1, 2, 3, 4),
+			expectedOffsets: ms(0, 0, 0, 0, 0),
+		},
+		{
+			name:            "Start offset",
+			options:         ReplayOptions{StartOffset: 25 * time.Millisecond},
+			expectedData:    data(3, 4),
+			expectedOffsets: ms(0, 10),
+		},
+		{
+			name:            "Time window",
+			options:         ReplayOptions{From: first.Add(10 * time.Millisecond), To: first.Add(30 * time.Millisecond)},
+			expectedData:    data(1, 2),
+			expectedOffsets: ms(0, 10),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewMessageFromFiles("data").WithFS(fsys).WithOptions(tt.options)
+
+			result, offsets, err := replayedData(t, h, 0)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedData, result)
+			assert.Equal(t, tt.expectedOffsets, offsets)
+		})
+	}
+}
+
+func TestMessageFromFiles_WithOptions_Loop(t *testing.T) {
+	first := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	fsys := newReplayFS(first, 3)
+	h := NewMessageFromFiles("data").WithFS(fsys).WithOptions(ReplayOptions{Speed: 10, Loop: true})
+
+	// The loops are 30ms apart, which is 3ms at 10x speed
+	result, offsets, err := replayedData(t, h, 7)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	require.Len(t, result, 7)
+	for i := range result {
+		expected := fmt.Sprintf(`{"E":%d}`, first.Add(time.Duration(i)*10*time.Millisecond).UnixMilli())
+		assert.Equal(t, expected, result[i])
+		assert.Equal(t, time.Duration(i)*time.Millisecond, offsets[i])
+	}
+}
+
+func TestMessageFromFiles_WithClock(t *testing.T) {
+	// An hour of messages a second apart
+	first := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{}
+	for i := range 3600 {
+		t := first.Add(time.Duration(i) * time.Second)
+		fsys["data/"+t.Format("2006-01-02T15:04:05.000000Z07:00")+".yaml"] = &fstest.MapFile{Data: []byte("type: text\ndata: data")}
+	}
+	c := clock.NewFake(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC))
+	h := NewMessageFromFiles("data").WithFS(fsys).WithClock(c)
+
+	ctx := context.Background()
+	mockConn := NewMockConnection(t)
+	mockConn.On("Write", ctx, Message{Type: MessageText, Data: []byte("data")}).Return(nil).Times(3600)
+
+	// Advance the clock whenever the replay sleeps, until it is done
+	replayCtx, replayDone := context.WithCancel(context.Background())
+	var err error
+	go func() {
+		err = h.Handle(ctx, Message{}, mockConn, nil)
+		replayDone()
+	}()
+	for c.WaitForSleepers(replayCtx, 1) == nil {
+		c.AdvanceToNext()
+	}
+
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2000, 1, 23, 13, 34, 55, 0, time.UTC), c.Now())
+}
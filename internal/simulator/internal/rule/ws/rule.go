@@ -19,6 +19,19 @@ type MessageHandler interface {
 	Handle(context.Context, Message, Connection, Connection) error
 }
 
+// Resetter is implemented by the rules and handlers that keep state as they handle messages, such as subscriptions,
+// to put it back as it was when they were made, e.g. as the simulator is reset. The wrappers of a rule or handler reset it.
+type Resetter interface {
+	Reset()
+}
+
+// reset resets v if it is a Resetter
+func reset(v any) {
+	if r, ok := v.(Resetter); ok {
+		r.Reset()
+	}
+}
+
 // Ensure RuleImpl implements Rule
 var _ Rule = (*RuleImpl)(nil)
 
@@ -30,3 +43,7 @@ type RuleImpl struct {
 func NewRule(messageMatcher MessageMatcher, messageHandler MessageHandler) RuleImpl {
 	return RuleImpl{MessageMatcher: messageMatcher, MessageHandler: messageHandler}
 }
+
+func (r RuleImpl) Reset() {
+	reset(r.MessageHandler)
+}
@@ -0,0 +1,141 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/fileio"
+)
+
+type Direction string
+
+const (
+	DirectionClientToServer Direction = "client_to_server"
+	DirectionServerToClient Direction = "server_to_client"
+)
+
+type SessionEventKind string
+
+const (
+	SessionEventOpen    SessionEventKind = "open"
+	SessionEventMessage SessionEventKind = "message"
+	SessionEventClose   SessionEventKind = "close"
+)
+
+// SessionEvent is an entry of a recorded WebSocket session.
+// For a close event, Direction tells which side the connection was closed from,
+// and CloseCode is -1 if it ended without a close frame.
+type SessionEvent struct {
+	Time         time.Time
+	ConnectionId uint64
+	Kind         SessionEventKind
+	Direction    Direction
+	Message      Message
+	CloseCode    int
+	CloseReason  string
+}
+
+type sessionEventJson struct {
+	Time         time.Time        `json:"time"`
+	ConnectionId uint64           `json:"connection_id"`
+	Kind         SessionEventKind `json:"event"`
+	Direction    Direction        `json:"direction,omitempty"`
+	Type         string           `json:"type,omitempty"`
+	Data         string           `json:"data,omitempty"`
+	CloseCode    int              `json:"close_code,omitempty"`
+	CloseReason  string           `json:"close_reason,omitempty"`
+}
+
+func (e SessionEvent) MarshalJSON() ([]byte, error) {
+	v := sessionEventJson{
+		Time:         e.Time,
+		ConnectionId: e.ConnectionId,
+		Kind:         e.Kind,
+		Direction:    e.Direction,
+		CloseCode:    e.CloseCode,
+		CloseReason:  e.CloseReason,
+	}
+	if e.Kind == SessionEventMessage {
+		typeStr, dataStr, err := formatMessage(e.Message)
+		if err != nil {
+			return nil, err
+		}
+		v.Type = typeStr
+		v.Data = dataStr
+	}
+	return json.Marshal(v)
+}
+
+func (e *SessionEvent) UnmarshalJSON(data []byte) error {
+	var v sessionEventJson
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+
+	*e = SessionEvent{
+		Time:         v.Time,
+		ConnectionId: v.ConnectionId,
+		Kind:         v.Kind,
+		Direction:    v.Direction,
+		CloseCode:    v.CloseCode,
+		CloseReason:  v.CloseReason,
+	}
+	if v.Kind == SessionEventMessage {
+		message, err := parseMessage(v.Type, v.Data)
+		if err != nil {
+			return err
+		}
+		e.Message = message
+	}
+	return nil
+}
+
+// SessionRecorder appends the events of every connection to a single JSON Lines file.
+// The file is created on the first event.
+type SessionRecorder struct {
+	writer jsonLinesWriter
+}
+
+func NewSessionRecorder(path string) *SessionRecorder {
+	return &SessionRecorder{
+		writer: jsonLinesWriter{path: path},
+	}
+}
+
+func (r *SessionRecorder) Record(event SessionEvent) error {
+	return r.writer.write(event)
+}
+
+func (r *SessionRecorder) Close() error {
+	return r.writer.close()
+}
+
+// ReadSessionFromFile reads a session written by SessionRecorder, gzip or zstd compressed or not
+func ReadSessionFromFile(path string) ([]SessionEvent, error) {
+	f, err := fileio.Open(fileio.OS, path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadSession(f)
+}
+
+func ReadSession(r io.Reader) ([]SessionEvent, error) {
+	var events []SessionEvent
+	reader := newJsonLinesReader(r)
+	for {
+		var e SessionEvent
+		err := reader.read(&e)
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+}
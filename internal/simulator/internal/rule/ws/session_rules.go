@@ -0,0 +1,289 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+type sessionSubscription struct {
+	// keys tell the streams or channels the subscription is about; see messageKeys
+	keys           []string
+	request        Record
+	response       *Record
+	unsubscription *Record
+	unsubResponse  *Record
+	updates        []Record
+}
+
+// DeriveSubscriptionRules builds a subscription rule for every distinct subscribe message a client sent in a recorded session.
+//
+// A client message is a subscription if it contains "subscribe" and an unsubscription if it contains "unsubscribe", ignoring case.
+// The response to a client message is the next server message with the same JSON "id", or the next server message if it has no id.
+// Any other server message is an update of the subscription of its connection, not unsubscribed yet, that shares the most keys with it,
+// or of the latest of those if several do. The keys of a JSON message are the streams, channels and topics it names,
+// such as btcusdt@depth in {"stream":"btcusdt@depth"} and ETH_BTC in {"method":"depth_subscribe","params":["ETH_BTC"]},
+// and its method without "subscribe", "unsubscribe" or "update", such as depth. A server message sharing no key with any subscription,
+// e.g. one that is not JSON, is an update of none. An unsubscription ends the subscription it shares the most keys with the same way,
+// or the latest one if it has no keys.
+// Updates are replayed with the delays they had after the subscription.
+func DeriveSubscriptionRules(events []SessionEvent) []*SubscriptionRule {
+	var rules []*SubscriptionRule
+	var seen [][]byte
+	for _, connEvents := range groupByConnection(events) {
+		for _, s := range deriveSubscriptions(connEvents) {
+			if slices.ContainsFunc(seen, func(d []byte) bool { return bytes.Equal(d, s.request.Message.Data) }) {
+				continue
+			}
+			seen = append(seen, s.request.Message.Data)
+
+			rules = append(rules, newDerivedSubscriptionRule(s))
+		}
+	}
+	return rules
+}
+
+func groupByConnection(events []SessionEvent) [][]SessionEvent {
+	var ids []uint64
+	groups := map[uint64][]SessionEvent{}
+	for _, e := range events {
+		if _, ok := groups[e.ConnectionId]; !ok {
+			ids = append(ids, e.ConnectionId)
+		}
+		groups[e.ConnectionId] = append(groups[e.ConnectionId], e)
+	}
+
+	result := make([][]SessionEvent, 0, len(ids))
+	for _, id := range ids {
+		g := groups[id]
+		slices.SortStableFunc(g, func(a, b SessionEvent) int { return a.Time.Compare(b.Time) })
+		result = append(result, g)
+	}
+	return result
+}
+
+func deriveSubscriptions(events []SessionEvent) []*sessionSubscription {
+	var messages []SessionEvent
+	for _, e := range events {
+		if e.Kind == SessionEventMessage {
+			messages = append(messages, e)
+		}
+	}
+
+	// Pair every client message with its response
+	paired := make([]bool, len(messages))
+	responses := map[int]int{}
+	for i, m := range messages {
+		if m.Direction != DirectionClientToServer {
+			continue
+		}
+		if j := findResponse(messages, i, paired); j != -1 {
+			paired[j] = true
+			responses[i] = j
+		}
+	}
+
+	record := func(i int) Record { return Record{Time: messages[i].Time, Message: messages[i].Message} }
+	response := func(i int) *Record {
+		j, ok := responses[i]
+		if !ok {
+			return nil
+		}
+		r := record(j)
+		return &r
+	}
+
+	var subscriptions []*sessionSubscription
+	var active []*sessionSubscription
+	for i, m := range messages {
+		switch {
+		case m.Direction == DirectionClientToServer && isUnsubscription(m.Message):
+			keys := messageKeys(m.Message)
+			j := matchSubscription(active, keys)
+			if j == -1 && len(keys) == 0 {
+				j = len(active) - 1
+			}
+			if j == -1 {
+				continue
+			}
+			s := active[j]
+			active = slices.Delete(active, j, j+1)
+			r := record(i)
+			s.unsubscription = &r
+			s.unsubResponse = response(i)
+		case m.Direction == DirectionClientToServer && isSubscription(m.Message):
+			s := &sessionSubscription{keys: messageKeys(m.Message), request: record(i), response: response(i)}
+			subscriptions = append(subscriptions, s)
+			active = append(active, s)
+		case m.Direction == DirectionServerToClient && !paired[i]:
+			j := matchSubscription(active, messageKeys(m.Message))
+			if j == -1 {
+				continue
+			}
+			active[j].updates = append(active[j].updates, record(i))
+		}
+	}
+	return subscriptions
+}
+
+// matchSubscription returns the index of the subscription that shares the most of keys, the latest of them if several do,
+// or -1 if none shares any
+func matchSubscription(subscriptions []*sessionSubscription, keys []string) int {
+	match, shared := -1, 0
+	for i, s := range subscriptions {
+		n := 0
+		for _, key := range keys {
+			if slices.Contains(s.keys, key) {
+				n++
+			}
+		}
+		if n > 0 && n >= shared {
+			match, shared = i, n
+		}
+	}
+	return match
+}
+
+// keyFields are the fields of a JSON message that name the streams, channels or topics it is about
+var keyFields = []string{"stream", "streams", "channel", "channels", "topic", "topics", "params", "args", "arg", "pair", "subscription"}
+
+// nameFields are the fields of a JSON message that name what it does, such as depth_subscribe or depth_update
+var nameFields = []string{"method", "op", "event", "type", "action"}
+
+// messageKeys returns the keys of a JSON object message, in lower case: the strings in its keyFields,
+// and its nameFields without "subscribe", "unsubscribe" or "update"
+func messageKeys(message Message) []string {
+	if message.Type != MessageText {
+		return nil
+	}
+	var data map[string]any
+	err := json.Unmarshal(message.Data, &data)
+	if err != nil {
+		return nil
+	}
+
+	var keys []string
+	add := func(key string) {
+		key = strings.ToLower(key)
+		if key != "" && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	for _, field := range keyFields {
+		for _, s := range jsonStrings(data[field]) {
+			add(s)
+		}
+	}
+	for _, field := range nameFields {
+		name, ok := data[field].(string)
+		if !ok {
+			continue
+		}
+		name = nameReplacer.Replace(strings.ToLower(name))
+		add(strings.Trim(name, "_-.:@/ "))
+	}
+	return keys
+}
+
+var nameReplacer = strings.NewReplacer("unsubscribe", "", "subscribe", "", "update", "")
+
+// jsonStrings returns the strings in a JSON value, looking into its arrays and objects
+func jsonStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var strs []string
+		for _, e := range v {
+			strs = append(strs, jsonStrings(e)...)
+		}
+		return strs
+	case map[string]any:
+		var strs []string
+		for _, e := range v {
+			strs = append(strs, jsonStrings(e)...)
+		}
+		return strs
+	default:
+		return nil
+	}
+}
+
+func findResponse(messages []SessionEvent, i int, paired []bool) int {
+	id, hasId := jsonId(messages[i].Message)
+	for j := i + 1; j < len(messages); j++ {
+		if messages[j].Direction != DirectionServerToClient || paired[j] {
+			continue
+		}
+		if !hasId {
+			return j
+		}
+		if responseId, ok := jsonId(messages[j].Message); ok && reflect.DeepEqual(id, responseId) {
+			return j
+		}
+	}
+	return -1
+}
+
+// jsonId returns the non-null "id" field of a JSON object message
+func jsonId(message Message) (any, bool) {
+	if message.Type != MessageText {
+		return nil, false
+	}
+
+	var data map[string]any
+	err := json.Unmarshal(message.Data, &data)
+	if err != nil || data["id"] == nil {
+		return nil, false
+	}
+	return data["id"], true
+}
+
+func isSubscription(message Message) bool {
+	data := bytes.ToLower(message.Data)
+	return message.Type == MessageText && bytes.Contains(data, []byte("subscribe")) && !bytes.Contains(data, []byte("unsubscribe"))
+}
+
+func isUnsubscription(message Message) bool {
+	return message.Type == MessageText && bytes.Contains(bytes.ToLower(message.Data), []byte("unsubscribe"))
+}
+
+func newDerivedSubscriptionRule(s *sessionSubscription) *SubscriptionRule {
+	var unsubscriptionMatcher MessageMatcher = noMessageMatcher{}
+	unsubscriptionResponse := NewMessageSequence(s.request.Time, nil)
+	if s.unsubscription != nil {
+		unsubscriptionMatcher = newDerivedMatcher(s.unsubscription.Message)
+		unsubscriptionResponse = newDerivedResponse(*s.unsubscription, s.unsubResponse)
+	}
+
+	return NewSubscriptionRule(
+		newDerivedMatcher(s.request.Message),
+		newDerivedResponse(s.request, s.response),
+		unsubscriptionMatcher,
+		unsubscriptionResponse,
+		NewMessageSequence(s.request.Time, s.updates),
+	)
+}
+
+func newDerivedMatcher(message Message) MessageMatcher {
+	if message.Type == MessageText && json.Valid(message.Data) {
+		return NewJsonMessageMatcher(string(message.Data))
+	}
+	return NewMessagePredicate(message.Type, message.Data)
+}
+
+func newDerivedResponse(request Record, response *Record) MessageSequence {
+	if response == nil {
+		return NewMessageSequence(request.Time, nil)
+	}
+	return NewMessageSequence(request.Time, []Record{*response})
+}
+
+// noMessageMatcher matches no message, for subscriptions that were never unsubscribed
+type noMessageMatcher struct{}
+
+func (noMessageMatcher) MatchMessage(Message) bool {
+	return false
+}
@@ -0,0 +1,128 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeriveSubscriptionRules(t *testing.T) {
+	t0 := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	text := func(s string) Message { return Message{Type: MessageText, Data: []byte(s)} }
+	client := func(id uint64, d time.Duration, s string) SessionEvent {
+		return SessionEvent{Time: t0.Add(d), ConnectionId: id, Kind: SessionEventMessage, Direction: DirectionClientToServer, Message: text(s)}
+	}
+	server := func(id uint64, d time.Duration, s string) SessionEvent {
+		return SessionEvent{Time: t0.Add(d), ConnectionId: id, Kind: SessionEventMessage, Direction: DirectionServerToClient, Message: text(s)}
+	}
+
+	events := []SessionEvent{
+		{Time: t0, ConnectionId: 1, Kind: SessionEventOpen},
+		{Time: t0, ConnectionId: 2, Kind: SessionEventOpen},
+		client(1, 1*time.Millisecond, `{"id":1,"method":"depth_subscribe","params":["ETH_BTC"]}`),
+		client(2, 1*time.Millisecond, `{"id":1,"method":"trades_subscribe","params":["ETH_BTC"]}`),
+		server(1, 2*time.Millisecond, `{"id":null,"method":"depth_update","params":[0]}`),
+		server(1, 3*time.Millisecond, `{"id":1,"result":"success"}`),
+		server(1, 5*time.Millisecond, `{"id":null,"method":"depth_update","params":[1]}`),
+		client(1, 6*time.Millisecond, `{"id":2,"method":"ping"}`),
+		server(1, 7*time.Millisecond, `{"id":2,"result":"pong"}`),
+		server(1, 8*time.Millisecond, `{"id":null,"method":"depth_update","params":[2]}`),
+		client(1, 9*time.Millisecond, `{"id":3,"method":"depth_unsubscribe","params":[]}`),
+		server(1, 10*time.Millisecond, `{"id":3,"result":"success"}`),
+		server(2, 10*time.Millisecond, `{"id":1,"result":"success"}`),
+		server(2, 12*time.Millisecond, `{"id":null,"method":"trades_update"}`),
+		{Time: t0.Add(20 * time.Millisecond), ConnectionId: 2, Kind: SessionEventClose, Direction: DirectionClientToServer, CloseCode: 1000},
+		// A repeated subscription adds no rule
+		client(3, 30*time.Millisecond, `{"id":1,"method":"depth_subscribe","params":["ETH_BTC"]}`),
+	}
+
+	rules := DeriveSubscriptionRules(events)
+	require.Len(t, rules, 2)
+
+	depth := rules[0]
+	assert.True(t, depth.MatchMessage(text(`{"params":["ETH_BTC"],"method":"depth_subscribe","id":1}`)))
+	assert.True(t, depth.MatchMessage(text(`{"id":3,"method":"depth_unsubscribe","params":[]}`)))
+	assert.False(t, depth.MatchMessage(text(`{"id":2,"method":"ping"}`)))
+	assert.Equal(t, NewMessageSequence(t0.Add(1*time.Millisecond), []Record{{Time: t0.Add(3 * time.Millisecond), Message: text(`{"id":1,"result":"success"}`)}}), depth.subscriptionResponse)
+	assert.Equal(t, NewMessageSequence(t0.Add(9*time.Millisecond), []Record{{Time: t0.Add(10 * time.Millisecond), Message: text(`{"id":3,"result":"success"}`)}}), depth.unsubscriptionResponse)
+	assert.Equal(t, NewMessageSequence(t0.Add(1*time.Millisecond), []Record{
+		{Time: t0.Add(2 * time.Millisecond), Message: text(`{"id":null,"method":"depth_update","params":[0]}`)},
+		{Time: t0.Add(5 * time.Millisecond), Message: text(`{"id":null,"method":"depth_update","params":[1]}`)},
+		{Time: t0.Add(8 * time.Millisecond), Message: text(`{"id":null,"method":"depth_update","params":[2]}`)},
+	}), depth.updateResponse)
+
+	trades := rules[1]
+	assert.True(t, trades.MatchMessage(text(`{"id":1,"method":"trades_subscribe","params":["ETH_BTC"]}`)))
+	assert.False(t, trades.unsubscriptionMessageMatcher.MatchMessage(text(`{"id":3,"method":"depth_unsubscribe","params":[]}`)))
+	assert.Equal(t, NewMessageSequence(t0.Add(1*time.Millisecond), []Record{
+		{Time: t0.Add(12 * time.Millisecond), Message: text(`{"id":null,"method":"trades_update"}`)},
+	}), trades.updateResponse)
+}
+
+func TestDeriveSubscriptionRules_WithoutIds(t *testing.T) {
+	t0 := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	events := []SessionEvent{
+		{Time: t0, ConnectionId: 1, Kind: SessionEventMessage, Direction: DirectionClientToServer, Message: Message{Type: MessageText, Data: []byte("subscribe")}},
+		{Time: t0.Add(time.Millisecond), ConnectionId: 1, Kind: SessionEventMessage, Direction: DirectionServerToClient, Message: Message{Type: MessageText, Data: []byte("subscribed")}},
+		{Time: t0.Add(2 * time.Millisecond), ConnectionId: 1, Kind: SessionEventMessage, Direction: DirectionServerToClient, Message: Message{Type: MessageText, Data: []byte("update")}},
+	}
+
+	rules := DeriveSubscriptionRules(events)
+	require.Len(t, rules, 1)
+
+	assert.True(t, rules[0].MatchMessage(Message{Type: MessageText, Data: []byte("subscribe")}))
+	assert.Equal(t, NewMessageSequence(t0, []Record{{Time: events[1].Time, Message: events[1].Message}}), rules[0].subscriptionResponse)
+	// A message that is not JSON has no key to match a subscription by
+	assert.Equal(t, NewMessageSequence(t0, nil), rules[0].updateResponse)
+}
+
+func TestDeriveSubscriptionRules_Interleaved(t *testing.T) {
+	t0 := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	text := func(s string) Message { return Message{Type: MessageText, Data: []byte(s)} }
+	client := func(d time.Duration, s string) SessionEvent {
+		return SessionEvent{Time: t0.Add(d), ConnectionId: 1, Kind: SessionEventMessage, Direction: DirectionClientToServer, Message: text(s)}
+	}
+	server := func(d time.Duration, s string) SessionEvent {
+		return SessionEvent{Time: t0.Add(d), ConnectionId: 1, Kind: SessionEventMessage, Direction: DirectionServerToClient, Message: text(s)}
+	}
+
+	events := []SessionEvent{
+		{Time: t0, ConnectionId: 1, Kind: SessionEventOpen},
+		client(1*time.Millisecond, `{"method":"SUBSCRIBE","params":["btcusdt@depth"],"id":1}`),
+		server(2*time.Millisecond, `{"result":null,"id":1}`),
+		client(3*time.Millisecond, `{"method":"SUBSCRIBE","params":["btcusdt@trade"],"id":2}`),
+		server(4*time.Millisecond, `{"stream":"btcusdt@depth","data":{"u":1}}`),
+		server(5*time.Millisecond, `{"result":null,"id":2}`),
+		server(6*time.Millisecond, `{"stream":"btcusdt@trade","data":{"t":1}}`),
+		server(7*time.Millisecond, `{"stream":"btcusdt@depth","data":{"u":2}}`),
+		// Neither subscription is about these
+		server(8*time.Millisecond, `{"stream":"ethusdt@depth","data":{"u":1}}`),
+		server(8*time.Millisecond, `heartbeat`),
+		// The unsubscription ends the subscription it names rather than the latest one
+		client(9*time.Millisecond, `{"method":"UNSUBSCRIBE","params":["btcusdt@depth"],"id":3}`),
+		server(10*time.Millisecond, `{"result":null,"id":3}`),
+		server(11*time.Millisecond, `{"stream":"btcusdt@trade","data":{"t":2}}`),
+		server(12*time.Millisecond, `{"stream":"btcusdt@depth","data":{"u":3}}`),
+	}
+
+	rules := DeriveSubscriptionRules(events)
+	require.Len(t, rules, 2)
+
+	depth := rules[0]
+	assert.True(t, depth.MatchMessage(text(`{"method":"SUBSCRIBE","params":["btcusdt@depth"],"id":1}`)))
+	assert.Equal(t, NewMessageSequence(t0.Add(9*time.Millisecond), []Record{{Time: t0.Add(10 * time.Millisecond), Message: text(`{"result":null,"id":3}`)}}), depth.unsubscriptionResponse)
+	assert.Equal(t, NewMessageSequence(t0.Add(1*time.Millisecond), []Record{
+		{Time: t0.Add(4 * time.Millisecond), Message: text(`{"stream":"btcusdt@depth","data":{"u":1}}`)},
+		{Time: t0.Add(7 * time.Millisecond), Message: text(`{"stream":"btcusdt@depth","data":{"u":2}}`)},
+	}), depth.updateResponse)
+
+	trade := rules[1]
+	assert.True(t, trade.MatchMessage(text(`{"method":"SUBSCRIBE","params":["btcusdt@trade"],"id":2}`)))
+	assert.Equal(t, NewMessageSequence(t0.Add(3*time.Millisecond), []Record{{Time: t0.Add(5 * time.Millisecond), Message: text(`{"result":null,"id":2}`)}}), trade.subscriptionResponse)
+	assert.Equal(t, NewMessageSequence(t0.Add(3*time.Millisecond), []Record{
+		{Time: t0.Add(6 * time.Millisecond), Message: text(`{"stream":"btcusdt@trade","data":{"t":1}}`)},
+		{Time: t0.Add(11 * time.Millisecond), Message: text(`{"stream":"btcusdt@trade","data":{"t":2}}`)},
+	}), trade.updateResponse)
+}
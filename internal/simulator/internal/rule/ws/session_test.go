@@ -0,0 +1,133 @@
+package ws
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionEvent_MarshalJSON(t *testing.T) {
+	eventTime := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		event        SessionEvent
+		expectedJson string
+	}{
+		{
+			name:         "Open event",
+			event:        SessionEvent{Time: eventTime, ConnectionId: 1, Kind: SessionEventOpen},
+			expectedJson: `{"time":"2000-01-23T12:34:56Z","connection_id":1,"event":"open"}`,
+		},
+		{
+			name: "Text message",
+			event: SessionEvent{
+				Time: eventTime, ConnectionId: 1, Kind: SessionEventMessage, Direction: DirectionClientToServer,
+				Message: Message{Type: MessageText, Data: []byte("ping")},
+			},
+			expectedJson: `{"time":"2000-01-23T12:34:56Z","connection_id":1,"event":"message","direction":"client_to_server","type":"text","data":"ping"}`,
+		},
+		{
+			name: "Binary message",
+			event: SessionEvent{
+				Time: eventTime, ConnectionId: 2, Kind: SessionEventMessage, Direction: DirectionServerToClient,
+				Message: Message{Type: MessageBinary, Data: []byte{0x01, 0x02}},
+			},
+			expectedJson: `{"time":"2000-01-23T12:34:56Z","connection_id":2,"event":"message","direction":"server_to_client","type":"binary","data":"0102"}`,
+		},
+		{
+			name: "Close event",
+			event: SessionEvent{
+				Time: eventTime, ConnectionId: 2, Kind: SessionEventClose, Direction: DirectionServerToClient,
+				CloseCode: 1001, CloseReason: "going away",
+			},
+			expectedJson: `{"time":"2000-01-23T12:34:56Z","connection_id":2,"event":"close","direction":"server_to_client","close_code":1001,"close_reason":"going away"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.event)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.expectedJson, string(data))
+
+			var decoded SessionEvent
+			err = json.Unmarshal(data, &decoded)
+			require.NoError(t, err)
+			assert.Equal(t, tt.event, decoded)
+		})
+	}
+}
+
+func TestSessionRecorder_Record(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session", "session.jsonl")
+	eventTime := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	events := []SessionEvent{
+		{Time: eventTime, ConnectionId: 1, Kind: SessionEventOpen},
+		{
+			Time: eventTime, ConnectionId: 1, Kind: SessionEventMessage, Direction: DirectionClientToServer,
+			Message: Message{Type: MessageText, Data: []byte("line1\nline2")},
+		},
+	}
+
+	recorder := NewSessionRecorder(path)
+	for _, e := range events[:1] {
+		require.NoError(t, recorder.Record(e))
+	}
+	require.NoError(t, recorder.Close())
+
+	// A new recorder appends to the existing file
+	recorder = NewSessionRecorder(path)
+	for _, e := range events[1:] {
+		require.NoError(t, recorder.Record(e))
+	}
+	require.NoError(t, recorder.Close())
+
+	recorded, err := ReadSessionFromFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, events, recorded)
+}
+
+func TestSessionRecorder_Record_Compressed(t *testing.T) {
+	for _, filename := range []string{"session.jsonl.gz", "session.jsonl.zst"} {
+		t.Run(filename, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), filename)
+			eventTime := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+			events := []SessionEvent{
+				{Time: eventTime, ConnectionId: 1, Kind: SessionEventOpen},
+				{
+					Time: eventTime, ConnectionId: 1, Kind: SessionEventMessage, Direction: DirectionServerToClient,
+					Message: Message{Type: MessageText, Data: []byte("data")},
+				},
+			}
+
+			recorder := NewSessionRecorder(path)
+			for _, e := range events {
+				require.NoError(t, recorder.Record(e))
+			}
+
+			// A session still being recorded can be read
+			recorded, err := ReadSessionFromFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, events, recorded)
+
+			require.NoError(t, recorder.Close())
+			recorded, err = ReadSessionFromFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, events, recorded)
+		})
+	}
+}
+
+func TestReadSession_Error(t *testing.T) {
+	_, err := ReadSession(strings.NewReader(`{"event":"message","type":"unknown"}`))
+	assert.Error(t, err)
+
+	_, err = ReadSessionFromFile("/non/existent/path.jsonl")
+	assert.Error(t, err)
+}
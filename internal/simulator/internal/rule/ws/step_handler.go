@@ -0,0 +1,36 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+)
+
+// Ensure StepHandler implements MessageHandler
+var _ MessageHandler = (*StepHandler)(nil)
+
+// StepHandler steps a lockstep timeline whenever the client asks for it,
+// so that the replays running on the timeline write their next messages
+type StepHandler struct {
+	timeline *clock.Fake
+	// start is the time of the timeline when the handler was made
+	start time.Time
+}
+
+func NewStepHandler(timeline *clock.Fake) StepHandler {
+	return StepHandler{
+		timeline: timeline,
+		start:    timeline.Now(),
+	}
+}
+
+// Reset moves the timeline back to the time it was at when r was made
+func (r StepHandler) Reset() {
+	r.timeline.Set(r.start)
+}
+
+func (r StepHandler) Handle(ctx context.Context, _ Message, _ Connection, _ Connection) error {
+	_, err := r.timeline.Step(ctx)
+	return err
+}
@@ -0,0 +1,102 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStepHandler_Handle(t *testing.T) {
+	// Trades every 30ms and depth updates every 20ms
+	first := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{}
+	for _, stream := range []struct {
+		name   string
+		period time.Duration
+	}{{"trade", 30 * time.Millisecond}, {"depth", 20 * time.Millisecond}} {
+		for i := range 3 {
+			t := first.Add(time.Duration(i) * stream.period)
+			content := fmt.Sprintf("type: text\ndata: %s%d", stream.name, i)
+			fsys[stream.name+"/"+t.Format("2006-01-02T15:04:05.000000Z07:00")+".yaml"] = &fstest.MapFile{Data: []byte(content)}
+		}
+	}
+
+	timeline := clock.NewFake(first)
+	ctx := context.Background()
+
+	var lock sync.Mutex
+	var written []string
+	mockConn := NewMockConnection(t)
+	mockConn.On("Write", ctx, mock.AnythingOfType("Message")).Return(nil).Run(func(args mock.Arguments) {
+		lock.Lock()
+		defer lock.Unlock()
+		written = append(written, string(args.Get(1).(Message).Data))
+	})
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"trade", "depth"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := NewMessageFromFiles(name).WithFS(fsys).WithClock(timeline).Handle(ctx, Message{}, mockConn, nil)
+			assert.NoError(t, err)
+		}()
+	}
+	// Both replays write their first message without waiting
+	assert.NoError(t, timeline.WaitForSleepers(ctx, 2))
+
+	h := NewStepHandler(timeline)
+	for range 4 {
+		// A slow strategy sees the same sequence as a fast one
+		time.Sleep(time.Millisecond)
+		err := h.Handle(ctx, Message{Type: MessageText, Data: []byte(`{"method":"step"}`)}, mockConn, nil)
+		assert.NoError(t, err)
+	}
+	wg.Wait()
+
+	assert.ElementsMatch(t, []string{"trade0", "depth0"}, written[:2])
+	assert.Equal(t, []string{"depth1", "trade1", "depth2", "trade2"}, written[2:])
+	assert.Equal(t, first.Add(60*time.Millisecond), timeline.Now())
+}
+
+func TestStepHandler_Handle_AfterSubscription(t *testing.T) {
+	first := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"depth/2023-07-22T10:00:00.000000Z.yaml": {Data: []byte("type: text\ndata: depth0")},
+		"depth/2023-07-22T10:00:01.000000Z.yaml": {Data: []byte("type: text\ndata: depth1")},
+	}
+	timeline := clock.NewFake(first)
+	ctx := context.Background()
+
+	written := make(chan string, 2)
+	mockConn := NewMockConnection(t)
+	mockConn.On("Write", mock.Anything, mock.AnythingOfType("Message")).Return(nil).Run(func(args mock.Arguments) {
+		written <- string(args.Get(1).(Message).Data)
+	})
+
+	rule := NewSubscriptionRule(
+		NewMessagePredicate(MessageText, []byte("subscribe")),
+		NewMessageSequence(first, nil),
+		NewMessagePredicate(MessageText, []byte("unsubscribe")),
+		NewMessageSequence(first, nil),
+		NewMessageFromFiles("depth").WithFS(fsys).WithClock(timeline),
+	)
+	err := rule.Handle(ctx, Message{Type: MessageText, Data: []byte("subscribe")}, mockConn, nil)
+	assert.NoError(t, err)
+
+	// The step right after subscribing waits for the replay instead of finding nothing to step
+	stepped, err := timeline.Step(ctx)
+	assert.NoError(t, err)
+	assert.True(t, stepped)
+	assert.Equal(t, "depth0", <-written)
+	assert.Equal(t, "depth1", <-written)
+	assert.Equal(t, first.Add(time.Second), timeline.Now())
+}
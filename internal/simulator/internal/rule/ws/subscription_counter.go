@@ -0,0 +1,41 @@
+package ws
+
+// SubscriptionCounter is a rule that counts the subscriptions of every connection, so that they can be capped
+type SubscriptionCounter interface {
+	Rule
+	// SubscriptionCount returns how many subscriptions connClient has
+	SubscriptionCount(connClient Connection) int
+	// NewSubscriptions returns how many subscriptions handling message would add to those of connClient
+	NewSubscriptions(message Message, connClient Connection) int
+}
+
+// SubscriptionCounterOf returns rule as a SubscriptionCounter, looking through the rules that wrap another, such as OrderedRuleImpl
+func SubscriptionCounterOf(rule Rule) (SubscriptionCounter, bool) {
+	return ruleAs[SubscriptionCounter](rule)
+}
+
+// TopicSubscribers is a rule that tells which connections are subscribed to a topic, so that messages can be pushed to them
+type TopicSubscribers interface {
+	Rule
+	// Subscribers returns the connections subscribed to topic, as they were handled
+	Subscribers(topic string) []Connection
+}
+
+// TopicSubscribersOf returns rule as TopicSubscribers, looking through the rules that wrap another, such as OrderedRuleImpl
+func TopicSubscribersOf(rule Rule) (TopicSubscribers, bool) {
+	return ruleAs[TopicSubscribers](rule)
+}
+
+func ruleAs[T Rule](rule Rule) (T, bool) {
+	for {
+		if r, ok := rule.(T); ok {
+			return r, true
+		}
+		w, ok := rule.(interface{ Unwrap() Rule })
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		rule = w.Unwrap()
+	}
+}
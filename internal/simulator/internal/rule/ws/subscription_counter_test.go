@@ -0,0 +1,79 @@
+package ws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopicSubscriptionRule_SubscriptionCount(t *testing.T) {
+	rule := newTestTopicSubscriptionRule(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn, _ := recordingConnection(t)
+
+	assert.Equal(t, 0, rule.SubscriptionCount(conn))
+	assert.Equal(t, 2, rule.NewSubscriptions(request("SUBSCRIBE", "a", "b", "a"), conn))
+
+	require.NoError(t, rule.Handle(ctx, request("SUBSCRIBE", "a", "b"), conn, nil))
+	assert.Equal(t, 2, rule.SubscriptionCount(conn))
+	assert.Equal(t, 1, rule.NewSubscriptions(request("SUBSCRIBE", "b", "c"), conn))
+	assert.Equal(t, 0, rule.NewSubscriptions(request("UNSUBSCRIBE", "a"), conn))
+	assert.Equal(t, 0, rule.NewSubscriptions(request("LIST_SUBSCRIPTIONS"), conn))
+}
+
+func TestSubscriptionRule_SubscriptionCount(t *testing.T) {
+	feeds, _, _ := testFeeds(t, "updates")
+	response := NewMessageFromString(MessageText, `{"result":null,"id":1}`, 0)
+	rule := NewSubscriptionRule(methodMatcher(t, "SUBSCRIBE"), response, methodMatcher(t, "UNSUBSCRIBE"), response, feeds["updates"])
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn, _ := recordingConnection(t)
+
+	assert.Equal(t, 0, rule.SubscriptionCount(conn))
+	assert.Equal(t, 1, rule.NewSubscriptions(request("SUBSCRIBE"), conn))
+
+	require.NoError(t, rule.Handle(ctx, request("SUBSCRIBE"), conn, nil))
+	assert.Equal(t, 1, rule.SubscriptionCount(conn))
+	assert.Equal(t, 0, rule.NewSubscriptions(request("SUBSCRIBE"), conn))
+	assert.Equal(t, 0, rule.NewSubscriptions(request("UNSUBSCRIBE"), conn))
+}
+
+func TestSubscriptionCounterOf(t *testing.T) {
+	rule := newTestTopicSubscriptionRule(t, nil)
+
+	counter, ok := SubscriptionCounterOf(NewOrderedRule(rule, OrderingBlocking))
+	assert.True(t, ok)
+	assert.IsType(t, TopicSubscriptionRule{}, counter)
+
+	_, ok = SubscriptionCounterOf(NewRule(methodMatcher(t, "PING"), NewMessageFromString(MessageText, "pong", 0)))
+	assert.False(t, ok)
+}
+
+func TestTopicSubscriptionRule_Subscribers(t *testing.T) {
+	rule := newTestTopicSubscriptionRule(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn1, _ := recordingConnection(t)
+	conn2, _ := recordingConnection(t)
+
+	require.NoError(t, rule.Handle(ctx, request("SUBSCRIBE", "a", "b"), conn1, nil))
+	require.NoError(t, rule.Handle(ctx, request("SUBSCRIBE", "b"), conn2, nil))
+
+	assert.Equal(t, []Connection{conn1}, rule.Subscribers("a"))
+	assert.ElementsMatch(t, []Connection{conn1, conn2}, rule.Subscribers("b"))
+	assert.Empty(t, rule.Subscribers("c"))
+}
+
+func TestTopicSubscribersOf(t *testing.T) {
+	rule := newTestTopicSubscriptionRule(t, nil)
+
+	subscribers, ok := TopicSubscribersOf(NewOrderedRule(rule, OrderingBlocking))
+	assert.True(t, ok)
+	assert.IsType(t, TopicSubscriptionRule{}, subscribers)
+
+	_, ok = TopicSubscribersOf(NewRule(methodMatcher(t, "PING"), NewMessageFromString(MessageText, "pong", 0)))
+	assert.False(t, ok)
+}
@@ -3,11 +3,19 @@ package ws
 import (
 	"context"
 	"sync"
+
+	"alphanonce.com/exchangesimulator/internal/log"
 )
 
 // Ensure SubscriptionRule implements Rule
 var _ Rule = (*SubscriptionRule)(nil)
 
+// Ensure SubscriptionRule implements SubscriptionCounter
+var _ SubscriptionCounter = (*SubscriptionRule)(nil)
+
+// SubscriptionRule starts streaming updates to a connection when it subscribes, and stops when it unsubscribes or goes away.
+// Every connection subscribes on its own, so a rule may be shared by any number of connections.
+// Connections are told apart by the connClient they are handled with, which must therefore be comparable.
 type SubscriptionRule struct {
 	subscriptionMessageMatcher   MessageMatcher
 	subscriptionResponse         MessageHandler
@@ -15,8 +23,8 @@ type SubscriptionRule struct {
 	unsubscriptionResponse       MessageHandler
 	updateResponse               MessageHandler
 	updateLock                   sync.Mutex
-	updateEnabled                bool
-	updateCancelFunc             func()
+	// updateCancelFuncs stops the updates of each subscribed connection
+	updateCancelFuncs map[Connection]func()
 }
 
 func NewSubscriptionRule(
@@ -33,8 +41,7 @@ func NewSubscriptionRule(
 		unsubscriptionResponse:       unsubscriptionResponse,
 		updateResponse:               updateResponse,
 		updateLock:                   sync.Mutex{},
-		updateEnabled:                false,
-		updateCancelFunc:             nil,
+		updateCancelFuncs:            map[Connection]func(){},
 	}
 }
 
@@ -50,14 +57,56 @@ func (r *SubscriptionRule) Handle(ctx context.Context, message Message, connClie
 	return r.handleUnsubscription(ctx, message, connClient, connServer)
 }
 
-func (r *SubscriptionRule) handleSubscription(ctx context.Context, message Message, connClient Connection, connServer Connection) error {
+// SubscriptionCount returns 1 if connClient is subscribed, and 0 otherwise
+func (r *SubscriptionRule) SubscriptionCount(connClient Connection) int {
+	if r.Subscribed(connClient) {
+		return 1
+	}
+	return 0
+}
+
+// NewSubscriptions returns 1 if message subscribes connClient, which is not subscribed yet, and 0 otherwise
+func (r *SubscriptionRule) NewSubscriptions(message Message, connClient Connection) int {
+	if r.subscriptionMessageMatcher.MatchMessage(message) && !r.Subscribed(connClient) {
+		return 1
+	}
+	return 0
+}
+
+// Subscribed tells whether connClient is subscribed
+func (r *SubscriptionRule) Subscribed(connClient Connection) bool {
+	r.updateLock.Lock()
+	defer r.updateLock.Unlock()
+
+	_, ok := r.updateCancelFuncs[connClient]
+	return ok
+}
+
+// Reset stops the updates of every subscribed connection
+func (r *SubscriptionRule) Reset() {
 	r.updateLock.Lock()
-	if !r.updateEnabled {
-		r.updateEnabled = true
-		ctx, cancel := context.WithCancel(ctx)
-		r.updateCancelFunc = cancel
+	cancels := r.updateCancelFuncs
+	r.updateCancelFuncs = map[Connection]func(){}
+	r.updateLock.Unlock()
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+	reset(r.subscriptionResponse)
+	reset(r.unsubscriptionResponse)
+	reset(r.updateResponse)
+}
 
-		go r.updateResponse.Handle(ctx, message, connClient, connServer)
+func (r *SubscriptionRule) handleSubscription(ctx context.Context, message Message, connClient Connection, connServer Connection) error {
+	r.updateLock.Lock()
+	if _, ok := r.updateCancelFuncs[connClient]; !ok {
+		cancel := startUpdates(ctx, r.updateResponse, message, connClient, connServer)
+		// ctx is done once the connection is closed, which unsubscribes it
+		stop := context.AfterFunc(ctx, func() { r.stopUpdates(connClient) })
+		r.updateCancelFuncs[connClient] = func() {
+			stop()
+			cancel()
+		}
 	}
 	r.updateLock.Unlock()
 
@@ -65,12 +114,47 @@ func (r *SubscriptionRule) handleSubscription(ctx context.Context, message Messa
 }
 
 func (r *SubscriptionRule) handleUnsubscription(ctx context.Context, message Message, connClient Connection, connServer Connection) error {
+	r.stopUpdates(connClient)
+
+	return r.unsubscriptionResponse.Handle(ctx, message, connClient, connServer)
+}
+
+// stopUpdates stops the updates of connClient if it is subscribed
+func (r *SubscriptionRule) stopUpdates(connClient Connection) {
 	r.updateLock.Lock()
-	if r.updateEnabled {
-		r.updateEnabled = false
-		r.updateCancelFunc()
-	}
+	cancel, ok := r.updateCancelFuncs[connClient]
+	delete(r.updateCancelFuncs, connClient)
 	r.updateLock.Unlock()
 
-	return r.unsubscriptionResponse.Handle(ctx, message, connClient, connServer)
+	if ok {
+		cancel()
+	}
+}
+
+// startUpdates runs handler in a goroutine until the returned function is called or ctx is done,
+// and logs the error it fails with, e.g. as the client cannot keep up with the updates.
+// The handler is attached to its clock before the goroutine starts; see clockAttacher.
+func startUpdates(ctx context.Context, handler MessageHandler, message Message, connClient Connection, connServer Connection) func() {
+	cancel, _ := runUpdates(ctx, handler, message, connClient, connServer)
+	return cancel
+}
+
+// runUpdates is startUpdates that also returns a channel closed once handler returns
+func runUpdates(ctx context.Context, handler MessageHandler, message Message, connClient Connection, connServer Connection) (func(), <-chan struct{}) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	detach := func() {}
+	if a, ok := handler.(clockAttacher); ok {
+		handler, detach = a.attachClock(ctx)
+	}
+	go func() {
+		defer close(done)
+		defer detach()
+		err := handler.Handle(ctx, message, connClient, connServer)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("Error writing updates", log.Any("error", err))
+		}
+	}()
+	return cancel, done
 }
@@ -2,6 +2,7 @@ package ws
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -84,27 +85,31 @@ func TestSubscriptionRule_Handle_Unsubscription(t *testing.T) {
 	updateHandler := NewMockMessageHandler(t)
 	rule := NewSubscriptionRule(subMatcher, subHandler, unsubMatcher, unsubHandler, updateHandler)
 
-	// First, simulate a subscription
-	isCancelFuncCalled := false
-	rule.updateEnabled = true
-	rule.updateCancelFunc = func() {
-		isCancelFuncCalled = true
-	}
-
 	ctx := context.Background()
-	msg := Message{}
+	subMsg := Message{Type: MessageText, Data: []byte("subscribe")}
+	unsubMsg := Message{Type: MessageText, Data: []byte("unsubscribe")}
 	connClient := NewMockConnection(t)
 	connServer := NewMockConnection(t)
 
 	// Set up expectations
-	subMatcher.On("MatchMessage", msg).Return(false)
-	unsubHandler.On("Handle", ctx, msg, connClient, connServer).Return(nil)
-
-	err := rule.Handle(ctx, msg, connClient, connServer)
+	subMatcher.On("MatchMessage", subMsg).Return(true)
+	subMatcher.On("MatchMessage", unsubMsg).Return(false)
+	subHandler.On("Handle", ctx, subMsg, connClient, connServer).Return(nil)
+	unsubHandler.On("Handle", ctx, unsubMsg, connClient, connServer).Return(nil)
+	updateCancelled := make(chan struct{})
+	updateHandler.On("Handle", mock.Anything, subMsg, connClient, connServer).Return(nil).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+		close(updateCancelled)
+	})
+
+	err := rule.Handle(ctx, subMsg, connClient, connServer)
+	assert.NoError(t, err)
+	assert.True(t, rule.Subscribed(connClient))
 
+	err = rule.Handle(ctx, unsubMsg, connClient, connServer)
 	assert.NoError(t, err)
-	assert.False(t, rule.updateEnabled)
-	assert.True(t, isCancelFuncCalled)
+	assert.False(t, rule.Subscribed(connClient))
+	<-updateCancelled
 }
 
 func TestSubscriptionRule_Handle_MultipleSubscriptions(t *testing.T) {
@@ -135,3 +140,98 @@ func TestSubscriptionRule_Handle_MultipleSubscriptions(t *testing.T) {
 
 	time.Sleep(10 * time.Millisecond) // Wait a bit to ensure the goroutine starts
 }
+
+func TestSubscriptionRule_Handle_ManyConnections(t *testing.T) {
+	subMsg := Message{Type: MessageText, Data: []byte("subscribe")}
+	unsubMsg := Message{Type: MessageText, Data: []byte("unsubscribe")}
+	updateMsg := Message{Type: MessageText, Data: []byte("update")}
+	subMatcher := NewMockMessageMatcher(t)
+	subMatcher.On("MatchMessage", subMsg).Return(true)
+	subMatcher.On("MatchMessage", unsubMsg).Return(false)
+	response := NewMessageFromString(MessageText, "OK", 0)
+
+	// Every subscribed connection is written an update, and then waits until its updates are stopped
+	var updating sync.WaitGroup
+	updateHandler := NewMockMessageHandler(t)
+	updateHandler.On("Handle", mock.Anything, subMsg, mock.Anything, nil).Return(nil).Run(func(args mock.Arguments) {
+		defer updating.Done()
+		ctx, conn := args.Get(0).(context.Context), args.Get(2).(Connection)
+		conn.Write(ctx, updateMsg)
+		<-ctx.Done()
+	})
+	rule := NewSubscriptionRule(subMatcher, response, nil, response, updateHandler)
+
+	const count = 50
+	conns := make([]*MockConnection, count)
+	updated := make([]chan struct{}, count)
+	for i := range conns {
+		conns[i] = NewMockConnection(t)
+		updated[i] = make(chan struct{})
+		conns[i].On("Write", mock.Anything, Message{Type: MessageText, Data: []byte("OK")}).Return(nil)
+		conns[i].On("Write", mock.Anything, updateMsg).Return(nil).Run(func(mock.Arguments) { close(updated[i]) }).Once()
+	}
+
+	cancels := make([]func(), count)
+	var handling sync.WaitGroup
+	for i, conn := range conns {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels[i] = cancel
+		handling.Add(1)
+		updating.Add(1)
+		go func() {
+			defer handling.Done()
+			assert.NoError(t, rule.Handle(ctx, subMsg, conn, nil))
+		}()
+	}
+	handling.Wait()
+	for i, conn := range conns {
+		<-updated[i]
+		assert.True(t, rule.Subscribed(conn))
+	}
+
+	// Half of the connections unsubscribe and the other half go away, and each one only stops its own updates
+	for i, conn := range conns[:count/2] {
+		assert.NoError(t, rule.Handle(context.Background(), unsubMsg, conn, nil))
+		assert.False(t, rule.Subscribed(conn))
+		assert.True(t, rule.Subscribed(conns[count-1-i]))
+	}
+	for _, cancel := range cancels[count/2:] {
+		cancel()
+	}
+	updating.Wait()
+	assert.Eventually(t, func() bool {
+		for _, conn := range conns {
+			if rule.Subscribed(conn) {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond)
+}
+
+func TestSubscriptionRule_Reset(t *testing.T) {
+	subMatcher := NewMockMessageMatcher(t)
+	subHandler := NewMockMessageHandler(t)
+	updateHandler := NewMockMessageHandler(t)
+	rule := NewSubscriptionRule(subMatcher, subHandler, NewMockMessageMatcher(t), NewMockMessageHandler(t), updateHandler)
+
+	ctx := context.Background()
+	msg := Message{}
+	connClient := NewMockConnection(t)
+
+	subMatcher.On("MatchMessage", msg).Return(true)
+	subHandler.On("Handle", ctx, msg, connClient, nil).Return(nil)
+	updateCancelled := make(chan struct{})
+	updateHandler.On("Handle", mock.Anything, msg, connClient, nil).Return(nil).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+		close(updateCancelled)
+	})
+
+	err := rule.Handle(ctx, msg, connClient, nil)
+	assert.NoError(t, err)
+	assert.True(t, rule.Subscribed(connClient))
+
+	rule.Reset()
+	assert.False(t, rule.Subscribed(connClient))
+	<-updateCancelled
+}
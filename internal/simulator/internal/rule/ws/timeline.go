@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+)
+
+// Timeline is the market time of a replay session, shared by the streams replayed on it
+// so that they stay in sync however late each of them is subscribed to.
+// It starts when the first stream on it is replayed, at origin or at the first record of that stream if origin is zero,
+// and runs speed times as fast as the clock from then on.
+type Timeline struct {
+	// initial is the origin t was made with
+	initial time.Time
+	speed   float64
+
+	lock    sync.Mutex
+	origin  time.Time
+	start   time.Time
+	started bool
+}
+
+// NewTimeline returns a timeline that starts at origin and runs speed times as fast as the clock.
+// A zero speed stands for 1.
+func NewTimeline(origin time.Time, speed float64) *Timeline {
+	return &Timeline{
+		initial: origin,
+		speed:   ReplayOptions{Speed: speed}.speed(),
+		origin:  origin,
+	}
+}
+
+// Reset stops t, so that it starts again when a stream on it is next replayed
+func (t *Timeline) Reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.origin = t.initial
+	t.start = time.Time{}
+	t.started = false
+}
+
+// begin starts t on c unless it has started already, taking the origin from first if it is zero,
+// and returns the time of c it started at and its origin
+func (t *Timeline) begin(c clock.Clock, first func() (time.Time, error)) (time.Time, time.Time, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.started {
+		if t.origin.IsZero() {
+			origin, err := first()
+			if err != nil {
+				return time.Time{}, time.Time{}, err
+			}
+			t.origin = origin
+		}
+		t.start = c.Now()
+		t.started = true
+	}
+	return t.start, t.origin, nil
+}
+
+// at returns the market time at now, the time of the clock t started on.
+// At an infinite speed, or one so high the market time is out of range, the market time is as far ahead as a duration goes
+// as soon as the clock has moved on from the start.
+func (t *Timeline) at(now time.Time) time.Time {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	elapsed := now.Sub(t.start)
+	if elapsed <= 0 {
+		return t.origin
+	}
+	scaled := float64(elapsed) * t.speed
+	if scaled >= math.MaxInt64 {
+		return t.origin.Add(math.MaxInt64)
+	}
+	return t.origin.Add(time.Duration(scaled))
+}
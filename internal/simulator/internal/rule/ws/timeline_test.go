@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeline_at(t *testing.T) {
+	origin := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	start := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		speed    float64
+		elapsed  time.Duration
+		expected time.Time
+	}{
+		{"Start", 2, 0, origin},
+		{"Speed", 2, time.Second, origin.Add(2 * time.Second)},
+		{"Infinite speed at the start", math.Inf(1), 0, origin},
+		{"Infinite speed", math.Inf(1), time.Nanosecond, origin.Add(math.MaxInt64)},
+		{"Out of range", 1e300, time.Second, origin.Add(math.MaxInt64)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeline := NewTimeline(origin, tt.speed)
+			_, _, err := timeline.begin(clock.NewFake(start), nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, timeline.at(start.Add(tt.elapsed)))
+		})
+	}
+}
+
+func TestTimeline_Reset(t *testing.T) {
+	start := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	first := time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC)
+	c := clock.NewFake(start)
+	timeline := NewTimeline(time.Time{}, 1)
+
+	_, origin, err := timeline.begin(c, func() (time.Time, error) { return first, nil })
+	require.NoError(t, err)
+	assert.Equal(t, first, origin)
+
+	timeline.Reset()
+	c.Advance(time.Minute)
+	began, origin, err := timeline.begin(c, func() (time.Time, error) { return first.Add(time.Hour), nil })
+	require.NoError(t, err)
+	assert.Equal(t, start.Add(time.Minute), began)
+	assert.Equal(t, first.Add(time.Hour), origin)
+}
@@ -0,0 +1,87 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TopicExtractor tells which topics, e.g. btcusdt@depth, a subscribe or unsubscribe message is about
+type TopicExtractor interface {
+	ExtractTopics(Message) ([]string, error)
+}
+
+// Ensure JsonTopicExtractor implements TopicExtractor
+var _ TopicExtractor = (*JsonTopicExtractor)(nil)
+
+// JsonTopicExtractor takes the topics of a JSON message from the value at a path of object keys separated by dots,
+// which is either a string or an array of strings.
+// For example, the path "params" takes btcusdt@depth and btcusdt@trade from
+// {"method":"SUBSCRIBE","params":["btcusdt@depth","btcusdt@trade"],"id":1}.
+type JsonTopicExtractor struct {
+	path []string
+}
+
+func NewJsonTopicExtractor(path string) JsonTopicExtractor {
+	return JsonTopicExtractor{path: strings.Split(path, ".")}
+}
+
+func (e JsonTopicExtractor) ExtractTopics(message Message) ([]string, error) {
+	var value any
+	err := json.Unmarshal(message.Data, &value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse topics: %w", err)
+	}
+
+	for _, key := range e.path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("no topics at %s", strings.Join(e.path, "."))
+		}
+		value, ok = object[key]
+		if !ok {
+			return nil, fmt.Errorf("no topics at %s", strings.Join(e.path, "."))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		topics := make([]string, 0, len(v))
+		for _, t := range v {
+			s, ok := t.(string)
+			if !ok {
+				return nil, fmt.Errorf("topic %v at %s is not a string", t, strings.Join(e.path, "."))
+			}
+			topics = append(topics, s)
+		}
+		return topics, nil
+	default:
+		return nil, fmt.Errorf("topics at %s are neither a string nor an array", strings.Join(e.path, "."))
+	}
+}
+
+// JsonTopicList responds to a request for the current subscriptions with them under "result", and the "id" of the request if it has one,
+// e.g. {"result":["btcusdt@depth"],"id":3}
+func JsonTopicList(request Message, topics []string) (Message, error) {
+	var r struct {
+		Id json.RawMessage `json:"id"`
+	}
+	// A request without an id, or that is not a JSON object, is answered without an id
+	_ = json.Unmarshal(request.Data, &r)
+
+	response := struct {
+		Result []string        `json:"result"`
+		Id     json.RawMessage `json:"id,omitempty"`
+	}{Result: topics, Id: r.Id}
+	if response.Result == nil {
+		response.Result = []string{}
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Type: MessageText, Data: data}, nil
+}
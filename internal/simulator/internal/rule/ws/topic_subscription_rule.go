@@ -0,0 +1,306 @@
+package ws
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Ensure TopicSubscriptionRule implements Rule
+var _ Rule = (*TopicSubscriptionRule)(nil)
+
+// Ensure TopicSubscriptionRule implements SubscriptionCounter
+var _ SubscriptionCounter = (*TopicSubscriptionRule)(nil)
+
+// Ensure TopicSubscriptionRule implements TopicSubscribers
+var _ TopicSubscribers = (*TopicSubscriptionRule)(nil)
+
+// DuplicateSubscription tells what subscribing to a topic a connection is subscribed to already does
+type DuplicateSubscription int
+
+const (
+	// DuplicateSubscriptionIgnore keeps the feed of the topic running as it is
+	DuplicateSubscriptionIgnore DuplicateSubscription = iota
+	// DuplicateSubscriptionRestart restarts the feed of the topic from the beginning
+	DuplicateSubscriptionRestart
+)
+
+// TopicLister makes the response to a request for the topics a connection is subscribed to
+type TopicLister func(request Message, topics []string) (Message, error)
+
+// TopicSubscriptionRule lets a connection subscribe to any number of topics, e.g. the depth, trades and klines of several symbols,
+// a few at a time. The topics of a subscribe or unsubscribe message are told by a TopicExtractor,
+// and the feed of each topic is streamed to the connection until it unsubscribes from the topic or goes away.
+// A topic without a feed can be subscribed to, but nothing is streamed for it.
+//
+// Every connection subscribes on its own, so a rule may be shared by any number of connections.
+// Connections are told apart by the connClient they are handled with, which must therefore be comparable.
+type TopicSubscriptionRule struct {
+	subscriptionMessageMatcher   MessageMatcher
+	subscriptionResponse         MessageHandler
+	unsubscriptionMessageMatcher MessageMatcher
+	unsubscriptionResponse       MessageHandler
+	topicExtractor               TopicExtractor
+	feeds                        map[string]MessageHandler
+
+	unsubscribeAllMessageMatcher MessageMatcher
+	unsubscribeAllResponse       MessageHandler
+	listMessageMatcher           MessageMatcher
+	topicLister                  TopicLister
+	duplicateSubscription        DuplicateSubscription
+
+	subscriptions *topicSubscriptions
+}
+
+func NewTopicSubscriptionRule(
+	subscriptionMessageMatcher MessageMatcher,
+	subscriptionResponse MessageHandler,
+	unsubscriptionMessageMatcher MessageMatcher,
+	unsubscriptionResponse MessageHandler,
+	topicExtractor TopicExtractor,
+	feeds map[string]MessageHandler,
+) TopicSubscriptionRule {
+	return TopicSubscriptionRule{
+		subscriptionMessageMatcher:   subscriptionMessageMatcher,
+		subscriptionResponse:         subscriptionResponse,
+		unsubscriptionMessageMatcher: unsubscriptionMessageMatcher,
+		unsubscriptionResponse:       unsubscriptionResponse,
+		topicExtractor:               topicExtractor,
+		feeds:                        feeds,
+		duplicateSubscription:        DuplicateSubscriptionIgnore,
+		subscriptions:                &topicSubscriptions{connections: map[Connection]*connectionTopics{}},
+	}
+}
+
+// WithUnsubscribeAll returns a copy of r that unsubscribes a connection from every topic on a message matching matcher,
+// and responds with response
+func (r TopicSubscriptionRule) WithUnsubscribeAll(matcher MessageMatcher, response MessageHandler) TopicSubscriptionRule {
+	r.unsubscribeAllMessageMatcher = matcher
+	r.unsubscribeAllResponse = response
+	return r
+}
+
+// WithListing returns a copy of r that responds to a message matching matcher with the topics the connection is subscribed to,
+// in the order they were subscribed to, as lister makes it, e.g. JsonTopicList
+func (r TopicSubscriptionRule) WithListing(matcher MessageMatcher, lister TopicLister) TopicSubscriptionRule {
+	r.listMessageMatcher = matcher
+	r.topicLister = lister
+	return r
+}
+
+// WithDuplicateSubscription returns a copy of r that handles subscribing to a topic twice as d tells
+func (r TopicSubscriptionRule) WithDuplicateSubscription(d DuplicateSubscription) TopicSubscriptionRule {
+	r.duplicateSubscription = d
+	return r
+}
+
+func (r TopicSubscriptionRule) MatchMessage(message Message) bool {
+	return r.subscriptionMessageMatcher.MatchMessage(message) ||
+		r.unsubscriptionMessageMatcher.MatchMessage(message) ||
+		matches(r.unsubscribeAllMessageMatcher, message) ||
+		matches(r.listMessageMatcher, message)
+}
+
+func (r TopicSubscriptionRule) Handle(ctx context.Context, message Message, connClient Connection, connServer Connection) error {
+	switch {
+	case matches(r.listMessageMatcher, message):
+		response, err := r.topicLister(message, r.Subscriptions(connClient))
+		if err != nil {
+			return err
+		}
+		return connClient.Write(ctx, response)
+	case matches(r.unsubscribeAllMessageMatcher, message):
+		r.subscriptions.unsubscribe(connClient, nil)
+		return r.unsubscribeAllResponse.Handle(ctx, message, connClient, connServer)
+	case r.subscriptionMessageMatcher.MatchMessage(message):
+		return r.handleSubscription(ctx, message, connClient, connServer)
+	default:
+		return r.handleUnsubscription(ctx, message, connClient, connServer)
+	}
+}
+
+// Reset unsubscribes every connection from every topic
+func (r TopicSubscriptionRule) Reset() {
+	r.subscriptions.reset()
+	reset(r.subscriptionResponse)
+	reset(r.unsubscriptionResponse)
+	reset(r.unsubscribeAllResponse)
+	for _, feed := range r.feeds {
+		reset(feed)
+	}
+}
+
+// Subscriptions returns the topics connClient is subscribed to, in the order they were subscribed to
+func (r TopicSubscriptionRule) Subscriptions(connClient Connection) []string {
+	return r.subscriptions.topics(connClient)
+}
+
+// SubscriptionCount returns how many topics connClient is subscribed to
+func (r TopicSubscriptionRule) SubscriptionCount(connClient Connection) int {
+	return len(r.Subscriptions(connClient))
+}
+
+// Subscribers returns the connections subscribed to topic
+func (r TopicSubscriptionRule) Subscribers(topic string) []Connection {
+	return r.subscriptions.subscribers(topic)
+}
+
+// NewSubscriptions returns how many topics that connClient is not subscribed to yet message subscribes it to
+func (r TopicSubscriptionRule) NewSubscriptions(message Message, connClient Connection) int {
+	if !r.subscriptionMessageMatcher.MatchMessage(message) || matches(r.listMessageMatcher, message) || matches(r.unsubscribeAllMessageMatcher, message) {
+		return 0
+	}
+	topics, err := r.topicExtractor.ExtractTopics(message)
+	if err != nil {
+		return 0
+	}
+
+	subscribed := r.Subscriptions(connClient)
+	var added []string
+	for _, topic := range topics {
+		if !slices.Contains(subscribed, topic) && !slices.Contains(added, topic) {
+			added = append(added, topic)
+		}
+	}
+	return len(added)
+}
+
+func (r TopicSubscriptionRule) handleSubscription(ctx context.Context, message Message, connClient Connection, connServer Connection) error {
+	topics, err := r.topicExtractor.ExtractTopics(message)
+	if err != nil {
+		return err
+	}
+
+	r.subscriptions.subscribe(ctx, connClient, topics, r.duplicateSubscription, func(topic string) func() {
+		feed, ok := r.feeds[topic]
+		if !ok {
+			return func() {}
+		}
+		return startUpdates(ctx, feed, message, connClient, connServer)
+	})
+
+	return r.subscriptionResponse.Handle(ctx, message, connClient, connServer)
+}
+
+func (r TopicSubscriptionRule) handleUnsubscription(ctx context.Context, message Message, connClient Connection, connServer Connection) error {
+	topics, err := r.topicExtractor.ExtractTopics(message)
+	if err != nil {
+		return err
+	}
+
+	r.subscriptions.unsubscribe(connClient, topics)
+
+	return r.unsubscriptionResponse.Handle(ctx, message, connClient, connServer)
+}
+
+func matches(matcher MessageMatcher, message Message) bool {
+	return matcher != nil && matcher.MatchMessage(message)
+}
+
+// topicSubscriptions are the topics every connection is subscribed to
+type topicSubscriptions struct {
+	lock        sync.Mutex
+	connections map[Connection]*connectionTopics
+}
+
+type connectionTopics struct {
+	topics []string
+	// cancels stops the feed of each topic
+	cancels map[string]func()
+	// stop stops unsubscribing the connection once it goes away
+	stop func() bool
+}
+
+// subscribe subscribes conn to topics, starting the feed of each with start.
+// ctx is done once the connection goes away, which unsubscribes it from every topic.
+// A connection is only kept track of while it is subscribed to a topic.
+func (s *topicSubscriptions) subscribe(ctx context.Context, conn Connection, topics []string, duplicate DuplicateSubscription, start func(string) func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c, ok := s.connections[conn]
+	if !ok {
+		if len(topics) == 0 {
+			return
+		}
+		c = &connectionTopics{cancels: map[string]func(){}}
+		c.stop = context.AfterFunc(ctx, func() { s.unsubscribe(conn, nil) })
+		s.connections[conn] = c
+	}
+
+	for _, topic := range topics {
+		if cancel, ok := c.cancels[topic]; ok {
+			if duplicate == DuplicateSubscriptionRestart {
+				cancel()
+				c.cancels[topic] = start(topic)
+			}
+			continue
+		}
+		c.topics = append(c.topics, topic)
+		c.cancels[topic] = start(topic)
+	}
+}
+
+// unsubscribe unsubscribes conn from topics, or from every topic if topics is nil
+func (s *topicSubscriptions) unsubscribe(conn Connection, topics []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c, ok := s.connections[conn]
+	if !ok {
+		return
+	}
+	if topics == nil {
+		topics = slices.Clone(c.topics)
+	}
+
+	for _, topic := range topics {
+		if cancel, ok := c.cancels[topic]; ok {
+			cancel()
+			delete(c.cancels, topic)
+			c.topics = slices.DeleteFunc(c.topics, func(t string) bool { return t == topic })
+		}
+	}
+	if len(c.topics) == 0 {
+		c.stop()
+		delete(s.connections, conn)
+	}
+}
+
+// reset unsubscribes every connection from every topic
+func (s *topicSubscriptions) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for conn, c := range s.connections {
+		for _, cancel := range c.cancels {
+			cancel()
+		}
+		c.stop()
+		delete(s.connections, conn)
+	}
+}
+
+func (s *topicSubscriptions) topics(conn Connection) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c, ok := s.connections[conn]
+	if !ok {
+		return nil
+	}
+	return slices.Clone(c.topics)
+}
+
+func (s *topicSubscriptions) subscribers(topic string) []Connection {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var conns []Connection
+	for conn, c := range s.connections {
+		if _, ok := c.cancels[topic]; ok {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
@@ -0,0 +1,215 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// methodMatcher matches the JSON messages with the given "method"
+func methodMatcher(t *testing.T, method string) *MockMessageMatcher {
+	m := NewMockMessageMatcher(t)
+	m.On("MatchMessage", mock.Anything).Return(func(message Message) bool {
+		var v struct{ Method string }
+		return json.Unmarshal(message.Data, &v) == nil && v.Method == method
+	}).Maybe()
+	return m
+}
+
+func request(method string, topics ...string) Message {
+	params, _ := json.Marshal(topics)
+	return Message{Type: MessageText, Data: []byte(fmt.Sprintf(`{"method":%q,"params":%s,"id":1}`, method, params))}
+}
+
+// testFeeds returns a feed for each topic, which tells when it starts and stops
+func testFeeds(t *testing.T, topics ...string) (map[string]MessageHandler, chan string, chan string) {
+	started, stopped := make(chan string, 10), make(chan string, 10)
+	feeds := map[string]MessageHandler{}
+	for _, topic := range topics {
+		feed := NewMockMessageHandler(t)
+		feed.On("Handle", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			started <- topic
+			<-args.Get(0).(context.Context).Done()
+			stopped <- topic
+		}).Maybe()
+		feeds[topic] = feed
+	}
+	return feeds, started, stopped
+}
+
+func receive(t *testing.T, ch chan string, n int) []string {
+	var result []string
+	for range n {
+		result = append(result, <-ch)
+	}
+	require.Empty(t, ch)
+	return result
+}
+
+func newTestTopicSubscriptionRule(t *testing.T, feeds map[string]MessageHandler) TopicSubscriptionRule {
+	response := NewMessageFromString(MessageText, `{"result":null,"id":1}`, 0)
+	return NewTopicSubscriptionRule(
+		methodMatcher(t, "SUBSCRIBE"), response,
+		methodMatcher(t, "UNSUBSCRIBE"), response,
+		NewJsonTopicExtractor("params"), feeds,
+	).
+		WithUnsubscribeAll(methodMatcher(t, "UNSUBSCRIBE_ALL"), response).
+		WithListing(methodMatcher(t, "LIST_SUBSCRIPTIONS"), JsonTopicList)
+}
+
+func TestTopicSubscriptionRule_MatchMessage(t *testing.T) {
+	rule := newTestTopicSubscriptionRule(t, nil)
+
+	assert.True(t, rule.MatchMessage(request("SUBSCRIBE", "a")))
+	assert.True(t, rule.MatchMessage(request("UNSUBSCRIBE", "a")))
+	assert.True(t, rule.MatchMessage(request("UNSUBSCRIBE_ALL")))
+	assert.True(t, rule.MatchMessage(request("LIST_SUBSCRIPTIONS")))
+	assert.False(t, rule.MatchMessage(request("PING")))
+}
+
+func TestTopicSubscriptionRule_Handle(t *testing.T) {
+	feeds, started, stopped := testFeeds(t, "btcusdt@depth", "btcusdt@trade", "ethusdt@depth")
+	rule := newTestTopicSubscriptionRule(t, feeds)
+	ctx := context.Background()
+	conn := NewMockConnection(t)
+	var written []string
+	conn.On("Write", ctx, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		written = append(written, string(args.Get(1).(Message).Data))
+	})
+
+	// Subscribe to several topics at once, including one without a feed
+	err := rule.Handle(ctx, request("SUBSCRIBE", "btcusdt@depth", "btcusdt@trade", "xrpusdt@depth"), conn, nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"btcusdt@depth", "btcusdt@trade"}, receive(t, started, 2))
+
+	err = rule.Handle(ctx, request("SUBSCRIBE", "ethusdt@depth"), conn, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ethusdt@depth"}, receive(t, started, 1))
+
+	err = rule.Handle(ctx, request("UNSUBSCRIBE", "btcusdt@trade"), conn, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"btcusdt@trade"}, receive(t, stopped, 1))
+
+	err = rule.Handle(ctx, request("LIST_SUBSCRIPTIONS"), conn, nil)
+	assert.NoError(t, err)
+
+	err = rule.Handle(ctx, request("UNSUBSCRIBE_ALL"), conn, nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"btcusdt@depth", "ethusdt@depth"}, receive(t, stopped, 2))
+	assert.Empty(t, rule.Subscriptions(conn))
+
+	assert.Equal(t, []string{
+		`{"result":null,"id":1}`,
+		`{"result":null,"id":1}`,
+		`{"result":null,"id":1}`,
+		`{"result":["btcusdt@depth","xrpusdt@depth","ethusdt@depth"],"id":1}`,
+		`{"result":null,"id":1}`,
+	}, written)
+}
+
+func TestTopicSubscriptionRule_Handle_DuplicateSubscription(t *testing.T) {
+	tests := []struct {
+		name            string
+		duplicate       DuplicateSubscription
+		expectedStarted int
+		expectedStopped int
+	}{
+		{"Ignore", DuplicateSubscriptionIgnore, 1, 0},
+		{"Restart", DuplicateSubscriptionRestart, 2, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feeds, started, stopped := testFeeds(t, "btcusdt@depth")
+			rule := newTestTopicSubscriptionRule(t, feeds).WithDuplicateSubscription(tt.duplicate)
+			ctx := context.Background()
+			conn := NewMockConnection(t)
+			conn.On("Write", ctx, mock.Anything).Return(nil)
+
+			for range 2 {
+				err := rule.Handle(ctx, request("SUBSCRIBE", "btcusdt@depth"), conn, nil)
+				assert.NoError(t, err)
+			}
+
+			assert.Len(t, receive(t, started, tt.expectedStarted), tt.expectedStarted)
+			assert.Len(t, receive(t, stopped, tt.expectedStopped), tt.expectedStopped)
+			assert.Equal(t, []string{"btcusdt@depth"}, rule.Subscriptions(conn))
+		})
+	}
+}
+
+func TestTopicSubscriptionRule_Handle_Connections(t *testing.T) {
+	feeds, started, stopped := testFeeds(t, "btcusdt@depth")
+	rule := newTestTopicSubscriptionRule(t, feeds)
+	conn1, conn2 := NewMockConnection(t), NewMockConnection(t)
+	conn1.On("Write", mock.Anything, mock.Anything).Return(nil)
+	conn2.On("Write", mock.Anything, mock.Anything).Return(nil)
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	assert.NoError(t, rule.Handle(ctx1, request("SUBSCRIBE", "btcusdt@depth"), conn1, nil))
+	assert.NoError(t, rule.Handle(ctx2, request("SUBSCRIBE", "btcusdt@depth"), conn2, nil))
+	receive(t, started, 2)
+
+	// The first connection goes away, which stops its feed only
+	cancel1()
+	receive(t, stopped, 1)
+	assert.Eventually(t, func() bool { return rule.Subscriptions(conn1) == nil }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"btcusdt@depth"}, rule.Subscriptions(conn2))
+}
+
+func TestTopicSubscriptionRule_Handle_NoTopics(t *testing.T) {
+	feeds, started, stopped := testFeeds(t, "btcusdt@depth")
+	rule := newTestTopicSubscriptionRule(t, feeds)
+	ctx := context.Background()
+	conn := NewMockConnection(t)
+	conn.On("Write", ctx, mock.Anything).Return(nil)
+
+	// Neither subscribing to no topic nor unsubscribing from every topic leaves the connection behind
+	assert.NoError(t, rule.Handle(ctx, Message{Type: MessageText, Data: []byte(`{"method":"SUBSCRIBE","params":[],"id":1}`)}, conn, nil))
+	assert.Empty(t, rule.subscriptions.connections)
+
+	assert.NoError(t, rule.Handle(ctx, request("SUBSCRIBE", "btcusdt@depth"), conn, nil))
+	receive(t, started, 1)
+	assert.NoError(t, rule.Handle(ctx, request("UNSUBSCRIBE", "btcusdt@depth"), conn, nil))
+	receive(t, stopped, 1)
+	assert.Empty(t, rule.subscriptions.connections)
+}
+
+func TestTopicSubscriptionRule_Handle_Error(t *testing.T) {
+	rule := newTestTopicSubscriptionRule(t, nil)
+	conn := NewMockConnection(t)
+
+	err := rule.Handle(context.Background(), Message{Type: MessageText, Data: []byte(`{"method":"SUBSCRIBE","id":1}`)}, conn, nil)
+
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "params"))
+}
+
+func TestTopicSubscriptionRule_Reset(t *testing.T) {
+	feeds, started, stopped := testFeeds(t, "btcusdt@depth", "ethusdt@depth")
+	rule := newTestTopicSubscriptionRule(t, feeds)
+	ctx := context.Background()
+	conn1, conn2 := NewMockConnection(t), NewMockConnection(t)
+	conn1.On("Write", ctx, mock.Anything).Return(nil)
+	conn2.On("Write", ctx, mock.Anything).Return(nil)
+
+	err := rule.Handle(ctx, request("SUBSCRIBE", "btcusdt@depth"), conn1, nil)
+	require.NoError(t, err)
+	err = rule.Handle(ctx, request("SUBSCRIBE", "ethusdt@depth"), conn2, nil)
+	require.NoError(t, err)
+	receive(t, started, 2)
+
+	rule.Reset()
+	assert.ElementsMatch(t, []string{"btcusdt@depth", "ethusdt@depth"}, receive(t, stopped, 2))
+	assert.Empty(t, rule.Subscriptions(conn1))
+	assert.Empty(t, rule.Subscribers("ethusdt@depth"))
+}
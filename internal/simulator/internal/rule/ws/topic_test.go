@@ -0,0 +1,89 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonTopicExtractor_ExtractTopics(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		data      string
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "Array",
+			path:     "params",
+			data:     `{"method":"SUBSCRIBE","params":["btcusdt@depth","btcusdt@trade"],"id":1}`,
+			expected: []string{"btcusdt@depth", "btcusdt@trade"},
+		},
+		{
+			name:     "String",
+			path:     "arg.channel",
+			data:     `{"op":"subscribe","arg":{"channel":"books"}}`,
+			expected: []string{"books"},
+		},
+		{
+			name:     "Empty array",
+			path:     "params",
+			data:     `{"method":"UNSUBSCRIBE","params":[],"id":1}`,
+			expected: []string{},
+		},
+		{
+			name:      "Missing",
+			path:      "params",
+			data:      `{"method":"SUBSCRIBE","id":1}`,
+			expectErr: true,
+		},
+		{
+			name:      "Not a string",
+			path:      "params",
+			data:      `{"params":[1]}`,
+			expectErr: true,
+		},
+		{
+			name:      "Not JSON",
+			path:      "params",
+			data:      `subscribe`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topics, err := NewJsonTopicExtractor(tt.path).ExtractTopics(Message{Type: MessageText, Data: []byte(tt.data)})
+
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, topics)
+			}
+		})
+	}
+}
+
+func TestJsonTopicList(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		topics   []string
+		expected string
+	}{
+		{"With id", `{"method":"LIST_SUBSCRIPTIONS","id":3}`, []string{"btcusdt@depth"}, `{"result":["btcusdt@depth"],"id":3}`},
+		{"Without id", `{"method":"LIST_SUBSCRIPTIONS"}`, []string{"btcusdt@depth"}, `{"result":["btcusdt@depth"]}`},
+		{"No topics", `{"method":"LIST_SUBSCRIPTIONS","id":"a"}`, nil, `{"result":[],"id":"a"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := JsonTopicList(Message{Type: MessageText, Data: []byte(tt.request)}, tt.topics)
+
+			assert.NoError(t, err)
+			assert.Equal(t, Message{Type: MessageText, Data: []byte(tt.expected)}, response)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package simulator
+
+import (
+	"cmp"
+	"context"
+	"encoding/hex"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultJournalSize is how many entries the journal keeps if the config does not tell
+const DefaultJournalSize = 10000
+
+// JournalKind tells what a journal entry is about
+type JournalKind string
+
+const (
+	JournalHttp       JournalKind = "http"
+	JournalWsOpen     JournalKind = "ws_open"
+	JournalWsInbound  JournalKind = "ws_in"
+	JournalWsOutbound JournalKind = "ws_out"
+	JournalWsClose    JournalKind = "ws_close"
+)
+
+// JournalEntry is a HTTP request the simulator received, or a WebSocket connection opening, closing,
+// or a message read from or written to it
+type JournalEntry struct {
+	// Seq orders the entries, as they were made
+	Seq  uint64      `json:"seq"`
+	Time time.Time   `json:"time"`
+	Kind JournalKind `json:"kind"`
+	// Rule is the id of the rule a HTTP request or an inbound message matched, or -1 if none did or the entry is not one
+	Rule int `json:"rule"`
+
+	Method      string `json:"method,omitempty"`
+	Path        string `json:"path,omitempty"`
+	QueryString string `json:"query_string,omitempty"`
+	// StatusCode is what the request was responded with, or 0 while the response is made or if making it failed
+	StatusCode int `json:"status_code,omitempty"`
+
+	ConnectionId uint64 `json:"connection_id,omitempty"`
+	// Type is the type of a message, text or binary
+	Type string `json:"type,omitempty"`
+	// Body is the body of a HTTP request or the data of a message, hex-encoded if it is binary
+	Body string `json:"body,omitempty"`
+}
+
+// JournalQuery selects journal entries; every field that is not empty must match
+type JournalQuery struct {
+	Kind   JournalKind
+	Method string
+	Path   string
+	// Params must be among the parameters of the query string or of a form-encoded body, such as symbol=BTCUSDT
+	Params       map[string]string
+	ConnectionId uint64
+	// Contains must be in the body of a request or the data of a message
+	Contains string
+}
+
+func (q JournalQuery) match(e JournalEntry) bool {
+	if (q.Kind != "" && e.Kind != q.Kind) ||
+		(q.Method != "" && e.Method != q.Method) ||
+		(q.Path != "" && e.Path != q.Path) ||
+		(q.ConnectionId != 0 && e.ConnectionId != q.ConnectionId) ||
+		(q.Contains != "" && !strings.Contains(e.Body, q.Contains)) {
+		return false
+	}
+	if len(q.Params) == 0 {
+		return true
+	}
+
+	query, _ := url.ParseQuery(e.QueryString)
+	form, _ := url.ParseQuery(e.Body)
+	for key, value := range q.Params {
+		if query.Get(key) != value && form.Get(key) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// journal keeps the latest entries, up to its size
+type journal struct {
+	size int
+
+	lock    sync.Mutex
+	entries []JournalEntry
+	seq     uint64
+}
+
+func newJournal(size int) *journal {
+	if size == 0 {
+		size = DefaultJournalSize
+	}
+	return &journal{size: max(0, size)}
+}
+
+// add adds e and returns its seq
+func (j *journal) add(e JournalEntry) uint64 {
+	if j.size == 0 {
+		return 0
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.seq++
+	e.Seq = j.seq
+	if len(j.entries) == j.size {
+		j.entries = j.entries[1:]
+	}
+	j.entries = append(j.entries, e)
+	return e.Seq
+}
+
+// update changes the entry with seq by f, unless it is no longer kept
+func (j *journal) update(seq uint64, f func(*JournalEntry)) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	i, ok := slices.BinarySearchFunc(j.entries, seq, func(e JournalEntry, seq uint64) int { return cmp.Compare(e.Seq, seq) })
+	if ok {
+		f(&j.entries[i])
+	}
+}
+
+// find returns the entries that match q, in the order they were made
+func (j *journal) find(q JournalQuery) []JournalEntry {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	entries := []JournalEntry{}
+	for _, e := range j.entries {
+		if q.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func (j *journal) reset() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.entries = nil
+}
+
+// Journal returns the entries of the journal that match query, in the order they were made,
+// e.g. to verify that a client placed an order twice or unsubscribed before disconnecting
+func (s Simulator) Journal(query JournalQuery) []JournalEntry {
+	return s.journal.find(query)
+}
+
+// ResetJournal empties the journal, e.g. between tests
+func (s Simulator) ResetJournal() {
+	s.journal.reset()
+}
+
+// journalHttp journals request as it arrives at t, to be responded to by the rule with ruleId, and returns the seq of the entry
+func (s Simulator) journalHttp(request HttpRequest, ruleId int, t time.Time) uint64 {
+	return s.journal.add(JournalEntry{
+		Time:        t,
+		Kind:        JournalHttp,
+		Rule:        ruleId,
+		Method:      request.Method,
+		Path:        request.Path,
+		QueryString: request.QueryString,
+		Body:        string(request.Body),
+	})
+}
+
+// journalHttpResponse fills in the response to the request journaled with seq
+func (s Simulator) journalHttpResponse(seq uint64, response HttpResponse) {
+	s.journal.update(seq, func(e *JournalEntry) {
+		e.StatusCode = response.StatusCode
+	})
+}
+
+func (s Simulator) journalWs(kind JournalKind, connectionId uint64, ruleId int, message WsMessage) {
+	e := JournalEntry{
+		Time:         s.clock.Now(),
+		Kind:         kind,
+		Rule:         ruleId,
+		ConnectionId: connectionId,
+	}
+	switch message.Type {
+	case WsMessageText:
+		e.Type = "text"
+		e.Body = string(message.Data)
+	case WsMessageBinary:
+		e.Type = "binary"
+		e.Body = hex.EncodeToString(message.Data)
+	}
+	s.journal.add(e)
+}
+
+// Ensure journalingConnection implements WsConnection
+var _ WsConnection = (*journalingConnection)(nil)
+
+// journalingConnection journals every message written to a client connection.
+// The messages read from it are journaled as they are matched to a rule; see Simulator.handleWsConnection.
+type journalingConnection struct {
+	WsConnection
+	simulator    Simulator
+	connectionId uint64
+}
+
+func (c journalingConnection) Write(ctx context.Context, message WsMessage) error {
+	err := c.WsConnection.Write(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	c.simulator.journalWs(JournalWsOutbound, c.connectionId, -1, message)
+	return nil
+}
@@ -0,0 +1,232 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalQuery_match(t *testing.T) {
+	order := JournalEntry{Kind: JournalHttp, Method: "POST", Path: "/api/v3/order", QueryString: "symbol=BTCUSDT&side=BUY", Body: "type=LIMIT"}
+	message := JournalEntry{Kind: JournalWsInbound, ConnectionId: 2, Type: "text", Body: `{"method":"UNSUBSCRIBE"}`}
+
+	tests := []struct {
+		name     string
+		query    JournalQuery
+		entry    JournalEntry
+		expected bool
+	}{
+		{"Empty", JournalQuery{}, order, true},
+		{"Request", JournalQuery{Kind: JournalHttp, Method: "POST", Path: "/api/v3/order"}, order, true},
+		{"Method", JournalQuery{Method: "GET"}, order, false},
+		{"Query param", JournalQuery{Params: map[string]string{"symbol": "BTCUSDT", "side": "BUY"}}, order, true},
+		{"Body param", JournalQuery{Params: map[string]string{"type": "LIMIT"}}, order, true},
+		{"Other param", JournalQuery{Params: map[string]string{"symbol": "ETHUSDT"}}, order, false},
+		{"Message", JournalQuery{Kind: JournalWsInbound, ConnectionId: 2, Contains: "UNSUBSCRIBE"}, message, true},
+		{"Connection", JournalQuery{ConnectionId: 1}, message, false},
+		{"Kind", JournalQuery{Kind: JournalWsOutbound}, message, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.query.match(tt.entry))
+		})
+	}
+}
+
+func TestJournal(t *testing.T) {
+	j := newJournal(2)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		j.add(JournalEntry{Kind: JournalHttp, Path: path})
+	}
+	assert.Equal(t, []JournalEntry{{Seq: 2, Kind: JournalHttp, Path: "/b"}, {Seq: 3, Kind: JournalHttp, Path: "/c"}}, j.find(JournalQuery{}))
+
+	j.update(3, func(e *JournalEntry) { e.StatusCode = 200 })
+	j.update(1, func(e *JournalEntry) { e.StatusCode = 500 })
+	assert.Equal(t, []JournalEntry{{Seq: 2, Kind: JournalHttp, Path: "/b"}, {Seq: 3, Kind: JournalHttp, Path: "/c", StatusCode: 200}}, j.find(JournalQuery{}))
+
+	j.reset()
+	assert.Empty(t, j.find(JournalQuery{}))
+
+	j = newJournal(-1)
+	j.add(JournalEntry{Kind: JournalHttp})
+	assert.Empty(t, j.find(JournalQuery{}))
+}
+
+func TestSimulator_Journal_Http(t *testing.T) {
+	now := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	sim := New(Config{
+		HttpBasePath:  "/api",
+		AdminBasePath: "/admin",
+		HttpRules: []HttpRule{
+			NewHttpRule(NewHttpRequestPredicate("POST", "/v3/order"), NewHttpResponseFromString(200, `{"orderId":1}`, 0)),
+		},
+		Clock: NewFakeClock(now),
+	})
+	for _, r := range []*http.Request{
+		httptest.NewRequest("POST", "/api/v3/order?symbol=BTCUSDT", nil),
+		httptest.NewRequest("POST", "/api/v3/order", strings.NewReader("symbol=BTCUSDT&side=SELL")),
+		httptest.NewRequest("POST", "/api/v3/order?symbol=ETHUSDT", nil),
+		httptest.NewRequest("GET", "/api/v3/time", nil),
+	} {
+		sim.requestHandler(httptest.NewRecorder(), r)
+	}
+
+	entries := sim.Journal(JournalQuery{Method: "POST", Path: "/v3/order", Params: map[string]string{"symbol": "BTCUSDT"}})
+	assert.Equal(t, []JournalEntry{
+		{Seq: 1, Time: now, Kind: JournalHttp, Rule: 0, Method: "POST", Path: "/v3/order", QueryString: "symbol=BTCUSDT", StatusCode: 200},
+		{Seq: 2, Time: now, Kind: JournalHttp, Rule: 0, Method: "POST", Path: "/v3/order", StatusCode: 200, Body: "symbol=BTCUSDT&side=SELL"},
+	}, entries)
+	assert.Equal(t, []JournalEntry{
+		{Seq: 4, Time: now, Kind: JournalHttp, Rule: -1, Method: "GET", Path: "/v3/time", StatusCode: 404},
+	}, sim.Journal(JournalQuery{Path: "/v3/time"}))
+
+	w := httptest.NewRecorder()
+	sim.requestHandler(w, httptest.NewRequest("GET", "/admin/journal?method=POST&path=/v3/order&param=symbol=BTCUSDT", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result struct {
+		Count   int            `json:"count"`
+		Entries []JournalEntry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 2, result.Count)
+	assert.Equal(t, entries, result.Entries)
+
+	w = httptest.NewRecorder()
+	sim.requestHandler(w, httptest.NewRequest("GET", "/admin/journal?param=symbol", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	sim.requestHandler(w, httptest.NewRequest("DELETE", "/admin/journal", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, sim.Journal(JournalQuery{}))
+}
+
+func TestSimulator_Journal_Http_InProgress(t *testing.T) {
+	release := make(chan struct{})
+	sim := New(Config{
+		HttpBasePath: "/api",
+		HttpRules: []HttpRule{
+			NewHttpRule(NewHttpRequestPredicate("GET", "/v3/time"), httpResponderFunc(func(HttpRequest) (HttpResponse, error) {
+				<-release
+				return HttpResponse{StatusCode: 200}, nil
+			})),
+		},
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sim.requestHandler(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v3/time", nil))
+	}()
+
+	// The request is journaled as soon as it arrives, and its response once it is made
+	require.Eventually(t, func() bool { return len(sim.Journal(JournalQuery{})) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 0, sim.Journal(JournalQuery{})[0].StatusCode)
+	close(release)
+	<-done
+	assert.Equal(t, 200, sim.Journal(JournalQuery{})[0].StatusCode)
+}
+
+func TestSimulator_Journal_Ws(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	response := NewWsMessageFromString(WsMessageText, `{"result":null}`, 0)
+	sim := New(Config{
+		WsEndpoint: "/ws",
+		WsRules: []WsRule{
+			NewWsTopicSubscriptionRule(
+				wsMethodMatcher("SUBSCRIBE"), response,
+				wsMethodMatcher("UNSUBSCRIBE"), response,
+				NewWsJsonTopicExtractor("params"), nil,
+			),
+		},
+	})
+	server := httptest.NewServer(http.HandlerFunc(sim.requestHandler))
+	defer server.Close()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.CloseNow()
+
+	_, err = exchange(t, ctx, conn, `{"method":"SUBSCRIBE","params":["a"]}`)
+	require.NoError(t, err)
+	_, err = exchange(t, ctx, conn, `{"method":"UNSUBSCRIBE","params":["a"]}`)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close(websocket.StatusNormalClosure, ""))
+	require.Eventually(t, func() bool { return len(sim.Journal(JournalQuery{Kind: JournalWsClose})) == 1 }, time.Second, 10*time.Millisecond)
+
+	// A response is journaled once it is written, which may be after the client sent the next message
+	kinds := map[JournalKind]int{}
+	entries := sim.Journal(JournalQuery{ConnectionId: 1})
+	for _, e := range entries {
+		kinds[e.Kind]++
+	}
+	assert.Equal(t, map[JournalKind]int{JournalWsOpen: 1, JournalWsInbound: 2, JournalWsOutbound: 2, JournalWsClose: 1}, kinds)
+	assert.Equal(t, JournalWsOpen, entries[0].Kind)
+
+	inbound := sim.Journal(JournalQuery{Kind: JournalWsInbound, ConnectionId: 1})
+	last := inbound[len(inbound)-1]
+	assert.Contains(t, last.Body, "UNSUBSCRIBE")
+	assert.Equal(t, 0, last.Rule)
+	assert.Less(t, last.Seq, sim.Journal(JournalQuery{Kind: JournalWsClose})[0].Seq)
+}
+
+func TestSimulator_JournalHar(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	sim := New(Config{
+		HttpBasePath:  "/api",
+		AdminBasePath: "/admin",
+		HttpRules: []HttpRule{
+			NewHttpRule(NewHttpRequestPredicate("POST", "/v3/order"), NewHttpResponseFromString(200, `{"orderId":1}`, 0)),
+		},
+		WsEndpoint: "/ws",
+		WsRules: []WsRule{
+			NewWsRule(NewWsMessagePredicate(WsMessageText, []byte("ping")), NewWsMessageFromString(WsMessageText, "pong", 0)),
+		},
+	})
+	server := httptest.NewServer(http.HandlerFunc(sim.requestHandler))
+	defer server.Close()
+
+	response, err := http.Post(server.URL+"/api/v3/order?symbol=BTCUSDT", "application/x-www-form-urlencoded", strings.NewReader("side=SELL"))
+	require.NoError(t, err)
+	response.Body.Close()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.CloseNow()
+	_, err = exchange(t, ctx, conn, "ping")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(sim.Journal(JournalQuery{Kind: JournalWsOutbound})) == 1 }, time.Second, 10*time.Millisecond)
+
+	response, err = http.Get(server.URL + "/admin/journal/har")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	h, err := DecodeHar(response.Body)
+	require.NoError(t, err)
+
+	require.Len(t, h.Log.Entries, 2)
+	order := h.Log.Entries[0]
+	assert.Equal(t, "POST", order.Request.Method)
+	assert.Equal(t, server.URL+"/api/v3/order?symbol=BTCUSDT", order.Request.Url)
+	assert.Equal(t, "side=SELL", order.Request.PostData.Text)
+	assert.Equal(t, 200, order.Response.Status)
+
+	connection := h.Log.Entries[1]
+	assert.Equal(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws", connection.Request.Url)
+	assert.Equal(t, 101, connection.Response.Status)
+	require.Len(t, connection.WebSocketMessages, 2)
+	assert.Equal(t, "send", connection.WebSocketMessages[0].Type)
+	assert.Equal(t, "ping", connection.WebSocketMessages[0].Data)
+	assert.Equal(t, "receive", connection.WebSocketMessages[1].Type)
+	assert.Equal(t, "pong", connection.WebSocketMessages[1].Data)
+}
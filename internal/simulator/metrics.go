@@ -0,0 +1,82 @@
+package simulator
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"alphanonce.com/exchangesimulator/internal/log"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/metrics"
+)
+
+// newContext returns a copy of ctx that carries the clock and the metrics of s, for the rules to run on and count in
+func (s Simulator) newContext(ctx context.Context) context.Context {
+	return metrics.NewContext(clock.NewContext(ctx, s.clock), s.metrics)
+}
+
+// countHttp counts a request responded to by the rule with ruleId, or by none if it is -1
+func (s Simulator) countHttp(ruleId int, response HttpResponse, err error) {
+	rule := "none"
+	if ruleId != -1 {
+		rule = strconv.Itoa(ruleId)
+	}
+	statusCode := "error"
+	if err == nil {
+		statusCode = strconv.Itoa(response.StatusCode)
+	}
+	s.metrics.Add(metrics.HttpRequests, 1, rule, statusCode)
+}
+
+// Ensure metricsConnection implements WsConnection
+var _ WsConnection = (*metricsConnection)(nil)
+
+// metricsConnection counts every message read from and written to a client connection
+type metricsConnection struct {
+	WsConnection
+	metrics  *metrics.Registry
+	endpoint string
+}
+
+func (c metricsConnection) Read(ctx context.Context) (WsMessage, error) {
+	message, err := c.WsConnection.Read(ctx)
+	if err != nil {
+		return message, err
+	}
+
+	c.metrics.Add(metrics.WsMessages, 1, c.endpoint, "in")
+	return message, nil
+}
+
+func (c metricsConnection) Write(ctx context.Context, message WsMessage) error {
+	err := c.WsConnection.Write(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	c.metrics.Add(metrics.WsMessages, 1, c.endpoint, "out")
+	return nil
+}
+
+// metricsHandler serves the metrics in the Prometheus text format,
+// with the gauges of the WebSocket connections as they are at the time.
+// The gauges are kept apart for every scrape, so that scrapes made at once do not see each other's.
+func (s Simulator) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	backlogs := s.WsBacklogs()
+	gauges := metrics.NewRegistry()
+	if s.config.WsEndpoint != "" {
+		gauges.Set(metrics.WsConnectionsOpen, float64(len(backlogs)), s.config.WsEndpoint)
+	}
+	for _, b := range backlogs {
+		id := strconv.FormatUint(b.ConnectionId, 10)
+		gauges.Set(metrics.WsWriteQueued, float64(b.Queued), id)
+		gauges.Set(metrics.WsWriteMaxQueued, float64(b.MaxQueued), id)
+		gauges.Set(metrics.WsWriteDropped, float64(b.Dropped), id)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	err := s.metrics.WriteText(w, gauges)
+	if err != nil {
+		logger.Info("Stopped writing the metrics", log.Any("error", err))
+	}
+}
@@ -0,0 +1,76 @@
+package simulator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulator_metricsHandler(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	sim := New(Config{
+		HttpBasePath: "/api",
+		HttpRules: []HttpRule{
+			NewHttpRule(NewHttpRequestPredicate("GET", "/time"), NewHttpResponseFromString(200, `{"serverTime":0}`, 0)),
+		},
+		WsEndpoint: "/ws",
+		WsRules: []WsRule{
+			NewWsRule(NewWsMessagePredicate(WsMessageText, []byte("ping")), NewWsMessageFromString(WsMessageText, "pong", 0)),
+		},
+		MetricsPath: "/metrics",
+	})
+	server := httptest.NewServer(http.HandlerFunc(sim.requestHandler))
+	defer server.Close()
+
+	for _, path := range []string{"/api/time", "/api/time", "/api/depth"} {
+		sim.requestHandler(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.CloseNow()
+	_, err = exchange(t, ctx, conn, "ping")
+	require.NoError(t, err)
+	_, err = exchange(t, ctx, conn, "hello")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	sim.requestHandler(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	for _, line := range []string{
+		`exchangesimulator_http_requests_total{rule="0",status_code="200"} 2`,
+		`exchangesimulator_http_requests_total{rule="none",status_code="404"} 1`,
+		`exchangesimulator_http_unmatched_requests_total 1`,
+		`exchangesimulator_ws_connections_total{endpoint="/ws"} 1`,
+		`exchangesimulator_ws_connections_open{endpoint="/ws"} 1`,
+		`exchangesimulator_ws_messages_total{endpoint="/ws",direction="in"} 2`,
+		`exchangesimulator_ws_unmatched_messages_total{endpoint="/ws"} 1`,
+		`exchangesimulator_ws_write_queued{connection_id="1"} 0`,
+		`exchangesimulator_ws_write_dropped{connection_id="1"} 0`,
+	} {
+		assert.Contains(t, w.Body.String(), line+"\n")
+	}
+
+	// Every scrape made at once has the gauges of every connection
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			sim.requestHandler(w, httptest.NewRequest("GET", "/metrics", nil))
+			assert.Contains(t, w.Body.String(), `exchangesimulator_ws_write_queued{connection_id="1"} 0`+"\n")
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,187 @@
+package simulator
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OutageScope tells what an outage takes down
+type OutageScope string
+
+const (
+	OutageScopeAll  OutageScope = ""
+	OutageScopeHttp OutageScope = "http"
+	OutageScopeWs   OutageScope = "ws"
+)
+
+// covers tells whether an outage with scope s takes down protocol, OutageScopeHttp or OutageScopeWs
+func (s OutageScope) covers(protocol OutageScope) bool {
+	return s == OutageScopeAll || s == protocol
+}
+
+// Outage tells how the exchange behaves while it is down, e.g. for maintenance.
+// The zero value answers every HTTP request with 503 Service Unavailable and closes every WebSocket connection.
+type Outage struct {
+	// Scope tells whether the outage takes down HTTP, WebSocket or both, which OutageScopeAll stands for
+	Scope OutageScope
+	// HttpRules answer the HTTP requests during the outage instead of the rules of the config,
+	// e.g. to flip the status endpoint to maintenance
+	HttpRules []HttpRule
+	// HttpResponse answers the HTTP requests that no rule of HttpRules matches, e.g. with the error body of the exchange.
+	// StatusCode 0 stands for 503 Service Unavailable.
+	HttpResponse HttpResponse
+	// WsClosure closes the open WebSocket connections as the outage starts.
+	// The new ones are refused with 503 Service Unavailable until it ends.
+	WsClosure WsClosure
+}
+
+// response answers request as the outage tells
+func (o Outage) response(request HttpRequest) (HttpResponse, error) {
+	i := slices.IndexFunc(o.HttpRules, func(r HttpRule) bool { return r.MatchRequest(request) })
+	if i != -1 {
+		return o.HttpRules[i].Response(request)
+	}
+
+	if o.HttpResponse.StatusCode == 0 {
+		return HttpResponse{
+			StatusCode: http.StatusServiceUnavailable,
+			Body:       []byte(`{"error":"Service Unavailable"}`),
+		}, nil
+	}
+	return o.HttpResponse, nil
+}
+
+// ScheduledOutage is an outage that starts at At, or After the simulator is made if At is zero, and lasts for Duration.
+// Duration 0 stands for an outage that never ends.
+type ScheduledOutage struct {
+	At       time.Time
+	After    time.Duration
+	Duration time.Duration
+	Outage   Outage
+}
+
+// outageSchedule tells when the exchange is down: during its windows, or from StartOutage to EndOutage
+type outageSchedule struct {
+	windows []outageWindow
+	lock    sync.Mutex
+	// manual is the outage started by hand, if any, which lasts until its end unless that is zero
+	manual *outageWindow
+	// changed is closed and replaced whenever an outage is started or ended by hand
+	changed chan struct{}
+}
+
+type outageWindow struct {
+	start  time.Time
+	end    time.Time
+	outage Outage
+}
+
+// newOutageSchedule schedules outages, the relative ones from now
+func newOutageSchedule(outages []ScheduledOutage, now time.Time) *outageSchedule {
+	windows := make([]outageWindow, 0, len(outages))
+	for _, o := range outages {
+		start := o.At
+		if start.IsZero() {
+			start = now.Add(o.After)
+		}
+		var end time.Time
+		if o.Duration > 0 {
+			end = start.Add(o.Duration)
+		}
+		windows = append(windows, outageWindow{start: start, end: end, outage: o.Outage})
+	}
+	return &outageSchedule{windows: windows, changed: make(chan struct{})}
+}
+
+// start starts outage by hand, until end unless end is zero
+func (s *outageSchedule) start(outage Outage, end time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.manual = &outageWindow{end: end, outage: outage}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *outageSchedule) end() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.manual = nil
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// on tells whether w is on at now and takes down protocol
+func (w outageWindow) on(now time.Time, protocol OutageScope) bool {
+	return !now.Before(w.start) && (w.end.IsZero() || now.Before(w.end)) && w.outage.Scope.covers(protocol)
+}
+
+// current returns the outage on at now that takes down protocol, if any.
+// An outage started by hand comes before the scheduled ones.
+func (s *outageSchedule) current(now time.Time, protocol OutageScope) (Outage, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.manual != nil && s.manual.on(now, protocol) {
+		return s.manual.outage, true
+	}
+	for _, w := range s.windows {
+		if w.on(now, protocol) {
+			return w.outage, true
+		}
+	}
+	return Outage{}, false
+}
+
+// next returns when the next scheduled outage after now that takes down protocol starts, if any
+func (s *outageSchedule) next(now time.Time, protocol OutageScope) (time.Time, bool) {
+	var next time.Time
+	for _, w := range s.windows {
+		if w.start.After(now) && w.outage.Scope.covers(protocol) && (next.IsZero() || w.start.Before(next)) {
+			next = w.start
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// wait waits on c until an outage that takes down protocol is on, and returns it
+func (s *outageSchedule) wait(ctx context.Context, c Clock, protocol OutageScope) (Outage, error) {
+	for {
+		s.lock.Lock()
+		changed := s.changed
+		s.lock.Unlock()
+
+		now := c.Now()
+		if outage, ok := s.current(now, protocol); ok {
+			return outage, nil
+		}
+
+		next, ok := s.next(now, protocol)
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return Outage{}, context.Cause(ctx)
+			case <-changed:
+				continue
+			}
+		}
+
+		sleepCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-changed:
+				cancel()
+			case <-sleepCtx.Done():
+			}
+		}()
+		c.SleepUntil(sleepCtx, next)
+		cancel()
+		if ctx.Err() != nil {
+			return Outage{}, context.Cause(ctx)
+		}
+	}
+}
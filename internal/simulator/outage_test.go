@@ -0,0 +1,225 @@
+package simulator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutageSchedule_current(t *testing.T) {
+	now := time.Date(2000, 1, 23, 10, 0, 0, 0, time.UTC)
+	maintenance := Outage{HttpResponse: HttpResponse{StatusCode: 503, Body: []byte("maintenance")}}
+	failure := Outage{HttpResponse: HttpResponse{StatusCode: 502, Body: []byte("failure")}}
+	schedule := newOutageSchedule([]ScheduledOutage{
+		{After: time.Minute, Duration: 5 * time.Minute, Outage: maintenance},
+		{At: now.Add(time.Hour), Outage: failure},
+	}, now)
+
+	tests := []struct {
+		name     string
+		offset   time.Duration
+		expected *Outage
+	}{
+		{"Before", 59 * time.Second, nil},
+		{"Start", time.Minute, &maintenance},
+		{"During", 3 * time.Minute, &maintenance},
+		{"End", 6 * time.Minute, nil},
+		{"Absolute", time.Hour, &failure},
+		{"Endless", 100 * time.Hour, &failure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outage, ok := schedule.current(now.Add(tt.offset), OutageScopeHttp)
+			if tt.expected == nil {
+				assert.False(t, ok)
+				return
+			}
+			assert.True(t, ok)
+			assert.Equal(t, *tt.expected, outage)
+		})
+	}
+}
+
+func TestOutageSchedule_start(t *testing.T) {
+	now := time.Date(2000, 1, 23, 10, 0, 0, 0, time.UTC)
+	maintenance := Outage{HttpResponse: HttpResponse{StatusCode: 503, Body: []byte("maintenance")}}
+	failure := Outage{HttpResponse: HttpResponse{StatusCode: 502, Body: []byte("failure")}}
+	schedule := newOutageSchedule([]ScheduledOutage{{Duration: time.Minute, Outage: maintenance}}, now)
+
+	schedule.start(failure, time.Time{})
+	outage, ok := schedule.current(now, OutageScopeHttp)
+	assert.True(t, ok)
+	assert.Equal(t, failure, outage)
+
+	schedule.end()
+	outage, ok = schedule.current(now, OutageScopeHttp)
+	assert.True(t, ok)
+	assert.Equal(t, maintenance, outage)
+	_, ok = schedule.current(now.Add(time.Minute), OutageScopeHttp)
+	assert.False(t, ok)
+}
+
+func TestOutageSchedule_start_Duration(t *testing.T) {
+	now := time.Date(2000, 1, 23, 10, 0, 0, 0, time.UTC)
+	failure := Outage{HttpResponse: HttpResponse{StatusCode: 502, Body: []byte("failure")}}
+	schedule := newOutageSchedule(nil, now)
+
+	schedule.start(failure, now.Add(time.Minute))
+
+	_, ok := schedule.current(now.Add(59*time.Second), OutageScopeHttp)
+	assert.True(t, ok)
+	_, ok = schedule.current(now.Add(time.Minute), OutageScopeHttp)
+	assert.False(t, ok)
+}
+
+func TestOutageSchedule_current_Scope(t *testing.T) {
+	now := time.Date(2000, 1, 23, 10, 0, 0, 0, time.UTC)
+	httpOutage := Outage{Scope: OutageScopeHttp}
+	wsOutage := Outage{Scope: OutageScopeWs, WsClosure: WsClosure{Code: 1012}}
+	schedule := newOutageSchedule([]ScheduledOutage{{Outage: wsOutage}}, now)
+	schedule.start(httpOutage, time.Time{})
+
+	outage, ok := schedule.current(now, OutageScopeHttp)
+	assert.True(t, ok)
+	assert.Equal(t, httpOutage, outage)
+	outage, ok = schedule.current(now, OutageScopeWs)
+	assert.True(t, ok)
+	assert.Equal(t, wsOutage, outage)
+
+	schedule.end()
+	_, ok = schedule.current(now, OutageScopeHttp)
+	assert.False(t, ok)
+}
+
+func TestOutageSchedule_wait(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	now := time.Date(2000, 1, 23, 10, 0, 0, 0, time.UTC)
+	maintenance := Outage{WsClosure: WsClosure{Code: 1012, Reason: "maintenance"}}
+	failure := Outage{WsClosure: WsClosure{Reset: true}}
+
+	t.Run("Scheduled", func(t *testing.T) {
+		c := NewFakeClock(now)
+		schedule := newOutageSchedule([]ScheduledOutage{{After: time.Minute, Outage: maintenance}}, now)
+
+		done := make(chan Outage)
+		go func() {
+			outage, err := schedule.wait(ctx, c, OutageScopeWs)
+			assert.NoError(t, err)
+			done <- outage
+		}()
+
+		require.NoError(t, c.WaitForSleepers(ctx, 1))
+		c.Advance(time.Minute)
+		assert.Equal(t, maintenance, <-done)
+	})
+
+	t.Run("Started", func(t *testing.T) {
+		c := NewFakeClock(now)
+		schedule := newOutageSchedule([]ScheduledOutage{{After: time.Minute, Outage: maintenance}}, now)
+
+		done := make(chan Outage)
+		go func() {
+			outage, err := schedule.wait(ctx, c, OutageScopeWs)
+			assert.NoError(t, err)
+			done <- outage
+		}()
+
+		require.NoError(t, c.WaitForSleepers(ctx, 1))
+		schedule.start(failure, time.Time{})
+		assert.Equal(t, failure, <-done)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		schedule := newOutageSchedule(nil, now)
+
+		cancel()
+		_, err := schedule.wait(ctx, NewFakeClock(now), OutageScopeWs)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestSimulator_httpRequestHandler_Outage(t *testing.T) {
+	c := NewFakeClock(time.Date(2000, 1, 23, 10, 0, 0, 0, time.UTC))
+	config := Config{
+		HttpBasePath: "/api",
+		HttpRules: []HttpRule{
+			NewHttpRule(NewHttpRequestPredicate("GET", "/status"), NewHttpResponseFromString(200, `{"status":"1"}`, 0)),
+			NewHttpRule(NewHttpRequestPredicate("POST", "/order"), NewHttpResponseFromString(200, `{"orderId":1}`, 0)),
+		},
+		Outages: []ScheduledOutage{{
+			After:    time.Minute,
+			Duration: 5 * time.Minute,
+			Outage: Outage{
+				HttpRules: []HttpRule{
+					NewHttpRule(NewHttpRequestPredicate("GET", "/status"), NewHttpResponseFromString(200, `{"status":"0"}`, 0)),
+				},
+			},
+		}},
+		Clock: c,
+	}
+	sim := New(config)
+
+	respond := func(method string, path string) (int, string) {
+		w := httptest.NewRecorder()
+		sim.httpRequestHandler(w, httptest.NewRequest(method, path, nil))
+		return w.Code, w.Body.String()
+	}
+
+	code, body := respond("GET", "/api/status")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, `{"status":"1"}`, body)
+
+	c.Advance(time.Minute)
+	code, body = respond("GET", "/api/status")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, `{"status":"0"}`, body)
+	code, body = respond("POST", "/api/order")
+	assert.Equal(t, 503, code)
+	assert.Equal(t, `{"error":"Service Unavailable"}`, body)
+
+	c.Advance(5 * time.Minute)
+	code, body = respond("POST", "/api/order")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, `{"orderId":1}`, body)
+}
+
+func TestSimulator_wsRequestHandler_Outage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	sim := New(Config{WsEndpoint: "/ws"})
+	server := httptest.NewServer(http.HandlerFunc(sim.requestHandler))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	conn, _, err := websocket.Dial(ctx, url, nil)
+	require.NoError(t, err)
+	defer conn.CloseNow()
+
+	sim.StartOutage(Outage{WsClosure: WsClosure{Code: 1012, Reason: "maintenance"}}, 0)
+	_, _, err = conn.Read(ctx)
+	var closeErr websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.StatusServiceRestart, closeErr.Code)
+	assert.Equal(t, "maintenance", closeErr.Reason)
+
+	_, response, err := websocket.Dial(ctx, url, nil)
+	require.Error(t, err)
+	require.NotNil(t, response)
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+
+	sim.EndOutage()
+	conn, _, err = websocket.Dial(ctx, url, nil)
+	require.NoError(t, err)
+	conn.CloseNow()
+}
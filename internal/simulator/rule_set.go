@@ -0,0 +1,155 @@
+package simulator
+
+import (
+	"slices"
+	"sync"
+)
+
+// ruleSet holds the rules of a simulator in the order they are matched in, so that they can be changed as it runs.
+// Every rule is given an id that stays the same while it is in the set; the rules of the config are given their index.
+// The ids of the rules added later are never given again, even once the set is reset, so that an id tells one rule
+// throughout the journal and the metrics.
+type ruleSet[R any] struct {
+	initial []R
+
+	lock    sync.RWMutex
+	entries []ruleEntry[R]
+	nextId  int
+}
+
+type ruleEntry[R any] struct {
+	id      int
+	rule    R
+	enabled bool
+	// initial tells that rule is the rule of the config with the id
+	initial bool
+	// description is what the rule was made from by the admin API, or nil if it was made in code
+	description any
+}
+
+// resetter is a rule that keeps state as it is matched, such as a ws.Resetter or an http.Resetter
+type resetter interface {
+	Reset()
+}
+
+func newRuleSet[R any](rules []R) *ruleSet[R] {
+	s := &ruleSet[R]{initial: rules, nextId: len(rules)}
+	s.entries = s.initialEntries()
+	return s
+}
+
+// reset puts the rules of the config back, as they were when the simulator was made.
+// Every rule that was in the set, or is put back, is reset as well, e.g. stopping its subscriptions.
+func (s *ruleSet[R]) reset() {
+	s.lock.Lock()
+	entries := s.entries
+	s.entries = s.initialEntries()
+	s.lock.Unlock()
+
+	for _, e := range entries {
+		if r, ok := any(e.rule).(resetter); ok && !e.initial {
+			r.Reset()
+		}
+	}
+	for _, rule := range s.initial {
+		if r, ok := any(rule).(resetter); ok {
+			r.Reset()
+		}
+	}
+}
+
+func (s *ruleSet[R]) initialEntries() []ruleEntry[R] {
+	entries := make([]ruleEntry[R], 0, len(s.initial))
+	for i, rule := range s.initial {
+		entries = append(entries, ruleEntry[R]{id: i, rule: rule, enabled: true, initial: true})
+	}
+	return entries
+}
+
+// find returns the first enabled rule that match tells, with its id
+func (s *ruleSet[R]) find(match func(R) bool) (int, R, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, e := range s.entries {
+		if e.enabled && match(e.rule) {
+			return e.id, e.rule, true
+		}
+	}
+	var zero R
+	return -1, zero, false
+}
+
+// rules returns the enabled rules
+func (s *ruleSet[R]) rules() []R {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	rules := make([]R, 0, len(s.entries))
+	for _, e := range s.entries {
+		if e.enabled {
+			rules = append(rules, e.rule)
+		}
+	}
+	return rules
+}
+
+// list returns every rule, enabled or not
+func (s *ruleSet[R]) list() []ruleEntry[R] {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return slices.Clone(s.entries)
+}
+
+// add puts rule at index, or last if index is out of range, and returns its id
+func (s *ruleSet[R]) add(rule R, description any, index int) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	id := s.nextId
+	s.nextId++
+	if index < 0 || index > len(s.entries) {
+		index = len(s.entries)
+	}
+	s.entries = slices.Insert(s.entries, index, ruleEntry[R]{id: id, rule: rule, enabled: true, description: description})
+	return id
+}
+
+// replace puts rule in place of the rule with id, keeping its id, position and whether it is enabled
+func (s *ruleSet[R]) replace(id int, rule R, description any) bool {
+	return s.update(id, func(e *ruleEntry[R]) {
+		e.rule = rule
+		e.initial = false
+		e.description = description
+	})
+}
+
+// enable enables or disables the rule with id
+func (s *ruleSet[R]) enable(id int, enabled bool) bool {
+	return s.update(id, func(e *ruleEntry[R]) {
+		e.enabled = enabled
+	})
+}
+
+func (s *ruleSet[R]) update(id int, f func(*ruleEntry[R])) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	i := slices.IndexFunc(s.entries, func(e ruleEntry[R]) bool { return e.id == id })
+	if i == -1 {
+		return false
+	}
+	f(&s.entries[i])
+	return true
+}
+
+// remove removes the rule with id
+func (s *ruleSet[R]) remove(id int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	n := len(s.entries)
+	s.entries = slices.DeleteFunc(s.entries, func(e ruleEntry[R]) bool { return e.id == id })
+	return len(s.entries) < n
+}
@@ -9,7 +9,74 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
-func TestConfig_GetHttpRule(t *testing.T) {
+func TestRuleSet(t *testing.T) {
+	ids := func(s *ruleSet[string]) []int {
+		var ids []int
+		for _, e := range s.list() {
+			ids = append(ids, e.id)
+		}
+		return ids
+	}
+	find := func(s *ruleSet[string], prefix byte) (int, string, bool) {
+		return s.find(func(r string) bool { return r[0] == prefix })
+	}
+
+	s := newRuleSet([]string{"a0", "b1"})
+	assert.Equal(t, []int{0, 1}, ids(s))
+
+	assert.Equal(t, 2, s.add("a2", "description", 0))
+	assert.Equal(t, 3, s.add("c3", nil, -1))
+	assert.Equal(t, 4, s.add("c4", nil, 100))
+	assert.Equal(t, []int{2, 0, 1, 3, 4}, ids(s))
+	id, rule, ok := find(s, 'a')
+	assert.Equal(t, 2, id)
+	assert.Equal(t, "a2", rule)
+	assert.True(t, ok)
+
+	assert.True(t, s.enable(2, false))
+	id, rule, _ = find(s, 'a')
+	assert.Equal(t, 0, id)
+	assert.Equal(t, "a0", rule)
+	assert.Equal(t, []string{"a0", "b1", "c3", "c4"}, s.rules())
+
+	assert.True(t, s.replace(3, "b3", nil))
+	assert.True(t, s.remove(1))
+	assert.False(t, s.remove(1))
+	assert.False(t, s.enable(1, true))
+	id, rule, _ = find(s, 'b')
+	assert.Equal(t, 3, id)
+	assert.Equal(t, "b3", rule)
+	id, _, ok = find(s, 'd')
+	assert.Equal(t, -1, id)
+	assert.False(t, ok)
+
+	s.reset()
+	assert.Equal(t, []int{0, 1}, ids(s))
+	assert.Equal(t, []string{"a0", "b1"}, s.rules())
+	assert.Equal(t, 5, s.add("a5", nil, 0))
+}
+
+type resettableRule struct {
+	resets *int
+}
+
+func (r resettableRule) Reset() {
+	*r.resets++
+}
+
+func TestRuleSet_reset(t *testing.T) {
+	var initialResets, addedResets int
+	s := newRuleSet([]any{resettableRule{&initialResets}, "a1"})
+	s.add(resettableRule{&addedResets}, nil, -1)
+	s.replace(0, "a0", nil)
+
+	s.reset()
+	assert.Equal(t, 1, initialResets)
+	assert.Equal(t, 1, addedResets)
+	assert.Len(t, s.list(), 2)
+}
+
+func TestRuleSet_find_Http(t *testing.T) {
 	mockRule1 := http.NewMockRule(t)
 	mockRule1.On("MatchRequest", HttpRequest{Method: "GET", Path: "/users"}).Return(true)
 	mockRule1.On("MatchRequest", mock.Anything).Return(false)
@@ -18,10 +85,7 @@ func TestConfig_GetHttpRule(t *testing.T) {
 	mockRule2.On("MatchRequest", HttpRequest{Method: "POST", Path: "/users"}).Return(true)
 	mockRule2.On("MatchRequest", mock.Anything).Return(false)
 
-	config := Config{
-		HttpBasePath: "/api",
-		HttpRules:    []HttpRule{mockRule1, mockRule2},
-	}
+	rules := newRuleSet([]HttpRule{mockRule1, mockRule2})
 
 	tests := []struct {
 		name         string
@@ -37,14 +101,14 @@ func TestConfig_GetHttpRule(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			rule, ok := config.GetHttpRule(tt.request)
+			_, rule, ok := rules.find(func(r HttpRule) bool { return r.MatchRequest(tt.request) })
 			assert.Equal(t, tt.expectedRule, rule)
 			assert.Equal(t, tt.expectedOk, ok)
 		})
 	}
 }
 
-func TestConfig_GetWsRule(t *testing.T) {
+func TestRuleSet_find_Ws(t *testing.T) {
 	mockRule1 := ws.NewMockRule(t)
 	mockRule1.On("MatchMessage", WsMessage{Type: WsMessageText, Data: []byte("ping")}).Return(true)
 	mockRule1.On("MatchMessage", mock.Anything).Return(false)
@@ -53,9 +117,7 @@ func TestConfig_GetWsRule(t *testing.T) {
 	mockRule2.On("MatchMessage", WsMessage{Type: WsMessageBinary, Data: []byte("pong")}).Return(true)
 	mockRule2.On("MatchMessage", mock.Anything).Return(false)
 
-	config := Config{
-		WsRules: []WsRule{mockRule1, mockRule2},
-	}
+	rules := newRuleSet([]WsRule{mockRule1, mockRule2})
 
 	tests := []struct {
 		name         string
@@ -71,7 +133,7 @@ func TestConfig_GetWsRule(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			rule, ok := config.GetWsRule(tt.message)
+			_, rule, ok := rules.find(func(r WsRule) bool { return r.MatchMessage(tt.message) })
 			assert.Equal(t, tt.expectedRule, rule)
 			assert.Equal(t, tt.expectedOk, ok)
 		})
@@ -2,36 +2,115 @@ package simulator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"alphanonce.com/exchangesimulator/internal/log"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/metrics"
 	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
 
 	"github.com/coder/websocket"
 )
 
 type Simulator struct {
-	config Config
+	config          Config
+	clock           Clock
+	connectionCount *atomic.Uint64
+	connections     *wsConnections
+	sessionRecorder *ws.SessionRecorder
+	recordWriter    *ws.RecordWriter
+	faultInjector   ws.FaultInjector
+	ipConnections   *wsIpConnections
+	outages         *outageSchedule
+	httpRules       *ruleSet[HttpRule]
+	wsRules         *ruleSet[WsRule]
+	admin           http.Handler
+	journal         *journal
+	metrics         *metrics.Registry
 }
 
 func New(config Config) Simulator {
-	return Simulator{
-		config: config,
+	s := Simulator{
+		config:          config,
+		clock:           config.Clock,
+		connectionCount: &atomic.Uint64{},
+		connections:     newWsConnections(),
+		faultInjector:   ws.NewFaultInjector(config.WsFault),
+		ipConnections:   newWsIpConnections(),
+		httpRules:       newRuleSet(config.HttpRules),
+		wsRules:         newRuleSet(config.WsRules),
+		journal:         newJournal(config.JournalSize),
+		metrics:         metrics.NewRegistry(),
 	}
+	if s.clock == nil {
+		s.clock = RealClock{}
+	}
+	s.outages = newOutageSchedule(config.Outages, s.clock.Now())
+	s.admin = s.newAdminHandler(config.AdminBasePath)
+	if config.WsSessionRecordPath != "" {
+		s.sessionRecorder = ws.NewSessionRecorder(config.WsSessionRecordPath)
+	}
+	if config.WsRecordFile != "" {
+		s.recordWriter = ws.NewRecordWriter(config.WsRecordFile)
+	}
+	return s
 }
 
 func (s Simulator) Run() error {
+	defer s.Close()
 	return http.ListenAndServe(s.config.ServerAddress, http.HandlerFunc(s.requestHandler))
 }
 
+// Close closes the files the simulator records to, WsRecordFile and WsSessionRecordPath.
+// Whatever was recorded is readable before, but closing releases the files.
+func (s Simulator) Close() error {
+	var errs []error
+	if s.sessionRecorder != nil {
+		errs = append(errs, s.sessionRecorder.Close())
+	}
+	if s.recordWriter != nil {
+		errs = append(errs, s.recordWriter.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// WsBacklogs tells how well every open WebSocket connection keeps up with the messages written to it
+func (s Simulator) WsBacklogs() []WsBacklog {
+	return s.connections.backlogs()
+}
+
+// StartOutage takes the exchange down as outage tells for duration, whatever the scheduled outages tell.
+// Duration 0 stands for until EndOutage is called.
+func (s Simulator) StartOutage(outage Outage, duration time.Duration) {
+	var end time.Time
+	if duration > 0 {
+		end = s.clock.Now().Add(duration)
+	}
+	logger.Info("Outage started", log.String("scope", string(outage.Scope)), log.Duration("duration", duration))
+	s.outages.start(outage, end)
+}
+
+// EndOutage ends the outage started by StartOutage. The scheduled outages go on as scheduled.
+func (s Simulator) EndOutage() {
+	logger.Info("Outage ended")
+	s.outages.end()
+}
+
 func (s Simulator) requestHandler(w http.ResponseWriter, r *http.Request) {
-	if s.config.HttpBasePath != "" && strings.HasPrefix(r.URL.Path, s.config.HttpBasePath) {
+	if s.config.AdminBasePath != "" && strings.HasPrefix(r.URL.Path, s.config.AdminBasePath) {
+		s.admin.ServeHTTP(w, r)
+	} else if s.config.MetricsPath != "" && r.URL.Path == s.config.MetricsPath {
+		s.metricsHandler(w, r)
+	} else if s.config.HttpBasePath != "" && strings.HasPrefix(r.URL.Path, s.config.HttpBasePath) {
 		s.httpRequestHandler(w, r)
 	} else if s.config.WsEndpoint != "" && r.URL.Path == s.config.WsEndpoint {
 		s.wsRequestHandler(w, r)
@@ -53,6 +132,7 @@ func (s Simulator) httpRequestHandler(w http.ResponseWriter, r *http.Request) {
 		log.Any("request", request),
 	)
 
+	request = request.WithContext(s.newContext(r.Context()))
 	response, err := s.simulateHttpResponse(request)
 	if err != nil {
 		logger.Error("TODO", log.Any("error", err))
@@ -60,7 +140,11 @@ func (s Simulator) httpRequestHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	convertHttpResponse(w, response)
+	err = s.writeHttpResponse(r.Context(), w, response)
+	if err != nil {
+		logger.Info("Stopped writing a HTTP response", log.Any("error", err))
+		return
+	}
 
 	logger.Debug(
 		"Completed a HTTP request",
@@ -69,17 +153,45 @@ func (s Simulator) httpRequestHandler(w http.ResponseWriter, r *http.Request) {
 	)
 }
 
+// simulateHttpResponse responds to request, journaling it as it arrives and filling in the response once it is made
 func (s Simulator) simulateHttpResponse(request HttpRequest) (HttpResponse, error) {
-	rule, ok := s.config.GetHttpRule(request)
+	now := s.clock.Now()
+	ruleId, responder := s.httpResponder(request, now)
+	seq := s.journalHttp(request, ruleId, now)
+	response, err := responder.Response(request)
+	if err == nil {
+		s.journalHttpResponse(seq, response)
+	}
+	s.countHttp(ruleId, response, err)
+	return response, err
+}
+
+// httpResponder returns what responds to request as the outage on at now or the first rule it matches tells,
+// with the id of the rule, or -1 if no rule responds
+func (s Simulator) httpResponder(request HttpRequest, now time.Time) (int, HttpResponder) {
+	if outage, ok := s.outages.current(now, OutageScopeHttp); ok {
+		return -1, httpResponderFunc(outage.response)
+	}
+
+	ruleId, rule, ok := s.httpRules.find(func(r HttpRule) bool { return r.MatchRequest(request) })
 	if !ok {
-		response := HttpResponse{
-			StatusCode: http.StatusNotFound,
-			Body:       []byte("Invalid request"),
-		}
-		return response, nil
+		s.metrics.Add(metrics.HttpUnmatchedRequests, 1)
+		return -1, httpResponderFunc(func(HttpRequest) (HttpResponse, error) {
+			response := HttpResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       []byte("Invalid request"),
+			}
+			return response, nil
+		})
 	}
+	return ruleId, rule
+}
+
+// httpResponderFunc responds to a request by calling itself
+type httpResponderFunc func(HttpRequest) (HttpResponse, error)
 
-	return rule.Response(request)
+func (f httpResponderFunc) Response(request HttpRequest) (HttpResponse, error) {
+	return f(request)
 }
 
 func convertHttpRequest(r *http.Request, basePath string) (HttpRequest, error) {
@@ -98,57 +210,193 @@ func convertHttpRequest(r *http.Request, basePath string) (HttpRequest, error) {
 		QueryString: r.URL.RawQuery,
 		Header:      r.Header,
 		Body:        bodyBytes,
+		RemoteAddr:  r.RemoteAddr,
 	}
 	return request, nil
 }
 
 func convertHttpResponse(w http.ResponseWriter, response HttpResponse) {
-	w.WriteHeader(response.StatusCode)
+	writeHttpHeader(w, response)
 	w.Write(response.Body)
 }
 
+func writeHttpHeader(w http.ResponseWriter, response HttpResponse) {
+	for key, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(response.StatusCode)
+}
+
+// writeHttpResponse writes response as its delivery tells, trickling the body on the simulator clock
+// and resetting the connection midway if it is to be reset
+func (s Simulator) writeHttpResponse(ctx context.Context, w http.ResponseWriter, response HttpResponse) error {
+	delivery := response.Delivery
+	if delivery == (HttpDelivery{}) {
+		convertHttpResponse(w, response)
+		return nil
+	}
+
+	body := response.Body
+	end := len(body)
+	if delivery.Reset {
+		end = min(max(0, delivery.ResetAfter), len(body))
+	}
+	chunkSize := delivery.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = max(1, end)
+	}
+
+	controller := http.NewResponseController(w)
+	writeHttpHeader(w, response)
+	for i := 0; i < end; i += chunkSize {
+		if i > 0 {
+			err := clock.Sleep(ctx, s.clock, delivery.ChunkInterval)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := w.Write(body[i:min(i+chunkSize, end)])
+		if err != nil {
+			return err
+		}
+		err = controller.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	if !delivery.Reset {
+		return nil
+	}
+	conn, _, err := controller.Hijack()
+	if err != nil {
+		return fmt.Errorf("failed to reset the connection: %w", err)
+	}
+	prepareReset(conn)
+	conn.Close()
+	return errors.New("reset the connection")
+}
+
 func (s Simulator) wsRequestHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithCancel(r.Context())
-	defer cancel()
+	ctx, cancel := context.WithCancelCause(s.newContext(r.Context()))
+	defer cancel(nil)
+
+	if _, ok := s.outages.current(s.clock.Now(), OutageScopeWs); ok {
+		logger.Info("Refused a WebSocket connection during an outage", log.String("remote_addr", r.RemoteAddr))
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	release, ok := s.ipConnections.acquire(r.RemoteAddr, s.config.WsLimits.MaxConnectionsPerIp)
+	if !ok {
+		logger.Info("Refused a WebSocket connection over the limit of its IP address", log.String("remote_addr", r.RemoteAddr))
+		http.Error(w, "Too many connections", http.StatusTooManyRequests)
+		return
+	}
+	defer release()
 
-	conn, err := websocket.Accept(w, r, nil)
+	hijacked := &hijackRecorder{ResponseWriter: w}
+	conn, err := websocket.Accept(hijacked, r, nil)
 	if err != nil {
 		logger.Error("Error upgrading to WebSocket", log.Any("error", err))
 		http.Error(w, "Failed to upgrade to WebSocket", http.StatusInternalServerError)
 		return
 	}
 	defer conn.Close(websocket.StatusNormalClosure, "")
-	logger.Info("Succeeded upgrading to WebSocket")
-	connClient := wrapConnection(conn)
+	connectionId := s.connectionCount.Add(1)
+	logger.Info("Succeeded upgrading to WebSocket", log.Uint64("connection_id", connectionId))
+	s.metrics.Add(metrics.WsConnections, 1, s.config.WsEndpoint)
+	var connClient WsConnection = wrapConnection(conn)
+	if s.sessionRecorder != nil {
+		s.recordSessionEvent(ws.SessionEvent{ConnectionId: connectionId, Kind: ws.SessionEventOpen})
+		connClient = newSessionRecordingConnection(connClient, s.sessionRecorder, s.clock, connectionId, ws.DirectionClientToServer)
+	}
+	s.journalWs(JournalWsOpen, connectionId, -1, WsMessage{})
+	defer s.journalWs(JournalWsClose, connectionId, -1, WsMessage{})
+	connClient = journalingConnection{WsConnection: connClient, simulator: s, connectionId: connectionId}
+	connClient = metricsConnection{WsConnection: connClient, metrics: s.metrics, endpoint: s.config.WsEndpoint}
+	closeConn := func(closure WsClosure) {
+		if closure.Reset {
+			prepareReset(hijacked.conn)
+			conn.CloseNow()
+			return
+		}
+		code := websocket.StatusCode(closure.Code)
+		if code == 0 {
+			code = websocket.StatusGoingAway
+		}
+		conn.Close(code, closure.Reason)
+	}
+	queue := newWsWriteQueue(ctx, cancel, connClient, connectionId, closeConn, s.config)
+	s.connections.add(connectionId, queue)
+	defer s.connections.remove(connectionId)
+	connClient = queue
+	if s.config.WsFault != (WsConnectionFault{}) {
+		connClient = s.faultInjector.Inject(ctx, connClient)
+	}
+	go s.closeWsOnOutage(ctx, connClient, connectionId)
 
 	var connServer WsConnection
 	if s.config.WsRedirectUrl != "" {
+		start := time.Now()
 		conn, _, err := websocket.Dial(ctx, s.config.WsRedirectUrl, nil)
 		if err != nil {
+			s.metrics.Add(metrics.RedirectErrors, 1, "ws")
 			logger.Error("Error connecting to WebSocket server", log.String("url", s.config.WsRedirectUrl), log.Any("error", err))
 			http.Error(w, "Failed to connect to WebSocket server", http.StatusInternalServerError)
 			return
 		}
 		defer conn.Close(websocket.StatusNormalClosure, "")
+		s.metrics.Observe(metrics.RedirectDuration, time.Since(start).Seconds(), "ws")
 		logger.Info("Succeeded connecting to WebSocket", log.String("url", s.config.WsRedirectUrl))
 		connServer = wrapConnection(conn)
+		if s.sessionRecorder != nil {
+			connServer = newSessionRecordingConnection(connServer, s.sessionRecorder, s.clock, connectionId, ws.DirectionServerToClient)
+		}
 
 		go func() {
 			err := s.redirectWsMessageFromServerToClient(ctx, connClient, connServer)
 			if err != nil {
+				if ctx.Err() == nil {
+					s.metrics.Add(metrics.RedirectErrors, 1, "ws")
+				}
 				logger.Error("Error redirecting messages from server", log.Any("error", err))
-				cancel()
+				cancel(err)
 			}
 		}()
 	}
 
-	err = s.handleWsConnection(ctx, connClient, connServer)
+	err = s.runWsOnConnect(ctx, connClient, connServer)
+	if err != nil {
+		logger.Error("Error greeting the WebSocket client", log.Any("error", err))
+		return
+	}
+	s.startWsPeriodic(ctx, cancel, connClient, connServer, conn.Ping, closeConn)
+
+	err = s.handleWsConnection(ctx, cancel, connectionId, connClient, connServer)
 	if err != nil {
 		logger.Error("Error handling websocket messages", log.Any("error", err))
 		return
 	}
 }
 
+// closeWsOnOutage closes connClient once an outage starts, after the messages written to it before
+func (s Simulator) closeWsOnOutage(ctx context.Context, connClient WsConnection, connectionId uint64) {
+	// An outage is not what a stepped clock steps to
+	outage, err := s.outages.wait(ctx, clock.Background(s.clock), OutageScopeWs)
+	if err != nil {
+		return
+	}
+
+	logger.Info("Closing a WebSocket connection for an outage", log.Uint64("connection_id", connectionId))
+	err = ws.CloseConnection(connClient, outage.WsClosure)
+	if err != nil {
+		logger.Error("Error closing a WebSocket connection for an outage", log.Any("error", err))
+	}
+}
+
 func (s Simulator) redirectWsMessageFromServerToClient(ctx context.Context, connClient WsConnection, connServer WsConnection) error {
 	for {
 		message, err := connServer.Read(ctx)
@@ -163,6 +411,13 @@ func (s Simulator) redirectWsMessageFromServerToClient(ctx context.Context, conn
 			}
 		}
 
+		if s.recordWriter != nil {
+			err = s.recordWriter.Write(ws.Record{Time: s.clock.Now(), Message: message})
+			if err != nil {
+				return fmt.Errorf("failed to append to the record file: %w", err)
+			}
+		}
+
 		err = connClient.Write(ctx, message)
 		if err != nil {
 			return fmt.Errorf("failed to write to client: %w", err)
@@ -176,7 +431,7 @@ func (s Simulator) saveMessageToFile(message WsMessage, dir string) error {
 		return err
 	}
 
-	filename := time.Now().Format(time.RFC3339Nano) + ".yaml"
+	filename := s.clock.Now().Format(time.RFC3339Nano) + ".yaml" + s.config.WsRecordCompression
 	path := filepath.Join(dir, filename)
 
 	err = ws.WriteToFile(path, message)
@@ -188,23 +443,50 @@ func (s Simulator) saveMessageToFile(message WsMessage, dir string) error {
 	return nil
 }
 
-func (s Simulator) handleWsConnection(ctx context.Context, connClient WsConnection, connServer WsConnection) error {
+// handleWsConnection reads the messages of a connection and handles them concurrently as their rules tell,
+// until reading fails or ctx is cancelled, e.g. as a message fails to be handled.
+// Every message is journaled with the rule it is dispatched to.
+func (s Simulator) handleWsConnection(ctx context.Context, cancel context.CancelCauseFunc, connectionId uint64, connClient WsConnection, connServer WsConnection) error {
+	var subscriptionLock sync.Mutex
+	handle := func(ctx context.Context, rule WsRule, message WsMessage) error {
+		return s.handleWsSubscriptions(ctx, &subscriptionLock, rule, message, connClient, connServer)
+	}
+	d := newWsDispatcher(ctx, cancel, handle, s.config.WsWriteBufferSize)
+	rate := newWsMessageRate(s.config.WsLimits)
 	for {
 		incomingMsg, err := connClient.Read(ctx)
 		if err != nil {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
 			return fmt.Errorf("failed to read message: %w", err)
 		}
 
-		err = s.simulateWsResponse(ctx, incomingMsg, connClient, connServer)
+		limit, reaction, exceeded := s.checkWsMessageRate(rate)
+		if exceeded {
+			s.journalWs(JournalWsInbound, connectionId, -1, incomingMsg)
+			err = reaction.react(ctx, limit, incomingMsg, connClient, connServer)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		ruleId, rule, _ := s.wsRules.find(func(r WsRule) bool { return r.MatchMessage(incomingMsg) })
+		s.journalWs(JournalWsInbound, connectionId, ruleId, incomingMsg)
+		if ruleId == -1 {
+			s.metrics.Add(metrics.WsUnmatchedMessages, 1, s.config.WsEndpoint)
+		}
+		err = d.dispatch(wsDispatch{ruleId: ruleId, rule: rule, message: incomingMsg})
 		if err != nil {
-			return fmt.Errorf("failed to handle message: %w", err)
+			return err
 		}
 	}
 }
 
-func (s Simulator) simulateWsResponse(ctx context.Context, message WsMessage, connClient WsConnection, connServer WsConnection) error {
-	rule, ok := s.config.GetWsRule(message)
-	if !ok {
+// respondWsWithRule handles message with rule, or tells the client the message is invalid if rule is nil
+func (s Simulator) respondWsWithRule(ctx context.Context, rule WsRule, message WsMessage, connClient WsConnection, connServer WsConnection) error {
+	if rule == nil {
 		response := WsMessage{
 			Type: WsMessageText,
 			Data: []byte("Invalid message"),
@@ -2,9 +2,13 @@ package simulator
 
 import (
 	"context"
+	"errors"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/http"
 	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
@@ -57,7 +61,54 @@ func TestSimulator_simulateHttpResponse(t *testing.T) {
 	}
 }
 
-func TestSimulator_simulateWsResponse(t *testing.T) {
+func TestSimulator_httpRequestHandler_Clock(t *testing.T) {
+	c := NewFakeClock(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC))
+	config := Config{
+		HttpBasePath: "/api",
+		HttpRules: []HttpRule{
+			NewHttpRule(NewHttpRequestPredicate("GET", "/test"), NewHttpResponseFromString(200, "OK", time.Hour)),
+		},
+		Clock: c,
+	}
+	sim := New(config)
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		sim.httpRequestHandler(w, httptest.NewRequest("GET", "/api/test", nil))
+		close(done)
+	}()
+	require.NoError(t, c.WaitForSleepers(context.Background(), 1))
+	c.Advance(time.Hour)
+	<-done
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "OK", w.Body.String())
+}
+
+func TestSimulator_httpRequestHandler_RateLimit(t *testing.T) {
+	limiter := NewHttpRateLimiter(HttpRateLimit{Window: time.Minute, Limit: 1, Header: "X-MBX-USED-WEIGHT-1M"})
+	config := Config{
+		HttpBasePath: "/api",
+		HttpRules: []HttpRule{
+			NewHttpRule(NewHttpRequestPredicate("GET", "/test"), limiter.Limit(NewHttpResponseFromString(200, "OK", 0), 1)),
+		},
+		Clock: NewFakeClock(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)),
+	}
+	sim := New(config)
+
+	var statusCodes []int
+	for range 2 {
+		w := httptest.NewRecorder()
+		sim.httpRequestHandler(w, httptest.NewRequest("GET", "/api/test", nil))
+		statusCodes = append(statusCodes, w.Code)
+		assert.NotEmpty(t, w.Header().Get("X-MBX-USED-WEIGHT-1M"))
+	}
+
+	assert.Equal(t, []int{200, 429}, statusCodes)
+}
+
+func TestSimulator_respondWsWithRule(t *testing.T) {
 	mockPingpongRule := ws.NewMockRule(t)
 	mockPingpongRule.On("MatchMessage", WsMessage{Type: WsMessageText, Data: []byte("ping")}).Return(true)
 	mockPingpongRule.On("MatchMessage", mock.Anything).Return(false)
@@ -112,7 +163,8 @@ func TestSimulator_simulateWsResponse(t *testing.T) {
 			mockConnServer := ws.NewMockConnection(t)
 			mockConnServer.On("Write", ctx, tt.expectedMessageServer).Maybe().Return(nil)
 
-			err := sim.simulateWsResponse(ctx, tt.message, mockConnClient, mockConnServer)
+			_, rule, _ := sim.wsRules.find(func(r WsRule) bool { return r.MatchMessage(tt.message) })
+			err := sim.respondWsWithRule(ctx, rule, tt.message, mockConnClient, mockConnServer)
 			assert.NoError(t, err)
 		})
 	}
@@ -155,3 +207,80 @@ func TestSimulator_saveMessageToFile(t *testing.T) {
 		})
 	}
 }
+
+func TestSimulator_saveMessageToFile_Compressed(t *testing.T) {
+	for _, compression := range []string{CompressionGzip, CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			tempDir := t.TempDir()
+			message := WsMessage{Type: WsMessageText, Data: []byte("Hello, World!")}
+
+			sim := New(Config{WsRecordDir: tempDir, WsRecordCompression: compression})
+
+			err := sim.saveMessageToFile(message, tempDir)
+			assert.NoError(t, err)
+
+			files, err := os.ReadDir(tempDir)
+			assert.NoError(t, err)
+			require.Len(t, files, 1)
+			assert.True(t, strings.HasSuffix(files[0].Name(), ".yaml"+compression))
+
+			read, err := ws.ReadFromFile(filepath.Join(tempDir, files[0].Name()))
+			assert.NoError(t, err)
+			assert.Equal(t, message, read)
+		})
+	}
+}
+
+func TestSimulator_redirectWsMessageFromServerToClient(t *testing.T) {
+	tempDir := t.TempDir()
+	recordFile := filepath.Join(tempDir, "records.jsonl")
+	sim := New(Config{WsRecordFile: recordFile})
+
+	message := WsMessage{Type: WsMessageText, Data: []byte("update")}
+	ctx := context.Background()
+
+	mockConnServer := ws.NewMockConnection(t)
+	mockConnServer.On("Read", ctx).Return(message, nil).Once()
+	mockConnServer.On("Read", ctx).Return(WsMessage{}, errors.New("closed")).Once()
+	mockConnClient := ws.NewMockConnection(t)
+	mockConnClient.On("Write", ctx, message).Return(nil).Once()
+
+	err := sim.redirectWsMessageFromServerToClient(ctx, mockConnClient, mockConnServer)
+	assert.ErrorContains(t, err, "failed to read from server")
+
+	records, err := ws.ReadRecordsFromFile(recordFile)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, message, records[0].Message)
+}
+
+func TestSimulator_Close(t *testing.T) {
+	tempDir := t.TempDir()
+	recordFile := filepath.Join(tempDir, "records.jsonl.gz")
+	sessionFile := filepath.Join(tempDir, "session.jsonl.zst")
+	sim := New(Config{WsRecordFile: recordFile, WsSessionRecordPath: sessionFile})
+
+	message := WsMessage{Type: WsMessageText, Data: []byte("update")}
+	ctx := context.Background()
+
+	mockConnServer := ws.NewMockConnection(t)
+	mockConnServer.On("Read", ctx).Return(message, nil).Once()
+	mockConnServer.On("Read", ctx).Return(WsMessage{}, errors.New("closed")).Once()
+	mockConnClient := ws.NewMockConnection(t)
+	mockConnClient.On("Write", ctx, message).Return(nil).Once()
+
+	err := sim.redirectWsMessageFromServerToClient(ctx, mockConnClient, mockConnServer)
+	assert.ErrorContains(t, err, "failed to read from server")
+	sim.recordSessionEvent(ws.SessionEvent{ConnectionId: 1, Kind: ws.SessionEventOpen})
+	require.NoError(t, sim.Close())
+
+	records, err := ws.ReadRecordsFromFile(recordFile)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, message, records[0].Message)
+
+	events, err := ws.ReadSessionFromFile(sessionFile)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, ws.SessionEventOpen, events[0].Kind)
+}
@@ -0,0 +1,66 @@
+package simulator
+
+import (
+	"slices"
+	"sync"
+)
+
+// wsConnections are the open WebSocket connections of a simulator, by their id
+type wsConnections struct {
+	lock   sync.Mutex
+	queues map[uint64]*wsWriteQueue
+}
+
+func newWsConnections() *wsConnections {
+	return &wsConnections{queues: map[uint64]*wsWriteQueue{}}
+}
+
+func (c *wsConnections) add(connectionId uint64, q *wsWriteQueue) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.queues[connectionId] = q
+}
+
+func (c *wsConnections) remove(connectionId uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.queues, connectionId)
+}
+
+func (c *wsConnections) get(connectionId uint64) (*wsWriteQueue, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	q, ok := c.queues[connectionId]
+	return q, ok
+}
+
+// all returns every connection, in the order they were opened
+func (c *wsConnections) all() []*wsWriteQueue {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ids := make([]uint64, 0, len(c.queues))
+	for id := range c.queues {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	queues := make([]*wsWriteQueue, 0, len(ids))
+	for _, id := range ids {
+		queues = append(queues, c.queues[id])
+	}
+	return queues
+}
+
+// backlogs returns the backlog of every connection, in the order they were opened
+func (c *wsConnections) backlogs() []WsBacklog {
+	queues := c.all()
+	backlogs := make([]WsBacklog, 0, len(queues))
+	for _, q := range queues {
+		backlogs = append(backlogs, q.Backlog())
+	}
+	return backlogs
+}
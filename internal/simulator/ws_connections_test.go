@@ -0,0 +1,21 @@
+package simulator
+
+import (
+	"context"
+	"testing"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWsConnections_backlogs(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	c := newWsConnections()
+	for _, id := range []uint64{3, 1, 2} {
+		c.add(id, newWsWriteQueue(ctx, cancel, ws.NewMockConnection(t), id, nil, Config{}))
+	}
+	c.remove(2)
+
+	assert.Equal(t, []WsBacklog{{ConnectionId: 1}, {ConnectionId: 3}}, c.backlogs())
+}
@@ -0,0 +1,95 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+)
+
+// wsDispatcher handles the messages of a connection concurrently, ordered as their rules tell; see WsOrdering.
+// A message that fails to be handled cancels the connection.
+type wsDispatcher struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	handle func(context.Context, WsRule, WsMessage) error
+	size   int
+
+	lock sync.Mutex
+	// queues are the messages of each sequential rule waiting to be handled, by the id of the rule,
+	// or -1 for the messages no rule matches
+	queues map[int]chan wsDispatch
+}
+
+// wsDispatch is a message waiting to be handled with the rule it matched as it was read
+type wsDispatch struct {
+	// ruleId is the id of rule, or -1 if the message matched none and rule is nil
+	ruleId  int
+	rule    WsRule
+	message WsMessage
+}
+
+func newWsDispatcher(ctx context.Context, cancel context.CancelCauseFunc, handle func(context.Context, WsRule, WsMessage) error, size int) *wsDispatcher {
+	if size <= 0 {
+		size = DefaultWsWriteBufferSize
+	}
+	return &wsDispatcher{
+		ctx:    ctx,
+		cancel: cancel,
+		handle: handle,
+		size:   size,
+		queues: map[int]chan wsDispatch{},
+	}
+}
+
+// dispatch handles m as its rule tells, and returns once the next message may be read
+func (d *wsDispatcher) dispatch(m wsDispatch) error {
+	ordering := ws.OrderingSequential
+	if m.rule != nil {
+		ordering = ws.OrderingOf(m.rule)
+	}
+
+	switch ordering {
+	case ws.OrderingBlocking:
+		d.run(m)
+	case ws.OrderingConcurrent:
+		go d.run(m)
+	default:
+		select {
+		case d.queue(m.ruleId) <- m:
+		case <-d.ctx.Done():
+		}
+	}
+	return context.Cause(d.ctx)
+}
+
+// queue returns the queue of the rule with id, starting to handle its messages if they were not yet
+func (d *wsDispatcher) queue(id int) chan wsDispatch {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	q, ok := d.queues[id]
+	if !ok {
+		q = make(chan wsDispatch, d.size)
+		d.queues[id] = q
+		go func() {
+			for {
+				select {
+				case <-d.ctx.Done():
+					return
+				case m := <-q:
+					d.run(m)
+				}
+			}
+		}()
+	}
+	return q
+}
+
+func (d *wsDispatcher) run(m wsDispatch) {
+	err := d.handle(d.ctx, m.rule, m.message)
+	if err != nil {
+		d.cancel(fmt.Errorf("failed to handle message: %w", err))
+	}
+}
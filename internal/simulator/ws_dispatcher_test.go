@@ -0,0 +1,97 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func textMessage(data string) WsMessage {
+	return WsMessage{Type: WsMessageText, Data: []byte(data)}
+}
+
+// matchedTextMessage returns the text message with data, with the first of rules it matches
+func matchedTextMessage(rules []WsRule, data string) wsDispatch {
+	message := textMessage(data)
+	id, rule, _ := newRuleSet(rules).find(func(r WsRule) bool { return r.MatchMessage(message) })
+	return wsDispatch{ruleId: id, rule: rule, message: message}
+}
+
+func TestWsDispatcher_dispatch(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	rules := []WsRule{
+		NewWsRule(NewWsMessagePredicate(WsMessageText, []byte("slow")), nil),
+		ws.NewOrderedRule(NewWsRule(NewWsMessagePredicate(WsMessageText, []byte("ping")), nil), ws.OrderingConcurrent),
+	}
+
+	// The slow messages are handled once released, and the others at once
+	release := make(chan struct{})
+	handled := make(chan string, 10)
+	handle := func(ctx context.Context, rule WsRule, message WsMessage) error {
+		if string(message.Data) == "slow" {
+			<-release
+		}
+		handled <- string(message.Data)
+		return nil
+	}
+	d := newWsDispatcher(ctx, cancel, handle, 0)
+
+	require.NoError(t, d.dispatch(matchedTextMessage(rules, "slow")))
+	require.NoError(t, d.dispatch(matchedTextMessage(rules, "slow")))
+	require.NoError(t, d.dispatch(matchedTextMessage(rules, "ping")))
+	require.NoError(t, d.dispatch(matchedTextMessage(rules, "unknown")))
+
+	// The ping and the unknown message do not wait for the slow one, and the second slow one waits for the first
+	assert.ElementsMatch(t, []string{"ping", "unknown"}, []string{<-handled, <-handled})
+	release <- struct{}{}
+	assert.Equal(t, "slow", <-handled)
+	select {
+	case <-handled:
+		t.Fatal("the second slow message was handled before it was released")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release <- struct{}{}
+	assert.Equal(t, "slow", <-handled)
+}
+
+func TestWsDispatcher_dispatch_Blocking(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	rules := []WsRule{
+		ws.NewOrderedRule(NewWsRule(NewWsMessagePredicate(WsMessageText, []byte("login")), nil), ws.OrderingBlocking),
+	}
+	handled := false
+	handle := func(ctx context.Context, rule WsRule, message WsMessage) error {
+		time.Sleep(time.Millisecond)
+		handled = true
+		return nil
+	}
+	d := newWsDispatcher(ctx, cancel, handle, 0)
+
+	err := d.dispatch(matchedTextMessage(rules, "login"))
+
+	assert.NoError(t, err)
+	assert.True(t, handled)
+}
+
+func TestWsDispatcher_dispatch_Error(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	handleErr := errors.New("handle error")
+	handle := func(ctx context.Context, rule WsRule, message WsMessage) error {
+		return handleErr
+	}
+	d := newWsDispatcher(ctx, cancel, handle, 0)
+
+	require.NoError(t, d.dispatch(matchedTextMessage(nil, "unknown")))
+	<-ctx.Done()
+
+	assert.ErrorIs(t, context.Cause(ctx), handleErr)
+	assert.ErrorIs(t, d.dispatch(matchedTextMessage(nil, "unknown")), handleErr)
+}
@@ -0,0 +1,79 @@
+package simulator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulator_wsRequestHandler_Fault(t *testing.T) {
+	tests := []struct {
+		name           string
+		fault          WsConnectionFault
+		send           string
+		expectedStatus websocket.StatusCode
+		expectedReason string
+	}{
+		{
+			name:           "Close after messages",
+			fault:          WsConnectionFault{AfterMessages: 1, Closure: WsClosure{Code: 4000, Reason: "bye"}},
+			expectedStatus: 4000,
+			expectedReason: "bye",
+		},
+		{
+			name:           "Reset after messages",
+			fault:          WsConnectionFault{AfterMessages: 1, Closure: WsClosure{Reset: true}},
+			expectedStatus: -1,
+		},
+		{
+			name:           "Close for a message",
+			send:           "close",
+			expectedStatus: websocket.StatusGoingAway,
+			expectedReason: "maintenance",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			sim := New(Config{
+				WsEndpoint: "/ws",
+				WsRules: []WsRule{
+					NewWsRule(NewWsMessagePredicate(WsMessageText, []byte("close")), NewWsCloseHandler(WsClosure{Reason: "maintenance"})),
+				},
+				WsOnConnect: []WsMessageHandler{NewWsMessageFromString(WsMessageText, "welcome", 0)},
+				WsFault:     tt.fault,
+			})
+			server := httptest.NewServer(http.HandlerFunc(sim.requestHandler))
+			defer server.Close()
+
+			conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+			require.NoError(t, err)
+			defer conn.CloseNow()
+
+			_, data, err := conn.Read(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, "welcome", string(data))
+			if tt.send != "" {
+				require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte(tt.send)))
+			}
+
+			_, _, err = conn.Read(ctx)
+			require.Error(t, err)
+			assert.Equal(t, tt.expectedStatus, websocket.CloseStatus(err))
+			var closeErr websocket.CloseError
+			if tt.expectedReason != "" && assert.ErrorAs(t, err, &closeErr) {
+				assert.Equal(t, tt.expectedReason, closeErr.Reason)
+			}
+		})
+	}
+}
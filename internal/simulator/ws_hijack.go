@@ -0,0 +1,36 @@
+package simulator
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// hijackRecorder keeps the network connection that a WebSocket is upgraded from, so that it can be reset
+type hijackRecorder struct {
+	http.ResponseWriter
+	conn net.Conn
+}
+
+func (w *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http.ResponseWriter does not implement http.Hijacker")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.conn = conn
+	return conn, rw, nil
+}
+
+// prepareReset makes closing a TCP connection reset it rather than shut it down,
+// so that the client sees it drop as it would on a network failure
+func prepareReset(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+}
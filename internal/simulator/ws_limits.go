@@ -0,0 +1,173 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+)
+
+// WsLimits limit what a client may do over WebSocket, as exchanges do. The zero value limits nothing.
+type WsLimits struct {
+	// MessageRate is how many messages a connection may send in any MessageWindow, e.g. 5 a second on Binance;
+	// 0 lets it send any number. MessageWindow 0 stands for a second.
+	// The messages over the rate are not handled, and do not count towards it.
+	MessageRate         int
+	MessageWindow       time.Duration
+	MessageRateReaction WsLimitReaction
+	// MaxSubscriptions caps the subscriptions of a connection, as counted by the rules that are subscription counters,
+	// e.g. 1024 streams on Binance; 0 lets it subscribe to any number. The messages that would subscribe over it are not handled.
+	MaxSubscriptions     int
+	SubscriptionReaction WsLimitReaction
+	// MaxConnectionsPerIp caps the connections open from an IP address at once; 0 lets it open any number.
+	// The connections over it are refused with 429 Too Many Requests.
+	MaxConnectionsPerIp int
+}
+
+// WsLimitAction tells what is done with a message that exceeds a limit
+type WsLimitAction int
+
+const (
+	// WsLimitReply answers the message with the reply of the reaction
+	WsLimitReply WsLimitAction = iota
+	// WsLimitClose closes the connection, once the messages written to it before are
+	WsLimitClose
+)
+
+// WsLimitReaction tells how a connection is treated when a message of it exceeds a limit
+type WsLimitReaction struct {
+	Action WsLimitAction
+	// Reply is handed the message by WsLimitReply, e.g. to answer it with an error; nil answers nothing
+	Reply WsMessageHandler
+	// CloseCode and CloseReason are what WsLimitClose closes the connection with. CloseCode 0 stands for 1008, policy violation.
+	CloseCode   int
+	CloseReason string
+}
+
+// react treats connClient as reaction tells for message, which exceeded limit.
+// It returns once the connection is closed if it is to be closed.
+func (reaction WsLimitReaction) react(ctx context.Context, limit string, message WsMessage, connClient WsConnection, connServer WsConnection) error {
+	if reaction.Action == WsLimitReply {
+		if reaction.Reply == nil {
+			return nil
+		}
+		return reaction.Reply.Handle(ctx, message, connClient, connServer)
+	}
+
+	code := reaction.CloseCode
+	if code == 0 {
+		code = 1008
+	}
+	err := ws.CloseConnection(connClient, WsClosure{Code: code, Reason: reaction.CloseReason})
+	if err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return fmt.Errorf("closed a connection that exceeded the %s limit: %w", limit, context.Cause(ctx))
+}
+
+// checkWsMessageRate tells whether message exceeds the message rate, and counts it towards it otherwise
+func (s Simulator) checkWsMessageRate(rate *wsMessageRate) (string, WsLimitReaction, bool) {
+	limits := s.config.WsLimits
+	if limits.MessageRate > 0 && !rate.take(s.clock.Now()) {
+		return "message rate", limits.MessageRateReaction, true
+	}
+	return "", WsLimitReaction{}, false
+}
+
+// handleWsSubscriptions handles message with rule, unless it would subscribe connClient over the cap of subscriptions,
+// in which case it reacts as the config tells. Checking the cap and subscribing happen under lock, so that the messages
+// handled concurrently or read before the previous ones are handled cannot subscribe over it together.
+func (s Simulator) handleWsSubscriptions(ctx context.Context, lock *sync.Mutex, rule WsRule, message WsMessage, connClient WsConnection, connServer WsConnection) error {
+	limits := s.config.WsLimits
+	counter, ok := ws.SubscriptionCounterOf(rule)
+	if limits.MaxSubscriptions <= 0 || !ok {
+		return s.respondWsWithRule(ctx, rule, message, connClient, connServer)
+	}
+
+	lock.Lock()
+	added := counter.NewSubscriptions(message, connClient)
+	if added > 0 && s.subscriptionCount(connClient)+added > limits.MaxSubscriptions {
+		lock.Unlock()
+		return limits.SubscriptionReaction.react(ctx, "subscription", message, connClient, connServer)
+	}
+	defer lock.Unlock()
+	return s.respondWsWithRule(ctx, rule, message, connClient, connServer)
+}
+
+// subscriptionCount returns how many subscriptions connClient has in every rule
+func (s Simulator) subscriptionCount(connClient WsConnection) int {
+	count := 0
+	for _, rule := range s.wsRules.rules() {
+		if counter, ok := ws.SubscriptionCounterOf(rule); ok {
+			count += counter.SubscriptionCount(connClient)
+		}
+	}
+	return count
+}
+
+// wsMessageRate keeps the times of the latest messages of a connection, to limit how many it sends in a sliding window
+type wsMessageRate struct {
+	limit  int
+	window time.Duration
+	times  []time.Time
+}
+
+func newWsMessageRate(limits WsLimits) *wsMessageRate {
+	window := limits.MessageWindow
+	if window <= 0 {
+		window = time.Second
+	}
+	return &wsMessageRate{limit: limits.MessageRate, window: window}
+}
+
+// take counts a message sent at now, unless the connection has sent as many as it may in the window up to now
+func (r *wsMessageRate) take(now time.Time) bool {
+	if len(r.times) >= r.limit {
+		if now.Sub(r.times[0]) < r.window {
+			return false
+		}
+		r.times = r.times[1:]
+	}
+	r.times = append(r.times, now)
+	return true
+}
+
+// wsIpConnections counts the connections open from every IP address
+type wsIpConnections struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+func newWsIpConnections() *wsIpConnections {
+	return &wsIpConnections{counts: map[string]int{}}
+}
+
+// acquire counts a connection from the IP address of remoteAddr, unless max are open from it already.
+// The returned function stops counting it.
+func (c *wsIpConnections) acquire(remoteAddr string, max int) (func(), bool) {
+	ip, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		ip = remoteAddr
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if max > 0 && c.counts[ip] >= max {
+		return nil, false
+	}
+	c.counts[ip]++
+	return sync.OnceFunc(func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		c.counts[ip]--
+		if c.counts[ip] == 0 {
+			delete(c.counts, ip)
+		}
+	}), true
+}
@@ -0,0 +1,173 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWsMessageRate_take(t *testing.T) {
+	start := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	rate := newWsMessageRate(WsLimits{MessageRate: 2})
+
+	var taken []bool
+	for _, offset := range []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, time.Second, 1050 * time.Millisecond, 1100 * time.Millisecond} {
+		taken = append(taken, rate.take(start.Add(offset)))
+	}
+
+	assert.Equal(t, []bool{true, true, false, true, false, true}, taken)
+}
+
+func TestWsIpConnections_acquire(t *testing.T) {
+	c := newWsIpConnections()
+
+	release1, ok := c.acquire("1.2.3.4:1000", 2)
+	require.True(t, ok)
+	_, ok = c.acquire("1.2.3.4:1001", 2)
+	require.True(t, ok)
+	_, ok = c.acquire("1.2.3.4:1002", 2)
+	assert.False(t, ok)
+	_, ok = c.acquire("5.6.7.8:1000", 2)
+	assert.True(t, ok)
+
+	release1()
+	release1()
+	_, ok = c.acquire("1.2.3.4:1003", 2)
+	assert.True(t, ok)
+	_, ok = c.acquire("1.2.3.4:1004", 2)
+	assert.False(t, ok)
+}
+
+// wsMethodMatcher matches the JSON messages with a method
+type wsMethodMatcher string
+
+func (m wsMethodMatcher) MatchMessage(message WsMessage) bool {
+	return strings.Contains(string(message.Data), fmt.Sprintf(`"method":%q`, string(m)))
+}
+
+func dialWsTestServer(t *testing.T, ctx context.Context, config Config) (*websocket.Conn, string) {
+	config.WsEndpoint = "/ws"
+	server := httptest.NewServer(http.HandlerFunc(New(config).requestHandler))
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	conn, _, err := websocket.Dial(ctx, url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.CloseNow() })
+	return conn, url
+}
+
+func exchange(t *testing.T, ctx context.Context, conn *websocket.Conn, request string) (string, error) {
+	require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte(request)))
+	_, data, err := conn.Read(ctx)
+	return string(data), err
+}
+
+func TestSimulator_handleWsConnection_MessageRate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	conn, _ := dialWsTestServer(t, ctx, Config{
+		WsRules: []WsRule{
+			NewWsRule(NewWsMessagePredicate(WsMessageText, []byte("ping")), NewWsMessageFromString(WsMessageText, "pong", 0)),
+		},
+		WsLimits: WsLimits{
+			MessageRate:         2,
+			MessageWindow:       time.Minute,
+			MessageRateReaction: WsLimitReaction{Reply: NewWsMessageFromString(WsMessageText, "too many requests", 0)},
+		},
+	})
+
+	var responses []string
+	for range 3 {
+		response, err := exchange(t, ctx, conn, "ping")
+		require.NoError(t, err)
+		responses = append(responses, response)
+	}
+
+	assert.Equal(t, []string{"pong", "pong", "too many requests"}, responses)
+}
+
+func TestSimulator_handleWsConnection_MaxSubscriptions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	response := NewWsMessageFromString(WsMessageText, `{"result":null}`, 0)
+	rule := NewWsTopicSubscriptionRule(
+		wsMethodMatcher("SUBSCRIBE"), response,
+		wsMethodMatcher("UNSUBSCRIBE"), response,
+		NewWsJsonTopicExtractor("params"), nil,
+	)
+	conn, _ := dialWsTestServer(t, ctx, Config{
+		WsRules: []WsRule{NewWsOrderedRule(rule, WsOrderingBlocking)},
+		WsLimits: WsLimits{
+			MaxSubscriptions:     2,
+			SubscriptionReaction: WsLimitReaction{Action: WsLimitClose, CloseCode: 4001, CloseReason: "too many streams"},
+		},
+	})
+
+	data, err := exchange(t, ctx, conn, `{"method":"SUBSCRIBE","params":["a","b"]}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"result":null}`, data)
+	data, err = exchange(t, ctx, conn, `{"method":"SUBSCRIBE","params":["b"]}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"result":null}`, data)
+	_, err = exchange(t, ctx, conn, `{"method":"SUBSCRIBE","params":["c"]}`)
+
+	var closeErr websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.StatusCode(4001), closeErr.Code)
+	assert.Equal(t, "too many streams", closeErr.Reason)
+}
+
+func TestSimulator_wsRequestHandler_MaxConnectionsPerIp(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, url := dialWsTestServer(t, ctx, Config{WsLimits: WsLimits{MaxConnectionsPerIp: 1}})
+
+	_, response, err := websocket.Dial(ctx, url, nil)
+	require.Error(t, err)
+	require.NotNil(t, response)
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+}
+
+func TestSimulator_handleWsConnection_MaxSubscriptions_Pipelined(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The subscriptions are handled slowly and in order, so that every message is read before the first is handled
+	response := NewWsMessageFromString(WsMessageText, `{"result":null}`, 10*time.Millisecond)
+	rule := NewWsTopicSubscriptionRule(
+		wsMethodMatcher("SUBSCRIBE"), response,
+		wsMethodMatcher("UNSUBSCRIBE"), response,
+		NewWsJsonTopicExtractor("params"), nil,
+	)
+	conn, _ := dialWsTestServer(t, ctx, Config{
+		WsRules: []WsRule{rule},
+		WsLimits: WsLimits{
+			MaxSubscriptions:     2,
+			SubscriptionReaction: WsLimitReaction{Reply: NewWsMessageFromString(WsMessageText, "too many streams", 0)},
+		},
+	})
+
+	for _, topic := range []string{"a", "b", "c"} {
+		require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte(fmt.Sprintf(`{"method":"SUBSCRIBE","params":[%q]}`, topic))))
+	}
+
+	var responses []string
+	for range 3 {
+		_, data, err := conn.Read(ctx)
+		require.NoError(t, err)
+		responses = append(responses, string(data))
+	}
+	assert.ElementsMatch(t, []string{`{"result":null}`, `{"result":null}`, "too many streams"}, responses)
+}
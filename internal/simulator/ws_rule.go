@@ -9,7 +9,21 @@ import (
 type WsRule = ws.Rule
 type WsConnection = ws.Connection
 type WsMessage = ws.Message
+type WsMessageHandler = ws.MessageHandler
 type WsMessageType = ws.MessageType
+type WsRecord = ws.Record
+type WsSessionEvent = ws.SessionEvent
+type WsReplayOptions = ws.ReplayOptions
+type WsTimeline = ws.Timeline
+type WsTopicExtractor = ws.TopicExtractor
+type WsTopicLister = ws.TopicLister
+type WsDuplicateSubscription = ws.DuplicateSubscription
+type WsOrdering = ws.Ordering
+type WsClosure = ws.Closure
+type WsConnectionFault = ws.ConnectionFault
+type WsChaos = ws.Chaos
+type WsSubscriptionCounter = ws.SubscriptionCounter
+type WsTopicSubscribers = ws.TopicSubscribers
 
 const (
 	WsMessageAny    = ws.MessageAny
@@ -17,12 +31,37 @@ const (
 	WsMessageBinary = ws.MessageBinary
 )
 
+const (
+	WsSessionEventOpen    = ws.SessionEventOpen
+	WsSessionEventMessage = ws.SessionEventMessage
+	WsSessionEventClose   = ws.SessionEventClose
+
+	WsDirectionClientToServer = ws.DirectionClientToServer
+	WsDirectionServerToClient = ws.DirectionServerToClient
+)
+
+const (
+	WsOrderingSequential = ws.OrderingSequential
+	WsOrderingConcurrent = ws.OrderingConcurrent
+	WsOrderingBlocking   = ws.OrderingBlocking
+)
+
+const (
+	WsDuplicateSubscriptionIgnore  = ws.DuplicateSubscriptionIgnore
+	WsDuplicateSubscriptionRestart = ws.DuplicateSubscriptionRestart
+)
+
 // Rules
 
 func NewWsRule(messageMatcher ws.MessageMatcher, messageHandler ws.MessageHandler) ws.RuleImpl {
 	return ws.RuleImpl{MessageMatcher: messageMatcher, MessageHandler: messageHandler}
 }
 
+// NewWsOrderedRule returns rule with its messages ordered as ordering tells, instead of WsOrderingSequential
+func NewWsOrderedRule(rule WsRule, ordering WsOrdering) ws.OrderedRuleImpl {
+	return ws.NewOrderedRule(rule, ordering)
+}
+
 func NewWsSubscriptionRule(
 	subscriptionMessageMatcher ws.MessageMatcher,
 	subscriptionResponse ws.MessageHandler,
@@ -37,6 +76,29 @@ func NewWsSubscriptionRule(
 	)
 }
 
+func NewWsTopicSubscriptionRule(
+	subscriptionMessageMatcher ws.MessageMatcher,
+	subscriptionResponse ws.MessageHandler,
+	unsubscriptionMessageMatcher ws.MessageMatcher,
+	unsubscriptionResponse ws.MessageHandler,
+	topicExtractor WsTopicExtractor,
+	feeds map[string]ws.MessageHandler,
+) ws.TopicSubscriptionRule {
+	return ws.NewTopicSubscriptionRule(
+		subscriptionMessageMatcher, subscriptionResponse,
+		unsubscriptionMessageMatcher, unsubscriptionResponse,
+		topicExtractor, feeds,
+	)
+}
+
+func NewWsSubscriptionRulesFromSession(events []WsSessionEvent) []WsRule {
+	var rules []WsRule
+	for _, r := range ws.DeriveSubscriptionRules(events) {
+		rules = append(rules, r)
+	}
+	return rules
+}
+
 // MessageMatchers
 
 func NewWsMessagePredicate(messageType WsMessageType, data []byte) ws.MessagePredicate {
@@ -47,6 +109,17 @@ func NewWsJsonMatcher(jsonString string) ws.JsonMessageMatcher {
 	return ws.NewJsonMessageMatcher(jsonString)
 }
 
+// Topics
+
+func NewWsJsonTopicExtractor(path string) ws.JsonTopicExtractor {
+	return ws.NewJsonTopicExtractor(path)
+}
+
+// WsJsonTopicList lists the topics of a connection in the JSON an exchange like Binance does
+func WsJsonTopicList(request WsMessage, topics []string) (WsMessage, error) {
+	return ws.JsonTopicList(request, topics)
+}
+
 // MessageHandlers
 
 func NewWsMessageFromString(messageType WsMessageType, data string, responseTime time.Duration) ws.MessageFromString {
@@ -57,6 +130,62 @@ func NewWsMessageFromFiles(dirPath string) ws.MessageFromFiles {
 	return ws.NewMessageFromFiles(dirPath)
 }
 
+func NewWsMessageFromRecordFile(filePath string) ws.MessageFromRecordFile {
+	return ws.NewMessageFromRecordFile(filePath)
+}
+
+func NewWsMessageFromStreams(timeline *WsTimeline, paths ...string) ws.MessageFromStreams {
+	return ws.NewMessageFromStreams(timeline, paths...)
+}
+
+func NewWsMessageSequence(origin time.Time, records []WsRecord) ws.MessageSequence {
+	return ws.NewMessageSequence(origin, records)
+}
+
+func NewWsBroadcast(feed ws.MessageHandler) ws.Broadcast {
+	return ws.NewBroadcast(feed)
+}
+
+// NewWsCloseHandler returns a handler that closes the connection of the messages it handles as closure tells
+func NewWsCloseHandler(closure WsClosure) ws.CloseHandler {
+	return ws.NewCloseHandler(closure)
+}
+
+// NewWsFaultHandler returns handler run on connections that fault closes,
+// counting the messages it writes and the time from the first message it handles on a connection
+func NewWsFaultHandler(handler ws.MessageHandler, fault WsConnectionFault) ws.FaultHandler {
+	return ws.NewFaultHandler(handler, fault)
+}
+
+// NewWsChaosHandler returns handler with the messages it writes disturbed as chaos tells
+func NewWsChaosHandler(handler ws.MessageHandler, chaos WsChaos) ws.ChaosHandler {
+	return ws.NewChaosHandler(handler, chaos)
+}
+
 func NewWsRedirectHandler() ws.RedirectHandler {
 	return ws.NewRedirectHandler()
 }
+
+func NewWsStepHandler(timeline *FakeClock) ws.StepHandler {
+	return ws.NewStepHandler(timeline)
+}
+
+// Recordings
+
+func NewWsTimeline(origin time.Time, speed float64) *WsTimeline {
+	return ws.NewTimeline(origin, speed)
+}
+
+func ConvertWsRecordDir(dirPath string, filePath string) error {
+	return ws.ConvertRecordDir(dirPath, filePath)
+}
+
+func ReadWsRecords(filePath string) ([]WsRecord, error) {
+	return ws.ReadRecordsFromFile(filePath)
+}
+
+// Sessions
+
+func ReadWsSession(path string) ([]WsSessionEvent, error) {
+	return ws.ReadSessionFromFile(path)
+}
@@ -0,0 +1,127 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/log"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/clock"
+)
+
+// WsPeriodicHandler is run on every connection every Interval until it closes, e.g. to send heartbeats.
+// It is handed an empty message.
+type WsPeriodicHandler struct {
+	Interval time.Duration
+	Handler  WsMessageHandler
+}
+
+// WsPingPolicy tells how ping frames are sent to the connections, as exchanges do to find the clients that are gone
+type WsPingPolicy struct {
+	// Interval is how often a ping frame is sent on the simulator clock; 0 sends none
+	Interval time.Duration
+	// Timeout is how long the client has to answer with a pong before the connection is closed; 0 stands for Interval.
+	// It is measured on the wall clock, as the pong comes over the network.
+	Timeout time.Duration
+	// CloseCode and CloseReason are what a connection that does not answer is closed with.
+	// CloseCode 0 stands for 1008, policy violation.
+	CloseCode   int
+	CloseReason string
+}
+
+// runWsOnConnect runs the connect-time handlers of a connection in order, before any message of the client is handled
+func (s Simulator) runWsOnConnect(ctx context.Context, connClient WsConnection, connServer WsConnection) error {
+	for i, handler := range s.config.WsOnConnect {
+		err := handler.Handle(ctx, WsMessage{}, connClient, connServer)
+		if err != nil {
+			return fmt.Errorf("failed to run connect handler %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// startWsPeriodic starts the periodic handlers and the pings of a connection, which run until ctx is done.
+// ping sends a ping frame and waits for its pong, and closeConn closes a connection that does not answer.
+// A handler that fails or a ping that is not answered cancels ctx with the error.
+func (s Simulator) startWsPeriodic(
+	ctx context.Context,
+	cancel context.CancelCauseFunc,
+	connClient WsConnection,
+	connServer WsConnection,
+	ping func(context.Context) error,
+	closeConn func(WsClosure),
+) {
+	for i, periodic := range s.config.WsPeriodic {
+		// The handler is attached to the clock before the goroutine starts, so that a stepped clock waits for it
+		c, detach := clock.Attach(s.clock)
+		handlerCtx := clock.NewContext(ctx, c)
+		go func() {
+			defer detach()
+			err := every(handlerCtx, c, periodic.Interval, func() error {
+				return periodic.Handler.Handle(handlerCtx, WsMessage{}, connClient, connServer)
+			})
+			if err != nil && ctx.Err() == nil {
+				logger.Error("Error running periodic handler", log.Int("index", i), log.Any("error", err))
+				cancel(fmt.Errorf("failed to run periodic handler %d: %w", i, err))
+			}
+		}()
+	}
+
+	policy := s.config.WsPing
+	if policy.Interval > 0 {
+		go func() {
+			// The pings are not what a stepped clock steps to
+			err := every(ctx, clock.Background(s.clock), policy.Interval, func() error {
+				return pingWs(ctx, ping, closeConn, policy)
+			})
+			if err != nil && ctx.Err() == nil {
+				logger.Info("Closed a WebSocket connection that did not answer a ping", log.Any("error", err))
+				cancel(err)
+			}
+		}()
+	}
+}
+
+// every calls f every interval on c until f fails or ctx is done.
+// The calls are timed from the start rather than from each other, so that slow calls do not make them drift.
+func every(ctx context.Context, c Clock, interval time.Duration, f func() error) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval: %s", interval)
+	}
+
+	next := c.Now()
+	for {
+		next = next.Add(interval)
+		err := c.SleepUntil(ctx, next)
+		if err != nil {
+			return err
+		}
+
+		err = f()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// pingWs sends a ping frame and closes the connection if the pong does not come within the timeout of policy
+func pingWs(ctx context.Context, ping func(context.Context) error, closeConn func(WsClosure), policy WsPingPolicy) error {
+	timeout := policy.Timeout
+	if timeout <= 0 {
+		timeout = policy.Interval
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := ping(pingCtx)
+	if err == nil || ctx.Err() != nil {
+		return nil
+	}
+
+	code := policy.CloseCode
+	if code == 0 {
+		code = 1008
+	}
+	closeConn(WsClosure{Code: code, Reason: policy.CloseReason})
+	return fmt.Errorf("client did not answer a ping within %s: %w", timeout, err)
+}
@@ -0,0 +1,147 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulator_runWsOnConnect(t *testing.T) {
+	tests := []struct {
+		name          string
+		writeError    error
+		expectedData  []string
+		expectedError string
+	}{
+		{
+			name:         "Handlers run in order",
+			expectedData: []string{"welcome", "info"},
+		},
+		{
+			name:          "Handler fails",
+			writeError:    errors.New("closed"),
+			expectedData:  []string{"welcome"},
+			expectedError: "failed to run connect handler 0: closed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var written []string
+			mockConn := ws.NewMockConnection(t)
+			mockConn.On("Write", mock.Anything, mock.Anything).Return(tt.writeError).Run(func(args mock.Arguments) {
+				written = append(written, string(args.Get(1).(WsMessage).Data))
+			})
+			sim := New(Config{
+				WsOnConnect: []WsMessageHandler{
+					NewWsMessageFromString(WsMessageText, "welcome", 0),
+					NewWsMessageFromString(WsMessageText, "info", 0),
+				},
+			})
+
+			err := sim.runWsOnConnect(context.Background(), mockConn, nil)
+
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedData, written)
+		})
+	}
+}
+
+func TestSimulator_startWsPeriodic(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	c := NewFakeClock(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC))
+	start := c.Now()
+	written := make(chan time.Time)
+	mockConn := ws.NewMockConnection(t)
+	mockConn.On("Write", mock.Anything, WsMessage{Type: WsMessageText, Data: []byte("heartbeat")}).Return(nil).Run(func(args mock.Arguments) {
+		written <- c.Now()
+	})
+	sim := New(Config{
+		WsPeriodic: []WsPeriodicHandler{
+			{Interval: time.Second, Handler: NewWsMessageFromString(WsMessageText, "heartbeat", 0)},
+		},
+		Clock: c,
+	})
+
+	sim.startWsPeriodic(ctx, cancel, mockConn, nil, nil, nil)
+
+	for i := range 3 {
+		stepped, err := c.Step(ctx)
+		require.NoError(t, err)
+		require.True(t, stepped)
+		assert.Equal(t, start.Add(time.Duration(i+1)*time.Second), <-written)
+	}
+	assert.NoError(t, context.Cause(ctx))
+}
+
+func TestSimulator_startWsPeriodic_Ping(t *testing.T) {
+	tests := []struct {
+		name          string
+		answered      bool
+		expectedClose []any
+		expectedError string
+	}{
+		{
+			name:     "Pong",
+			answered: true,
+		},
+		{
+			name:          "No pong",
+			expectedClose: []any{1008, "pong timeout"},
+			expectedError: "client did not answer a ping within 10ms",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+
+			c := NewFakeClock(time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC))
+			pinged := make(chan struct{})
+			ping := func(ctx context.Context) error {
+				defer func() { pinged <- struct{}{} }()
+				if tt.answered {
+					return nil
+				}
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			var closed []any
+			closeConn := func(closure WsClosure) {
+				closed = []any{closure.Code, closure.Reason}
+			}
+			sim := New(Config{
+				WsPing: WsPingPolicy{Interval: 3 * time.Minute, Timeout: 10 * time.Millisecond, CloseReason: "pong timeout"},
+				Clock:  c,
+			})
+
+			sim.startWsPeriodic(ctx, cancel, nil, nil, ping, closeConn)
+			require.NoError(t, c.WaitForSleepers(ctx, 1))
+			c.Advance(3 * time.Minute)
+			<-pinged
+
+			if tt.expectedError != "" {
+				<-ctx.Done()
+				assert.ErrorContains(t, context.Cause(ctx), tt.expectedError)
+			} else {
+				// The next ping is waited for, rather than the connection closed
+				require.NoError(t, c.WaitForSleepers(ctx, 1))
+				assert.NoError(t, context.Cause(ctx))
+			}
+			assert.Equal(t, tt.expectedClose, closed)
+		})
+	}
+}
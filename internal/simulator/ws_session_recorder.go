@@ -0,0 +1,82 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"alphanonce.com/exchangesimulator/internal/log"
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+
+	"github.com/coder/websocket"
+)
+
+// Ensure sessionRecordingConnection implements WsConnection
+var _ WsConnection = (*sessionRecordingConnection)(nil)
+
+// sessionRecordingConnection records every message read from a connection,
+// and how the peer closed the connection once reading fails
+type sessionRecordingConnection struct {
+	WsConnection
+	recorder     *ws.SessionRecorder
+	clock        Clock
+	connectionId uint64
+	direction    ws.Direction
+}
+
+func newSessionRecordingConnection(conn WsConnection, recorder *ws.SessionRecorder, clock Clock, connectionId uint64, direction ws.Direction) sessionRecordingConnection {
+	return sessionRecordingConnection{
+		WsConnection: conn,
+		recorder:     recorder,
+		clock:        clock,
+		connectionId: connectionId,
+		direction:    direction,
+	}
+}
+
+func (c sessionRecordingConnection) Read(ctx context.Context) (WsMessage, error) {
+	message, err := c.WsConnection.Read(ctx)
+	if err != nil {
+		// The read was abandoned rather than the connection closed by the peer
+		if ctx.Err() != nil {
+			return message, err
+		}
+
+		event := ws.SessionEvent{
+			Time:         c.clock.Now(),
+			ConnectionId: c.connectionId,
+			Kind:         ws.SessionEventClose,
+			Direction:    c.direction,
+			CloseCode:    int(websocket.CloseStatus(err)),
+		}
+		var closeErr websocket.CloseError
+		if errors.As(err, &closeErr) {
+			event.CloseReason = closeErr.Reason
+		}
+		recordErr := c.recorder.Record(event)
+		if recordErr != nil {
+			logger.Error("Error recording WebSocket session", log.Any("error", recordErr))
+		}
+		return message, err
+	}
+
+	err = c.recorder.Record(ws.SessionEvent{
+		Time:         c.clock.Now(),
+		ConnectionId: c.connectionId,
+		Kind:         ws.SessionEventMessage,
+		Direction:    c.direction,
+		Message:      message,
+	})
+	if err != nil {
+		return WsMessage{}, fmt.Errorf("failed to record message: %w", err)
+	}
+	return message, nil
+}
+
+func (s Simulator) recordSessionEvent(event ws.SessionEvent) {
+	event.Time = s.clock.Now()
+	err := s.sessionRecorder.Record(event)
+	if err != nil {
+		logger.Error("Error recording WebSocket session", log.Any("error", err))
+	}
+}
@@ -0,0 +1,71 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionRecordingConnection_Read(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder := ws.NewSessionRecorder(path)
+
+	message := WsMessage{Type: WsMessageText, Data: []byte("subscribe")}
+	mockConn := ws.NewMockConnection(t)
+	mockConn.On("Read", mock.Anything).Return(message, nil).Once()
+	mockConn.On("Read", mock.Anything).Return(WsMessage{}, websocket.CloseError{Code: websocket.StatusGoingAway, Reason: "bye"}).Once()
+
+	now := time.Date(2000, 1, 23, 12, 34, 56, 0, time.UTC)
+	conn := newSessionRecordingConnection(mockConn, recorder, NewFakeClock(now), 7, ws.DirectionClientToServer)
+
+	ctx := context.Background()
+	received, err := conn.Read(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, message, received)
+
+	_, err = conn.Read(ctx)
+	assert.Error(t, err)
+	require.NoError(t, recorder.Close())
+
+	events, err := ws.ReadSessionFromFile(path)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	assert.True(t, now.Equal(events[0].Time))
+	assert.Equal(t, uint64(7), events[0].ConnectionId)
+	assert.Equal(t, ws.SessionEventMessage, events[0].Kind)
+	assert.Equal(t, ws.DirectionClientToServer, events[0].Direction)
+	assert.Equal(t, message, events[0].Message)
+
+	assert.Equal(t, uint64(7), events[1].ConnectionId)
+	assert.Equal(t, ws.SessionEventClose, events[1].Kind)
+	assert.Equal(t, ws.DirectionClientToServer, events[1].Direction)
+	assert.Equal(t, int(websocket.StatusGoingAway), events[1].CloseCode)
+	assert.Equal(t, "bye", events[1].CloseReason)
+}
+
+func TestSessionRecordingConnection_Read_Abandoned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder := ws.NewSessionRecorder(path)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mockConn := ws.NewMockConnection(t)
+	mockConn.On("Read", ctx).Return(WsMessage{}, errors.New("context canceled"))
+
+	conn := newSessionRecordingConnection(mockConn, recorder, RealClock{}, 1, ws.DirectionServerToClient)
+	_, err := conn.Read(ctx)
+	assert.Error(t, err)
+
+	_, err = ws.ReadSessionFromFile(path)
+	assert.Error(t, err, "nothing should have been recorded")
+}
@@ -0,0 +1,265 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+)
+
+// DefaultWsWriteBufferSize is how many messages may be queued to be written to a connection if Config.WsWriteBufferSize is 0
+const DefaultWsWriteBufferSize = 1024
+
+// WsSlowConsumerAction tells what is done with a message written to a connection whose buffer is full
+type WsSlowConsumerAction int
+
+const (
+	// WsSlowConsumerBlock waits until the buffer has room
+	WsSlowConsumerBlock WsSlowConsumerAction = iota
+	// WsSlowConsumerDropOldest drops the oldest message of the buffer to make room
+	WsSlowConsumerDropOldest
+	// WsSlowConsumerDropNewest drops the message written
+	WsSlowConsumerDropNewest
+	// WsSlowConsumerClose closes the connection, as exchanges disconnect the clients that cannot keep up
+	WsSlowConsumerClose
+)
+
+// WsSlowConsumerPolicy tells how a connection that cannot keep up with the messages written to it is treated
+type WsSlowConsumerPolicy struct {
+	Action WsSlowConsumerAction
+	// CloseCode and CloseReason are what the connection is closed with by WsSlowConsumerClose.
+	// CloseCode 0 stands for 1008, policy violation.
+	CloseCode   int
+	CloseReason string
+}
+
+// WsBacklog tells how well a connection keeps up with the messages written to it
+type WsBacklog struct {
+	ConnectionId uint64
+	// Queued is how many messages are waiting to be written, and MaxQueued the most there have been
+	Queued    int
+	MaxQueued int
+	Written   uint64
+	Dropped   uint64
+}
+
+var errWsClosed = errors.New("the connection was closed by the simulator")
+
+// Ensure wsWriteQueue implements WsConnection
+var _ WsConnection = (*wsWriteQueue)(nil)
+
+// Ensure wsWriteQueue implements ws.Closer
+var _ ws.Closer = (*wsWriteQueue)(nil)
+
+// wsWriteQueue serializes the writes to a connection, which the handlers of several messages, the subscriptions
+// and the redirection make concurrently. The messages are queued in a bounded buffer and written one at a time
+// by a single goroutine, in the order they were queued.
+// Write returns once the message is queued; what it does while the buffer is full is up to the slow consumer policy.
+type wsWriteQueue struct {
+	WsConnection
+	size      int
+	policy    WsSlowConsumerPolicy
+	timeout   time.Duration
+	closeConn func(WsClosure)
+	cancel    context.CancelCauseFunc
+
+	lock     sync.Mutex
+	messages []WsMessage
+	// closing is how the connection is closed once the messages queued are written, if it is to be closed
+	closing *WsClosure
+	// changed is closed and replaced whenever a message is queued or taken to be written
+	changed chan struct{}
+	// done is closed once the messages are no longer written, and err tells why
+	done    chan struct{}
+	err     error
+	backlog WsBacklog
+}
+
+// newWsWriteQueue writes the messages queued to conn until ctx is done or a write fails,
+// in which case it calls cancel with the error. closeConn closes the connection for WsSlowConsumerClose and Close.
+func newWsWriteQueue(ctx context.Context, cancel context.CancelCauseFunc, conn WsConnection, connectionId uint64, closeConn func(WsClosure), config Config) *wsWriteQueue {
+	size := config.WsWriteBufferSize
+	if size <= 0 {
+		size = DefaultWsWriteBufferSize
+	}
+	q := &wsWriteQueue{
+		WsConnection: conn,
+		size:         size,
+		policy:       config.WsSlowConsumerPolicy,
+		timeout:      config.WsWriteTimeout,
+		closeConn:    closeConn,
+		cancel:       cancel,
+		changed:      make(chan struct{}),
+		done:         make(chan struct{}),
+		backlog:      WsBacklog{ConnectionId: connectionId},
+	}
+
+	go func() {
+		q.stop(q.run(ctx))
+	}()
+	return q
+}
+
+func (q *wsWriteQueue) run(ctx context.Context) error {
+	for {
+		q.lock.Lock()
+		if len(q.messages) == 0 {
+			if q.closing != nil {
+				closure := *q.closing
+				q.lock.Unlock()
+				q.closeConn(closure)
+				return errWsClosed
+			}
+
+			changed := q.changed
+			q.lock.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			case <-q.done:
+				return nil
+			case <-changed:
+			}
+			continue
+		}
+		message := q.messages[0]
+		q.messages = q.messages[1:]
+		q.backlog.Queued = len(q.messages)
+		q.notify()
+		q.lock.Unlock()
+
+		err := q.write(ctx, message)
+		if err != nil {
+			return fmt.Errorf("failed to write to client: %w", err)
+		}
+
+		q.lock.Lock()
+		q.backlog.Written++
+		q.lock.Unlock()
+	}
+}
+
+func (q *wsWriteQueue) write(ctx context.Context, message WsMessage) error {
+	if q.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.timeout)
+		defer cancel()
+	}
+	return q.WsConnection.Write(ctx, message)
+}
+
+// stop stops writing the messages for err, unless they were stopped already
+func (q *wsWriteQueue) stop(err error) {
+	q.lock.Lock()
+	select {
+	case <-q.done:
+		q.lock.Unlock()
+		return
+	default:
+	}
+	q.err = err
+	close(q.done)
+	q.notify()
+	q.lock.Unlock()
+
+	q.cancel(err)
+}
+
+// notify wakes up the goroutines waiting for a change. q.lock must be held.
+func (q *wsWriteQueue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+func (q *wsWriteQueue) Write(ctx context.Context, message WsMessage) error {
+	for {
+		q.lock.Lock()
+		select {
+		case <-q.done:
+			q.lock.Unlock()
+			return q.err
+		default:
+		}
+		if q.closing != nil {
+			q.lock.Unlock()
+			return errWsClosed
+		}
+
+		if len(q.messages) < q.size {
+			q.queue(message)
+			q.lock.Unlock()
+			return nil
+		}
+
+		switch q.policy.Action {
+		case WsSlowConsumerDropOldest:
+			q.messages = q.messages[1:]
+			q.backlog.Dropped++
+			q.queue(message)
+			q.lock.Unlock()
+			return nil
+		case WsSlowConsumerDropNewest:
+			q.backlog.Dropped++
+			q.lock.Unlock()
+			return nil
+		case WsSlowConsumerClose:
+			q.lock.Unlock()
+			err := fmt.Errorf("client fell %d messages behind", q.size)
+			code := q.policy.CloseCode
+			if code == 0 {
+				code = 1008
+			}
+			q.closeConn(WsClosure{Code: code, Reason: q.policy.CloseReason})
+			q.stop(err)
+			return err
+		}
+
+		changed := q.changed
+		q.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-changed:
+		}
+	}
+}
+
+// Close closes the connection as closure tells once the messages queued are written
+func (q *wsWriteQueue) Close(closure WsClosure) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	select {
+	case <-q.done:
+		return q.err
+	default:
+	}
+	if q.closing != nil {
+		return errWsClosed
+	}
+
+	q.closing = &closure
+	q.notify()
+	return nil
+}
+
+// queue adds message to the end of the queue. q.lock must be held.
+func (q *wsWriteQueue) queue(message WsMessage) {
+	q.messages = append(q.messages, message)
+	q.backlog.Queued = len(q.messages)
+	q.backlog.MaxQueued = max(q.backlog.MaxQueued, q.backlog.Queued)
+	q.notify()
+}
+
+// Backlog tells how well the connection keeps up with the messages written to it
+func (q *wsWriteQueue) Backlog() WsBacklog {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.backlog
+}
@@ -0,0 +1,215 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"alphanonce.com/exchangesimulator/internal/simulator/internal/rule/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWsWriteQueue_Write(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	// Three writers queue their messages concurrently, and each writer's messages are written in order
+	var lock sync.Mutex
+	written := map[string][]int{}
+	var wg sync.WaitGroup
+	wg.Add(30)
+	mockConn := ws.NewMockConnection(t)
+	mockConn.On("Write", ctx, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		var writer string
+		var i int
+		fmt.Sscanf(string(args.Get(1).(WsMessage).Data), "%s %d", &writer, &i)
+		lock.Lock()
+		written[writer] = append(written[writer], i)
+		lock.Unlock()
+		wg.Done()
+	})
+	q := newWsWriteQueue(ctx, cancel, mockConn, 1, nil, Config{WsWriteBufferSize: 4})
+
+	for _, writer := range []string{"a", "b", "c"} {
+		go func() {
+			for i := range 10 {
+				assert.NoError(t, q.Write(context.Background(), WsMessage{Type: WsMessageText, Data: []byte(fmt.Sprintf("%s %d", writer, i))}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, writer := range []string{"a", "b", "c"} {
+		assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, written[writer])
+	}
+}
+
+func TestWsWriteQueue_Write_Full(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	// The first message is being written until unblocked, and the second one fills the buffer
+	unblock := make(chan struct{})
+	mockConn := ws.NewMockConnection(t)
+	mockConn.On("Write", ctx, mock.Anything).Return(nil).Run(func(mock.Arguments) { <-unblock })
+	q := newWsWriteQueue(ctx, cancel, mockConn, 1, nil, Config{WsWriteBufferSize: 1})
+	message := WsMessage{Type: WsMessageText, Data: []byte("data")}
+	assert.NoError(t, q.Write(context.Background(), message))
+	assert.Eventually(t, func() bool { return q.Backlog().Queued == 0 }, time.Second, time.Millisecond)
+	assert.NoError(t, q.Write(context.Background(), message))
+
+	writeCtx, writeCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer writeCancel()
+	err := q.Write(writeCtx, message)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	close(unblock)
+}
+
+func TestWsWriteQueue_Write_Error(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	writeErr := errors.New("write error")
+	mockConn := ws.NewMockConnection(t)
+	mockConn.On("Write", ctx, mock.Anything).Return(writeErr).Once()
+	q := newWsWriteQueue(ctx, cancel, mockConn, 1, nil, Config{WsWriteBufferSize: 1})
+	message := WsMessage{Type: WsMessageText, Data: []byte("data")}
+
+	assert.NoError(t, q.Write(context.Background(), message))
+	<-ctx.Done()
+
+	assert.ErrorIs(t, context.Cause(ctx), writeErr)
+	assert.ErrorIs(t, q.Write(context.Background(), message), writeErr)
+}
+
+func TestWsWriteQueue_Write_SlowConsumer(t *testing.T) {
+	tests := []struct {
+		name            string
+		policy          WsSlowConsumerPolicy
+		expectedErr     bool
+		expectedWritten []string
+		expectedClose   []any
+		expectedBacklog WsBacklog
+	}{
+		{
+			name:            "Drop oldest",
+			policy:          WsSlowConsumerPolicy{Action: WsSlowConsumerDropOldest},
+			expectedWritten: []string{"0", "3", "4"},
+			expectedBacklog: WsBacklog{ConnectionId: 7, MaxQueued: 2, Written: 3, Dropped: 2},
+		},
+		{
+			name:            "Drop newest",
+			policy:          WsSlowConsumerPolicy{Action: WsSlowConsumerDropNewest},
+			expectedWritten: []string{"0", "1", "2"},
+			expectedBacklog: WsBacklog{ConnectionId: 7, MaxQueued: 2, Written: 3, Dropped: 2},
+		},
+		{
+			name:          "Close",
+			policy:        WsSlowConsumerPolicy{Action: WsSlowConsumerClose, CloseReason: "too slow"},
+			expectedErr:   true,
+			expectedClose: []any{1008, "too slow"},
+		},
+		{
+			name:          "Close with a code",
+			policy:        WsSlowConsumerPolicy{Action: WsSlowConsumerClose, CloseCode: 4000},
+			expectedErr:   true,
+			expectedClose: []any{4000, ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+
+			// The first message is being written until unblocked, and the next two fill the buffer
+			unblock := make(chan struct{})
+			written := make(chan string, 5)
+			mockConn := ws.NewMockConnection(t)
+			mockConn.On("Write", ctx, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				<-unblock
+				written <- string(args.Get(1).(WsMessage).Data)
+			}).Maybe()
+			var closed []any
+			closeConn := func(closure WsClosure) { closed = []any{closure.Code, closure.Reason} }
+			q := newWsWriteQueue(ctx, cancel, mockConn, 7, closeConn, Config{WsWriteBufferSize: 2, WsSlowConsumerPolicy: tt.policy})
+
+			require.NoError(t, q.Write(context.Background(), textMessage("0")))
+			assert.Eventually(t, func() bool { return q.Backlog().Queued == 0 }, time.Second, time.Millisecond)
+			var err error
+			for i := 1; i < 5 && err == nil; i++ {
+				err = q.Write(context.Background(), textMessage(fmt.Sprint(i)))
+			}
+
+			if tt.expectedErr {
+				assert.Error(t, err)
+				assert.ErrorIs(t, context.Cause(ctx), err)
+				assert.Equal(t, tt.expectedClose, closed)
+				close(unblock)
+				return
+			}
+			assert.NoError(t, err)
+			close(unblock)
+			var result []string
+			for range tt.expectedWritten {
+				result = append(result, <-written)
+			}
+			assert.Equal(t, tt.expectedWritten, result)
+			assert.Eventually(t, func() bool { return q.Backlog() == tt.expectedBacklog }, time.Second, time.Millisecond)
+		})
+	}
+}
+
+func TestWsWriteQueue_Write_Timeout(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	mockConn := ws.NewMockConnection(t)
+	mockConn.On("Write", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return(context.DeadlineExceeded)
+	q := newWsWriteQueue(ctx, cancel, mockConn, 1, nil, Config{WsWriteTimeout: time.Millisecond})
+
+	require.NoError(t, q.Write(context.Background(), textMessage("data")))
+	<-ctx.Done()
+
+	assert.ErrorIs(t, context.Cause(ctx), context.DeadlineExceeded)
+}
+
+func TestWsWriteQueue_Close(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	// The messages queued before the connection is closed are written before it
+	unblock := make(chan struct{})
+	var written []string
+	mockConn := ws.NewMockConnection(t)
+	mockConn.On("Write", ctx, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		<-unblock
+		written = append(written, string(args.Get(1).(WsMessage).Data))
+	}).Twice()
+	var closed []WsClosure
+	closeConn := func(closure WsClosure) {
+		closed = append(closed, closure)
+		assert.Equal(t, []string{"1", "2"}, written)
+	}
+	q := newWsWriteQueue(ctx, cancel, mockConn, 1, closeConn, Config{})
+
+	require.NoError(t, q.Write(context.Background(), textMessage("1")))
+	require.NoError(t, q.Write(context.Background(), textMessage("2")))
+	closure := WsClosure{Code: 1001, Reason: "maintenance"}
+	require.NoError(t, q.Close(closure))
+	assert.ErrorIs(t, q.Write(context.Background(), textMessage("3")), errWsClosed)
+	assert.ErrorIs(t, q.Close(closure), errWsClosed)
+	close(unblock)
+	<-ctx.Done()
+
+	assert.ErrorIs(t, context.Cause(ctx), errWsClosed)
+	assert.Equal(t, []WsClosure{closure}, closed)
+}
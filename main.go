@@ -52,6 +52,7 @@ func main() {
 		},
 		WsRedirectUrl: "wss://api.whitebit.com/ws",
 		WsRecordDir:   filepath.Join("records", "ws"),
+		MetricsPath:   "/metrics",
 	}
 	sim := simulator.New(config)
 